@@ -2,12 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
 )
 
+// historyPageSize is the number of messages history shows at once
+const historyPageSize = 20
+
 type API struct {
 	client *Client
 }
@@ -15,7 +24,22 @@ type API struct {
 const (
 	identity = "identity"
 	list     = "list"
+	count    = "count"
+	devices  = "devices"
 	relay    = "relay"
+	ask      = "ask"
+	seal     = "seal"
+	enqueue  = "enqueue"
+	join     = "join"
+	leave    = "leave"
+	sendFile = "send"
+	block    = "block"
+	unblock  = "unblock"
+	blocked  = "blocked"
+	profile  = "profile"
+	whois    = "whois"
+	find     = "find"
+	history  = "history"
 	quit     = "quit"
 	help     = "help"
 )
@@ -27,6 +51,11 @@ func NewAPI(client *Client) *API {
 }
 
 func (a *API) Run() {
+	go a.saveStreams()
+	go a.printReceipts()
+	go a.printGaps()
+	go a.printJobs()
+	a.client.Handle(echo)
 	scanner := bufio.NewScanner(os.Stdin)
 
 	var cmd string
@@ -42,6 +71,10 @@ func (a *API) Run() {
 				fmt.Printf("GetIdentity failed: %s", err.Error())
 				continue
 			}
+			if token := a.client.Token(); token != "" {
+				fmt.Printf("my user_id=%d token=%s\n", id, token)
+				continue
+			}
 			fmt.Printf("my user_id=%d\n", id)
 		case list:
 			ids, err := a.client.ListUsers()
@@ -50,27 +83,258 @@ func (a *API) Run() {
 				continue
 			}
 			fmt.Printf("active users=%v\n", ids)
+		case count:
+			total, err := a.client.CountUsers()
+			if err != nil {
+				fmt.Printf("CountUsers failed: %s", err.Error())
+				continue
+			}
+			fmt.Printf("active users count=%d\n", total)
+		case devices:
+			userDevices, err := a.client.ListUserDevices()
+			if err != nil {
+				fmt.Printf("ListUserDevices failed: %s", err.Error())
+				continue
+			}
+			fmt.Printf("active users with devices=%v\n", userDevices)
 		case relay:
 			// collect user ids
 			fmt.Printf("Enter comma separated list of users to relay message to: ")
 			scanner.Scan()
 			usersStr := scanner.Text()
-			users := strings.Split(usersStr, ",")
-			userIds := make([]int32, 0, len(users))
-			for _, user := range users {
-				if id, err := strconv.Atoi(user); err == nil {
-					userIds = append(userIds, int32(id))
-				}
-			}
+			userIds := parseIDs(usersStr)
 
 			// read message
 			fmt.Printf("Enter message: ")
 			scanner.Scan()
 			msg := scanner.Text()
 
+			// read delivery options
+			opts := RelayOptions{Resend: true}
+			fmt.Printf("Enter seconds to live (empty to keep until delivered): ")
+			scanner.Scan()
+			if ttl, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64); err == nil && ttl > 0 {
+				opts.TTL = time.Duration(ttl * float64(time.Second))
+			}
+			fmt.Printf("Request delivery receipts? (y/n) ")
+			scanner.Scan()
+			opts.Receipt = scanner.Text() == "y"
+			fmt.Printf("Enter priority (high/normal/low, empty for normal): ")
+			scanner.Scan()
+			if priority, ok := messages.RelayRequest_Priority_value[strings.ToUpper(strings.TrimSpace(scanner.Text()))]; ok {
+				opts.Priority = messages.RelayRequest_Priority(priority)
+			}
+
 			// relay message
 			fmt.Printf("sending msg='%s' to users=%s\n", msg, usersStr)
-			a.client.RelayRequest(userIds, []byte(msg))
+			receipt, err := a.client.RelayWithOptions(userIds, []byte(msg), opts)
+			if err != nil {
+				fmt.Printf("RelayWithOptions failed: %s\n", err.Error())
+				continue
+			}
+			if receipt != 0 {
+				fmt.Printf("receipts will refer to receipt=%d\n", receipt)
+			}
+		case seal:
+			fmt.Printf("Enter comma separated list of users to relay sealed message to: ")
+			scanner.Scan()
+			userIds := parseIDs(scanner.Text())
+			fmt.Printf("Enter message: ")
+			scanner.Scan()
+			if err := a.client.RelaySealed(userIds, []byte(scanner.Text()), RelayOptions{Resend: true}); err != nil {
+				fmt.Printf("RelaySealed failed: %s\n", err.Error())
+			}
+		case ask:
+			fmt.Printf("Enter user to ask: ")
+			scanner.Scan()
+			peer := parseIDs(scanner.Text())
+			if len(peer) != 1 {
+				fmt.Println("Expected one user")
+				continue
+			}
+			fmt.Printf("Enter message: ")
+			scanner.Scan()
+			reply, err := a.client.Request(context.Background(), peer[0], []byte(scanner.Text()))
+			if err != nil {
+				fmt.Printf("Request failed: %s\n", err.Error())
+				continue
+			}
+			fmt.Printf("reply from user=%d msg='%s'\n", peer[0], reply)
+		case enqueue:
+			fmt.Printf("Enter group: ")
+			scanner.Scan()
+			group := strings.TrimSpace(scanner.Text())
+			fmt.Printf("Enter message: ")
+			scanner.Scan()
+			if _, err := a.client.RelayWithOptions(nil, []byte(scanner.Text()), RelayOptions{Group: group, Resend: true}); err != nil {
+				fmt.Printf("RelayWithOptions failed: %s\n", err.Error())
+			}
+		case join, leave:
+			fmt.Printf("Enter group: ")
+			scanner.Scan()
+			group := strings.TrimSpace(scanner.Text())
+			var groups []string
+			var err error
+			if cmd == join {
+				groups, err = a.client.JoinGroup(group)
+			} else {
+				groups, err = a.client.LeaveGroup(group)
+			}
+			if err != nil {
+				fmt.Printf("%s failed: %s\n", cmd, err.Error())
+				continue
+			}
+			fmt.Printf("groups=%v\n", groups)
+		case sendFile:
+			// collect user ids
+			fmt.Printf("Enter comma separated list of users to send file to: ")
+			scanner.Scan()
+			usersStr := scanner.Text()
+			userIds := parseIDs(usersStr)
+
+			// open file
+			fmt.Printf("Enter file path: ")
+			scanner.Scan()
+			path := scanner.Text()
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Printf("opening file failed: %s\n", err.Error())
+				continue
+			}
+			var length int64
+			if info, err := f.Stat(); err == nil {
+				length = info.Size()
+			}
+
+			// stream file
+			fmt.Printf("sending file='%s' to users=%s\n", path, usersStr)
+			err = a.client.SendStream(userIds, filepath.Base(path), length, f)
+			f.Close()
+			if err != nil {
+				fmt.Printf("SendStream failed: %s\n", err.Error())
+				continue
+			}
+			fmt.Printf("file='%s' sent\n", path)
+		case block, unblock:
+			fmt.Printf("Enter comma separated list of users to %s: ", cmd)
+			scanner.Scan()
+			userIds := parseIDs(scanner.Text())
+
+			var ids []int32
+			var err error
+			if cmd == block {
+				ids, err = a.client.BlockUsers(userIds)
+			} else {
+				ids, err = a.client.UnblockUsers(userIds)
+			}
+			if err != nil {
+				fmt.Printf("%s failed: %s", cmd, err.Error())
+				continue
+			}
+			fmt.Printf("blocked users=%v\n", ids)
+		case blocked:
+			ids, err := a.client.BlockedUsers()
+			if err != nil {
+				fmt.Printf("BlockedUsers failed: %s", err.Error())
+				continue
+			}
+			fmt.Printf("blocked users=%v\n", ids)
+		case profile:
+			fmt.Printf("Enter display name: ")
+			scanner.Scan()
+			name := scanner.Text()
+			fmt.Printf("Enter comma separated list of key=value metadata: ")
+			scanner.Scan()
+			metadata := parseMetadata(scanner.Text())
+
+			p, err := a.client.SetProfile(name, metadata)
+			if err != nil {
+				fmt.Printf("SetProfile failed: %s\n", err.Error())
+				continue
+			}
+			fmt.Printf("my profile=%s\n", formatProfile(p))
+		case whois:
+			fmt.Printf("Enter comma separated list of users to look up: ")
+			scanner.Scan()
+			profiles, err := a.client.LookupUsers(parseIDs(scanner.Text()))
+			if err != nil {
+				fmt.Printf("LookupUsers failed: %s\n", err.Error())
+				continue
+			}
+			for _, p := range profiles {
+				fmt.Printf("user=%s\n", formatProfile(p))
+			}
+		case find:
+			query := &messages.DirectoryQuery{}
+			fmt.Printf("Enter name prefix: ")
+			scanner.Scan()
+			query.NamePrefix = scanner.Text()
+			fmt.Printf("Enter comma separated list of key=value attributes to match: ")
+			scanner.Scan()
+			for key, value := range parseMetadata(scanner.Text()) {
+				query.Attributes = append(query.Attributes, &messages.Attribute{Key: key, Value: value})
+			}
+			fmt.Printf("Enter presence (any, online or offline): ")
+			scanner.Scan()
+			query.Presence = messages.DirectoryQuery_Presence(messages.DirectoryQuery_Presence_value[strings.ToUpper(scanner.Text())])
+
+			// print all pages
+			for {
+				users, next, err := a.client.FindUsers(query)
+				if err != nil {
+					fmt.Printf("FindUsers failed: %s\n", err.Error())
+					break
+				}
+				for _, u := range users {
+					fmt.Printf("user=%s online=%t devices=%d\n", formatProfile(u.Profile), u.Online, u.Devices)
+				}
+				if next == 0 {
+					break
+				}
+				query.After = next
+			}
+		case history:
+			fmt.Printf("Enter comma separated list of users to show the conversation with: ")
+			scanner.Scan()
+			peers := parseIDs(scanner.Text())
+			if len(peers) == 0 {
+				fmt.Println("Expected users")
+				continue
+			}
+
+			// show pages from the newest while asked for older ones
+			var before uint64
+			for {
+				var entries []*messages.HistoryEntry
+				var next uint64
+				var err error
+				if len(peers) == 1 {
+					entries, next, err = a.client.History(peers[0], before, historyPageSize)
+				} else {
+					entries, next, err = a.client.GroupHistory(peers, before, historyPageSize)
+				}
+				if err != nil {
+					fmt.Printf("History failed: %s\n", err.Error())
+					break
+				}
+				for _, e := range entries {
+					to := fmt.Sprint(e.To)
+					if len(e.Receivers) > 0 {
+						to = fmt.Sprint(e.Receivers)
+					}
+					fmt.Printf("%s message_id=%d from=%d to=%s msg='%s'\n",
+						time.Unix(0, e.Timestamp*int64(time.Millisecond)).Format(time.RFC3339), e.Id, e.From, to, e.Body)
+				}
+				if next == 0 {
+					break
+				}
+				fmt.Printf("Show older messages? (y/n): ")
+				scanner.Scan()
+				if scanner.Text() != "y" {
+					break
+				}
+				before = next
+			}
 		case quit:
 			break
 		case help:
@@ -81,7 +345,22 @@ Usage:
 
 identity - authentify on hub (if not already authentified)
 list - show list of currently active users
+count - show the number of currently active users
+devices - show currently active users with the number of their devices
 relay - relay message to selected users
+seal - relay message encrypted end to end to selected users, all of them must run with -e2e
+ask - send a message to a user and wait for the reply, clients echo messages they are asked
+enqueue - relay message to one member of a queue group
+join - join a queue group, messages to the group are shown and acked
+leave - leave a queue group
+send - send a file of any size to selected users, received files are saved to the working directory
+block - stop selected users from relaying messages to you
+unblock - allow selected users to relay messages to you again
+blocked - show list of blocked users
+profile - set your display name and metadata
+whois - show profiles of selected users
+find - search users by name prefix, attributes and presence
+history - show messages exchanged with a user or with several users at once, if the hub keeps history
 quit - quit the program
 help - show this help
 
@@ -91,3 +370,96 @@ help - show this help
 		}
 	}
 }
+
+// echo answers calls with their body
+func echo(req Request) Response {
+	fmt.Printf("\nasked by user=%d msg='%s'\n", req.From, req.Body)
+	return Response{Body: req.Body}
+}
+
+// printJobs prints relays to queue groups as they arrive and acks them
+func (a *API) printJobs() {
+	for job := range a.client.Jobs() {
+		fmt.Printf("\njob=%d group=%s from=%d msg='%s'\n", job.ID, job.Group, job.From, job.Body)
+		if err := job.Ack(); err != nil {
+			fmt.Printf("Ack failed: %s\n", err.Error())
+		}
+	}
+}
+
+// printReceipts prints receipts of relays as they arrive
+func (a *API) printReceipts() {
+	for receipt := range a.client.Receipts() {
+		fmt.Printf("\nreceipt=%d user=%d status=%s\n", receipt.Receipt, receipt.Id, strings.ToLower(receipt.Status.String()))
+	}
+}
+
+// printGaps prints runs of relays missed as they are noticed
+func (a *API) printGaps() {
+	for gap := range a.client.Gaps() {
+		fmt.Printf("\nmissed messages %d-%d from user=%d\n", gap.First, gap.Last, gap.From)
+	}
+}
+
+// saveStreams saves incoming streams to files named after the stream and the sender's file name
+func (a *API) saveStreams() {
+	for stream := range a.client.Streams() {
+		go saveStream(stream)
+	}
+}
+
+func saveStream(stream *Stream) {
+	path := fmt.Sprintf("stream-%d-%s", stream.ID, filepath.Base(stream.Name))
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("\ncreating file failed: %s\n", err.Error())
+		stream.Close()
+		return
+	}
+	n, err := io.Copy(f, stream)
+	f.Close()
+	if err != nil {
+		fmt.Printf("\nreceiving file='%s' from user=%d failed: %s\n", path, stream.From, err.Error())
+		stream.Close()
+		return
+	}
+	fmt.Printf("\nreceived file='%s' (%d bytes) from user=%d\n", path, n, stream.From)
+}
+
+// parseIDs parses comma separated list of user ids, skipping malformed ones
+func parseIDs(s string) []int32 {
+	users := strings.Split(s, ",")
+	ids := make([]int32, 0, len(users))
+	for _, user := range users {
+		if id, err := strconv.Atoi(strings.TrimSpace(user)); err == nil {
+			ids = append(ids, int32(id))
+		}
+	}
+	return ids
+}
+
+// parseMetadata parses comma separated key=value pairs, skipping pairs without key
+func parseMetadata(s string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if key == "" {
+			continue
+		}
+		var value string
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+func formatProfile(p *messages.Profile) string {
+	attrs := make([]string, len(p.Metadata))
+	for i, attr := range p.Metadata {
+		attrs[i] = attr.Key + "=" + attr.Value
+	}
+	return fmt.Sprintf("%d name='%s' metadata=[%s]", p.Id, p.Name, strings.Join(attrs, ", "))
+}
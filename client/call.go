@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoResponder is returned by Request when the call reached no session of the user,
+// or no session of the user handles calls
+var ErrNoResponder = errors.New(messages.ReplyNoResponder)
+
+// ResponseError is an error the responder replied with
+type ResponseError string
+
+func (e ResponseError) Error() string {
+	return fmt.Sprintf("responder failed: %s", string(e))
+}
+
+// Request is a call received from another user
+type Request struct {
+	From int32
+	Body []byte
+}
+
+// Response is the reply to a Request, a non-empty Error fails the call
+type Response struct {
+	Body  []byte
+	Error string
+}
+
+// Request relays body to userID as a call and waits for the reply. The call fails with the
+// context, or after the request timeout if ctx has no deadline, and with ErrNoResponder if
+// nobody takes it. A user with several sessions handling calls replies from each of them,
+// the first reply is taken.
+func (c *Client) Request(ctx context.Context, userID int32, body []byte) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	if len(body) > messages.BodyMaxLength {
+		body = body[:messages.BodyMaxLength]
+	}
+
+	token, replies := c.newCall()
+	defer c.endCall(token)
+	req := &messages.RelayRequest{
+		Id:   c.id,
+		Ids:  []int32{userID},
+		Body: body,
+		Call: token,
+	}
+	// the hub drops a call nobody took before the caller gives up on it
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Ttl = uint32((time.Until(deadline) + time.Millisecond - 1) / time.Millisecond)
+	}
+	if err := c.send(req, messages.MsgTypeRelayRequest); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("request to %d failed: %w", userID, ctx.Err())
+	case resp := <-replies:
+		switch {
+		case resp.Error == messages.ReplyNoResponder:
+			return nil, ErrNoResponder
+		case resp.Error != "":
+			return nil, ResponseError(resp.Error)
+		}
+		return resp.Body, nil
+	}
+}
+
+// Handle makes the client answer calls with handler, which is run in a goroutine of its own
+// for every call. Calls received without a handler are replied with ErrNoResponder.
+func (c *Client) Handle(handler func(Request) Response) {
+	c.callLock.Lock()
+	c.handler = handler
+	c.callLock.Unlock()
+}
+
+// newCall registers a call under a random token
+func (c *Client) newCall() (uint64, chan Response) {
+	replies := make(chan Response, 1)
+	c.callLock.Lock()
+	defer c.callLock.Unlock()
+	for {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(fmt.Sprintf("reading random token failed, %s", err.Error()))
+		}
+		token := binary.BigEndian.Uint64(b[:])
+		if _, ok := c.calls[token]; token != 0 && !ok {
+			c.calls[token] = replies
+			return token, replies
+		}
+	}
+}
+
+func (c *Client) endCall(token uint64) {
+	c.callLock.Lock()
+	delete(c.calls, token)
+	c.callLock.Unlock()
+}
+
+// handleReply passes a reply on to its call, replies to calls of other sessions of the user
+// or to calls which were given up on are dropped
+func (c *Client) handleReply(relay *messages.Relay) {
+	c.callLock.Lock()
+	replies, ok := c.calls[relay.Reply]
+	delete(c.calls, relay.Reply)
+	c.callLock.Unlock()
+	if !ok {
+		c.logger.Debug("dropping reply to unknown call", zap.Int32("from", relay.From))
+		return
+	}
+	replies <- Response{Body: relay.Body, Error: relay.Error}
+}
+
+// handleCall answers a call with the handler
+func (c *Client) handleCall(relay *messages.Relay) {
+	c.callLock.Lock()
+	handler := c.handler
+	c.callLock.Unlock()
+
+	resp := Response{Error: messages.ReplyNoResponder}
+	if handler != nil {
+		resp = handler(Request{From: relay.From, Body: relay.Body})
+	}
+	if len(resp.Body) > messages.BodyMaxLength {
+		resp.Body = resp.Body[:messages.BodyMaxLength]
+	}
+	if len(resp.Error) > messages.ReplyErrorMaxLength {
+		resp.Error = resp.Error[:messages.ReplyErrorMaxLength]
+	}
+	reply := &messages.RelayRequest{
+		Id:    c.id,
+		Ids:   []int32{relay.From},
+		Body:  resp.Body,
+		Reply: relay.Call,
+		Error: resp.Error,
+	}
+	if err := c.send(reply, messages.MsgTypeRelayRequest); err != nil {
+		c.logger.Error("sending reply failed", zap.Int32("to", relay.From), zap.Error(err))
+	}
+}
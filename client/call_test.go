@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestClient_Request(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   *messages.Relay
+		want    []byte
+		wantErr error
+	}{
+		{"reply", &messages.Relay{From: 123, Body: []byte("pong")}, []byte("pong"), nil},
+		{"responder failed", &messages.Relay{From: 123, Error: "busy"}, nil, ResponseError("busy")},
+		{"no responder", &messages.Relay{Error: messages.ReplyNoResponder}, nil, ErrNoResponder},
+		{"timeout", nil, nil, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			server, client := net.Pipe()
+			c := newTestClient(client)
+			go c.receiveMessages()
+			go func() {
+				b, _, err := messages.Decode(server)
+				if err != nil {
+					return
+				}
+				var req messages.RelayRequest
+				if err := proto.Unmarshal(b, &req); err != nil || tt.reply == nil {
+					return
+				}
+				// a reply to another call is ignored
+				stray, _ := messages.Encode(&messages.Relay{Reply: req.Call + 1, Body: []byte("stray")}, messages.MsgTypeRelay)
+				server.Write(stray)
+				tt.reply.Reply = req.Call
+				reply, _ := messages.Encode(tt.reply, messages.MsgTypeRelay)
+				server.Write(reply)
+			}()
+
+			// act
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			got, err := c.Request(ctx, 123, []byte("ping"))
+
+			// assert
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Request failed. Expected err %v, got %v", tt.wantErr, err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Request failed. Expected %q, got %q", tt.want, got)
+			}
+			if len(c.calls) != 0 {
+				t.Errorf("Request failed. Expected no calls left, got %d", len(c.calls))
+			}
+		})
+	}
+}
+
+func TestClient_Handle(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(Request) Response
+		want    *messages.RelayRequest
+	}{
+		{
+			"handler",
+			func(req Request) Response {
+				return Response{Body: bytes.ToUpper(req.Body)}
+			},
+			&messages.RelayRequest{Ids: []int32{234}, Body: []byte("PING"), Reply: 9},
+		},
+		{
+			"no handler",
+			nil,
+			&messages.RelayRequest{Ids: []int32{234}, Reply: 9, Error: messages.ReplyNoResponder},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			server, client := net.Pipe()
+			c := newTestClient(client)
+			if tt.handler != nil {
+				c.Handle(tt.handler)
+			}
+			go c.receiveMessages()
+
+			// act
+			call := encodeRelay(t, &messages.Relay{From: 234, Body: []byte("ping"), Call: 9})
+			go server.Write(call)
+
+			// assert
+			b, msgType, err := messages.Decode(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msgType != messages.MsgTypeRelayRequest {
+				t.Fatalf("Handle failed. Expected %d, got %d", messages.MsgTypeRelayRequest, msgType)
+			}
+			var result messages.RelayRequest
+			if err := proto.Unmarshal(b, &result); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&result, tt.want) {
+				t.Errorf("Handle failed. Expected %#v, got %#v", tt.want, &result)
+			}
+		})
+	}
+}
+
+func newTestClient(conn net.Conn) *Client {
+	return &Client{
+		conn:           conn,
+		enc:            messages.NewEncoder(conn),
+		logger:         zap.NewNop(),
+		requestTimeout: time.Second,
+		responseChan:   make(chan messageRaw, 1),
+		calls:          make(map[uint64]chan Response),
+		jobs:           make(chan *Job, 1),
+		peerKeys:       make(map[int32]*[keyLen]byte),
+	}
+}
+
+func encodeRelay(t *testing.T, relay *messages.Relay) []byte {
+	t.Helper()
+	b, err := messages.Encode(relay, messages.MsgTypeRelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -1,27 +1,92 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"net"
 	"github.com/antonzhukov/go-tcp-messaging/messages"
 
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"go.uber.org/zap"
 )
 
+// receiptBacklog is the number of receipts waiting to be taken, later ones are dropped
+const receiptBacklog = 64
+
 type Client struct {
 	conn           net.Conn
+	enc            *messages.Encoder
 	responseChan   chan messageRaw
 	requestTimeout time.Duration
 	logger         *zap.Logger
+	// requestLock serializes requests waiting for a response, responses come in order of requests
+	requestLock sync.Mutex
 
 	id         int32
 	identified bool
+	// key is a device key binding the client to a stable id, the hub issues a new id for every session without
+	key string
+	// token is the session token the hub issued for id, another session of id is opened with it
+	token string
+	// codecs are compression codecs offered to the hub in order of preference
+	codecs []string
+	// version and features negotiated by hello, version is 0 with an older hub supporting all
+	// features. helloLock guards them as they are set by the goroutine receiving messages.
+	helloLock sync.Mutex
+	version   uint32
+	features  []string
+
+	// relayLock guards lastMessage, caughtUp and unsent
+	relayLock sync.Mutex
+	// lastMessage is the id of the latest relay received from a hub keeping history
+	lastMessage uint64
+	// caughtUp holds ids of relays received while catching up, nil otherwise
+	caughtUp map[uint64]struct{}
+	// unsent are relays to send again on Reconnect, see RelayOptions.Resend
+	unsent []*messages.RelayRequest
+
+	// lastReceipt is the latest receipt id requested, accessed atomically
+	lastReceipt uint64
+	receipts    chan *messages.RelayReceipt
+
+	// sequences tells gaps and duplicates of relays, nil skips checking them
+	sequences *Sequences
+	gaps      chan Gap
+
+	// callLock guards calls waiting for replies by token and the handler of calls received
+	callLock sync.Mutex
+	calls    map[uint64]chan Response
+	handler  func(Request) Response
+
+	jobs chan *Job
+
+	// keys seal and open relays, nil if the client doesn't seal them. keyLock guards peerKeys,
+	// the public keys pinned for users, and pending, relays waiting for the key of their sender
+	keys     *KeyPair
+	keyLock  sync.Mutex
+	peerKeys map[int32]*[keyLen]byte
+	pending  map[int32][]*messages.Relay
+
+	// writeLock serializes writes of requests and stream acks, and guards conn on Reconnect
+	writeLock sync.Mutex
+	// received is closed when the goroutine receiving messages of conn returns
+	received chan struct{}
+	// lost tells the connection to the hub is gone, see Lost
+	lost chan struct{}
+
+	streamLock sync.Mutex
+	lastStream uint64
+	outgoing   map[uint64]*outStream
+	incoming   map[uint64]*Stream
+	streams    chan *Stream
 }
 
 type messageRaw struct {
@@ -32,24 +97,182 @@ type messageRaw struct {
 func NewClient(logger *zap.Logger, conn net.Conn, requestTimeout time.Duration) *Client {
 	return &Client{
 		conn:           conn,
+		enc:            messages.NewEncoder(conn),
 		responseChan:   make(chan messageRaw, 1),
 		requestTimeout: requestTimeout,
 		logger:         logger,
+		streams:        make(chan *Stream, streamBacklog),
+		receipts:       make(chan *messages.RelayReceipt, receiptBacklog),
+		sequences:      NewSequences(),
+		gaps:           make(chan Gap, gapBacklog),
+		calls:          make(map[uint64]chan Response),
+		jobs:           make(chan *Job, jobBacklog),
+		peerKeys:       make(map[int32]*[keyLen]byte),
+		lost:           make(chan struct{}, 1),
 	}
 }
 
 func (c *Client) Run() error {
 	// start asynchronous receiving messages
+	c.received = make(chan struct{})
 	go c.receiveMessages()
 
-	err := c.authenticate()
+	// identity request follows hello without waiting for welcome,
+	// hubs not knowing hello skip it and the client falls back to protocol version 1
+	err := c.send(messages.NewHello(messages.DefaultMaxFrame), messages.MsgTypeHello)
 	if err != nil {
 		return err
 	}
 
+	err = c.authenticate()
+	if err != nil {
+		return err
+	}
+
+	// peers seal relays with the published key, before catch-up so relays sealed meanwhile open
+	if c.keys != nil {
+		if err := c.publishKey(); err != nil {
+			return fmt.Errorf("publishing key failed: %s", err.Error())
+		}
+	}
+
+	if err := c.resend(); err != nil {
+		return fmt.Errorf("sending relays again failed: %s", err.Error())
+	}
+
+	// relays missed since the last connection
+	if since := c.LastMessage(); since != 0 {
+		if err := c.catchUp(since); err != nil {
+			c.logger.Error("catching up failed", zap.Error(err))
+		}
+	}
+
 	return nil
 }
 
+// Reconnect continues the session on conn after the connection to the hub is lost, relays
+// kept by RelayOptions.Resend are sent again. Requests waiting for a response meanwhile fail.
+func (c *Client) Reconnect(conn net.Conn) error {
+	c.writeLock.Lock()
+	c.conn.Close()
+	c.conn = conn
+	c.enc = messages.NewEncoder(conn)
+	received := c.received
+	c.writeLock.Unlock()
+
+	// responses of the lost connection must not be taken for responses on conn
+	c.requestLock.Lock()
+	c.identified = false
+	for received != nil {
+		select {
+		case <-c.responseChan:
+		case <-received:
+			received = nil
+		}
+	}
+	select {
+	case <-c.responseChan:
+	default:
+	}
+	c.requestLock.Unlock()
+
+	c.helloLock.Lock()
+	c.version, c.features = 0, nil
+	c.helloLock.Unlock()
+
+	return c.Run()
+}
+
+// Lost tells the connection to the hub is gone, the caller passes a new one to Reconnect.
+// It isn't told for connections the hub rejected.
+func (c *Client) Lost() <-chan struct{} {
+	return c.lost
+}
+
+// resend sends relays kept by RelayOptions.Resend again, the hub drops those
+// it got before by their keys
+func (c *Client) resend() error {
+	c.relayLock.Lock()
+	unsent := c.unsent
+	c.unsent = nil
+	c.relayLock.Unlock()
+
+	for i, relayReq := range unsent {
+		if err := c.sendWithin(relayReq, messages.MsgTypeRelayRequest, c.requestTimeout); err != nil {
+			c.relayLock.Lock()
+			c.unsent = append(unsent[i:], c.unsent...)
+			c.relayLock.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+// Resume makes Run catch up on relays received after the relay with id lastMessage,
+// usually the LastMessage of the client of a lost connection to the same hub
+func (c *Client) Resume(lastMessage uint64) {
+	c.relayLock.Lock()
+	c.lastMessage = lastMessage
+	c.relayLock.Unlock()
+}
+
+// ResumeSequences checks relays against sequences of the client of a previous connection,
+// it must be called before Run
+func (c *Client) ResumeSequences(sequences *Sequences) {
+	c.sequences = sequences
+}
+
+// Sequences returns sequence numbers of relays received, see ResumeSequences
+func (c *Client) Sequences() *Sequences {
+	return c.sequences
+}
+
+// Gaps returns runs of relays missed, as told by their sequence numbers
+func (c *Client) Gaps() <-chan Gap {
+	return c.gaps
+}
+
+// LastMessage returns the id of the latest relay received, 0 if the hub keeps no history
+func (c *Client) LastMessage() uint64 {
+	c.relayLock.Lock()
+	defer c.relayLock.Unlock()
+	return c.lastMessage
+}
+
+// catchUp receives all relays kept by the hub since the relay with id since,
+// relays also received live meanwhile are received once
+func (c *Client) catchUp(since uint64) error {
+	c.relayLock.Lock()
+	c.caughtUp = make(map[uint64]struct{})
+	c.relayLock.Unlock()
+	defer func() {
+		c.relayLock.Lock()
+		c.caughtUp = nil
+		c.relayLock.Unlock()
+	}()
+
+	for {
+		entries, next, err := c.CatchUp(since, 0)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			relay := &messages.Relay{Body: entry.Body, MessageId: entry.Id, Timestamp: entry.Timestamp, From: entry.From, Sealed: entry.Sealed}
+			if relay.Sealed {
+				if err := c.open(relay); err != nil {
+					c.logger.Error("opening sealed relay failed", zap.Int32("from", relay.From), zap.Error(err))
+					continue
+				}
+			}
+			c.receiveRelay(relay)
+		}
+		if next == 0 {
+			return nil
+		}
+		since = next
+	}
+}
+
 func (c *Client) authenticate() error {
 	// Get my user ID
 	id, err := c.GetIdentity()
@@ -63,8 +286,13 @@ func (c *Client) authenticate() error {
 	return nil
 }
 
-// GetIdentity passes authentication on hub
+// GetIdentity passes authentication on hub, if the client was given the id of an existing
+// user along with its session token it opens another session (device) of that user.
+// A client with a device key gets the id bound to the key.
 func (c *Client) GetIdentity() (int32, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
 	// only authenticate once
 	if c.identified {
 		return c.id, nil
@@ -72,13 +300,16 @@ func (c *Client) GetIdentity() (int32, error) {
 
 	// send request
 	idReq := &messages.Request{
-		Type: messages.Request_IDENTITY,
+		Id:     c.id,
+		Type:   messages.Request_IDENTITY,
+		Codecs: c.codecs,
+		Key:    c.key,
+		Token:  c.token,
 	}
-	bytes, err := messages.Encode(idReq, messages.MsgTypeRequest)
+	err := c.send(idReq, messages.MsgTypeRequest)
 	if err != nil {
-		return 0, fmt.Errorf("request marshalling failed, %s", err.Error())
+		return 0, err
 	}
-	c.conn.Write(bytes)
 
 	// receive response
 	var msgRaw messageRaw
@@ -88,6 +319,11 @@ func (c *Client) GetIdentity() (int32, error) {
 	case msgRaw = <-c.responseChan:
 	}
 
+	if msgRaw.msgType == messages.MsgTypeWelcome {
+		var welcome messages.Welcome
+		proto.Unmarshal(msgRaw.msg, &welcome)
+		return 0, fmt.Errorf("hub rejected connection: %s", welcome.Reason)
+	}
 	if msgRaw.msgType != messages.MsgTypeIdentityResponse {
 		return 0, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeIdentityResponse, msgRaw.msgType)
 	}
@@ -97,21 +333,144 @@ func (c *Client) GetIdentity() (int32, error) {
 		return 0, fmt.Errorf("unmarshal failed: %s", err.Error())
 	}
 
+	// compress requests with the codec chosen by hub
+	if idResp.Codec != "" {
+		codec := messages.LookupCodec(idResp.Codec)
+		if codec == nil {
+			return 0, fmt.Errorf("hub chose unknown codec %q", idResp.Codec)
+		}
+		c.writeLock.Lock()
+		c.enc.SetCodec(codec)
+		c.writeLock.Unlock()
+	}
+
+	c.token = idResp.Token
 	return idResp.Id, nil
 }
 
+// Token returns the session token opening other sessions of the user along with its id,
+// it is empty for clients with a device key
+func (c *Client) Token() string {
+	return c.token
+}
+
 // ListUsers returns list of currently active users
 func (c *Client) ListUsers() ([]int32, error) {
-	// send request
-	listReq := &messages.Request{
-		Id:   c.id,
-		Type: messages.Request_LIST,
+	var ids []int32
+	pages := c.UserPages(0, false)
+	for pages.Next() {
+		ids = append(ids, pages.IDs()...)
+	}
+	if pages.Err() != nil {
+		return nil, pages.Err()
 	}
-	bytes, err := messages.Encode(listReq, messages.MsgTypeRequest)
+
+	return ids, nil
+}
+
+// ListUserDevices returns currently active users along with the number of their connected devices
+func (c *Client) ListUserDevices() (map[int32]int32, error) {
+	devices := make(map[int32]int32)
+	pages := c.UserPages(0, true)
+	for pages.Next() {
+		for i, id := range pages.IDs() {
+			devices[id] = pages.Devices()[i]
+		}
+	}
+	if pages.Err() != nil {
+		return nil, pages.Err()
+	}
+	return devices, nil
+}
+
+// CountUsers returns the number of currently active users without listing them
+func (c *Client) CountUsers() (int32, error) {
+	listResp, err := c.listRequest(&messages.Request{
+		Type:      messages.Request_LIST,
+		CountOnly: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return listResp.Total, nil
+}
+
+// UserPages iterates over currently active users a page at a time:
+//
+//	pages := c.UserPages(100, false)
+//	for pages.Next() {
+//		fmt.Println(pages.IDs())
+//	}
+//	if err := pages.Err(); err != nil {
+//		...
+//	}
+type UserPages struct {
+	client *Client
+	req    messages.Request
+	resp   *messages.ListResponse
+	done   bool
+	err    error
+}
+
+// UserPages lists active users in pages of pageSize ids ordered by id, 0 lets the hub
+// pick the largest page. Device counts are listed along if devices is set.
+func (c *Client) UserPages(pageSize int, devices bool) *UserPages {
+	return &UserPages{
+		client: c,
+		req: messages.Request{
+			Type:    messages.Request_LIST,
+			Devices: devices,
+			Limit:   uint32(pageSize),
+		},
+	}
+}
+
+// Next requests the next page and reports whether there is one
+func (p *UserPages) Next() bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	resp, err := p.client.listRequest(&p.req)
 	if err != nil {
-		return nil, fmt.Errorf("request marshalling failed, %s", err.Error())
+		p.err = err
+		return false
+	}
+	if p.req.Devices && len(resp.Devices) != len(resp.Ids) {
+		p.err = fmt.Errorf("bad response, got %d device counts for %d users", len(resp.Devices), len(resp.Ids))
+		return false
+	}
+	p.resp = resp
+	p.req.After = resp.Next
+	p.done = resp.Next == 0
+	return true
+}
+
+// IDs returns the users of the current page
+func (p *UserPages) IDs() []int32 {
+	return p.resp.GetIds()
+}
+
+// Devices returns the number of sessions of IDs()[i] if requested
+func (p *UserPages) Devices() []int32 {
+	return p.resp.GetDevices()
+}
+
+// Err returns the error which stopped the iteration
+func (p *UserPages) Err() error {
+	return p.err
+}
+
+func (c *Client) listRequest(listReq *messages.Request) (*messages.ListResponse, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	listReq.Id = c.id
+	err := c.send(listReq, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, err
 	}
-	c.conn.Write(bytes)
 
 	// receive response
 	var msgRaw messageRaw
@@ -130,11 +489,260 @@ func (c *Client) ListUsers() ([]int32, error) {
 		return nil, fmt.Errorf("unmarshal failed: %s", err.Error())
 	}
 
-	return listResp.Ids, nil
+	return &listResp, nil
+}
+
+// BlockUsers stops given users from relaying to this client and returns the updated block list
+func (c *Client) BlockUsers(ids []int32) ([]int32, error) {
+	return c.blockRequest(messages.Request_BLOCK, ids)
+}
+
+// UnblockUsers removes given users from the block list and returns the updated block list
+func (c *Client) UnblockUsers(ids []int32) ([]int32, error) {
+	return c.blockRequest(messages.Request_UNBLOCK, ids)
+}
+
+// BlockedUsers returns the block list
+func (c *Client) BlockedUsers() ([]int32, error) {
+	return c.blockRequest(messages.Request_BLOCK_LIST, nil)
+}
+
+func (c *Client) blockRequest(reqType messages.Request_Type, ids []int32) ([]int32, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	blockReq := &messages.Request{
+		Id:   c.id,
+		Type: reqType,
+		Ids:  ids,
+	}
+	err := c.send(blockReq, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// receive response
+	var msgRaw messageRaw
+	select {
+	case <-time.After(c.requestTimeout):
+		return nil, errors.New("block request timed out")
+	case msgRaw = <-c.responseChan:
+	}
+
+	if msgRaw.msgType != messages.MsgTypeBlockListResponse {
+		return nil, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeBlockListResponse, msgRaw.msgType)
+	}
+	var blockResp messages.BlockListResponse
+	err = proto.Unmarshal(msgRaw.msg, &blockResp)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	return blockResp.Ids, nil
+}
+
+// SetProfile stores the display name and metadata of this client's user
+func (c *Client) SetProfile(name string, metadata map[string]string) (*messages.Profile, error) {
+	profile := &messages.Profile{Name: name}
+	for key, value := range metadata {
+		profile.Metadata = append(profile.Metadata, &messages.Attribute{Key: key, Value: value})
+	}
+	sort.Slice(profile.Metadata, func(i, j int) bool { return profile.Metadata[i].Key < profile.Metadata[j].Key })
+
+	profiles, err := c.profileRequest(&messages.Request{
+		Id:      c.id,
+		Type:    messages.Request_PROFILE,
+		Profile: profile,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) != 1 {
+		return nil, fmt.Errorf("bad response, got %d profiles", len(profiles))
+	}
+	return profiles[0], nil
+}
+
+// LookupUsers returns profiles of given users, unknown users are left out
+func (c *Client) LookupUsers(ids []int32) ([]*messages.Profile, error) {
+	return c.profileRequest(&messages.Request{
+		Id:   c.id,
+		Type: messages.Request_LOOKUP,
+		Ids:  ids,
+	})
+}
+
+func (c *Client) profileRequest(req *messages.Request) ([]*messages.Profile, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	err := c.send(req, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// receive response
+	var msgRaw messageRaw
+	select {
+	case <-time.After(c.requestTimeout):
+		return nil, errors.New("profile request timed out")
+	case msgRaw = <-c.responseChan:
+	}
+
+	if msgRaw.msgType != messages.MsgTypeProfileResponse {
+		return nil, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeProfileResponse, msgRaw.msgType)
+	}
+	var profileResp messages.ProfileResponse
+	err = proto.Unmarshal(msgRaw.msg, &profileResp)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	return profileResp.Profiles, nil
+}
+
+// FindUsers returns a page of users matching query and the cursor of the next page,
+// which is 0 on the last page
+func (c *Client) FindUsers(query *messages.DirectoryQuery) ([]*messages.UserRecord, int32, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	dirReq := &messages.Request{
+		Id:    c.id,
+		Type:  messages.Request_DIRECTORY,
+		Query: query,
+	}
+	err := c.send(dirReq, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// receive response
+	var msgRaw messageRaw
+	select {
+	case <-time.After(c.requestTimeout):
+		return nil, 0, errors.New("directory request timed out")
+	case msgRaw = <-c.responseChan:
+	}
+
+	if msgRaw.msgType != messages.MsgTypeDirectoryResponse {
+		return nil, 0, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeDirectoryResponse, msgRaw.msgType)
+	}
+	var dirResp messages.DirectoryResponse
+	err = proto.Unmarshal(msgRaw.msg, &dirResp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	return dirResp.Users, dirResp.Next, nil
+}
+
+// History returns a page of relays exchanged with peer before the relay with id before,
+// or the newest ones if before is 0, oldest first. next is the cursor of the older page,
+// 0 if there is none. A limit of 0 lets the hub pick the page size.
+func (c *Client) History(peer int32, before uint64, limit int) ([]*messages.HistoryEntry, uint64, error) {
+	return c.historyRequest(&messages.Request{
+		Type:   messages.Request_HISTORY,
+		Peer:   peer,
+		Before: before,
+		Limit:  uint32(limit),
+	})
+}
+
+// GroupHistory returns a page of relays of the conversation with several users like History,
+// relays sent to all of them and the client at once are kept in it
+func (c *Client) GroupHistory(ids []int32, before uint64, limit int) ([]*messages.HistoryEntry, uint64, error) {
+	return c.historyRequest(&messages.Request{
+		Type:   messages.Request_HISTORY,
+		Ids:    ids,
+		Before: before,
+		Limit:  uint32(limit),
+	})
+}
+
+// CatchUp returns a page of relays received after the relay with id since, oldest first.
+// next is the cursor of the following page, 0 if there is none.
+func (c *Client) CatchUp(since uint64, limit int) ([]*messages.HistoryEntry, uint64, error) {
+	return c.historyRequest(&messages.Request{
+		Type:  messages.Request_CATCH_UP,
+		Since: since,
+		Limit: uint32(limit),
+	})
+}
+
+func (c *Client) historyRequest(historyReq *messages.Request) ([]*messages.HistoryEntry, uint64, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	historyReq.Id = c.id
+	err := c.send(historyReq, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// receive response
+	var msgRaw messageRaw
+	select {
+	case <-time.After(c.requestTimeout):
+		return nil, 0, errors.New("history request timed out")
+	case msgRaw = <-c.responseChan:
+	}
+
+	if msgRaw.msgType != messages.MsgTypeHistoryResponse {
+		return nil, 0, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeHistoryResponse, msgRaw.msgType)
+	}
+	var historyResp messages.HistoryResponse
+	err = proto.Unmarshal(msgRaw.msg, &historyResp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	return historyResp.Entries, historyResp.Next, nil
+}
+
+// RelayOptions change how the hub delivers a relay
+type RelayOptions struct {
+	// TTL drops the relay if it isn't delivered in time, rounded up to milliseconds, 0 never expires
+	TTL time.Duration
+	// Receipt asks the hub for a receipt from every receiver the relay is delivered to or expires for
+	Receipt bool
+	// Priority picks the lane of the relay in outbound queues of receivers
+	Priority messages.RelayRequest_Priority
+	// Key makes the hub drop repeats of the relay, see NewRelayKey. Pass the same key to send
+	// the relay again after a reconnect.
+	Key string
+	// Resend keeps the relay if it isn't written within the request timeout for Reconnect
+	// to send it again with the same key, a key is made up for the relay if it has none
+	Resend bool
+	// Group relays to a single member of the queue group instead of ids, see JoinGroup
+	Group string
+
+	// sealed is set by RelaySealed
+	sealed bool
+}
+
+// NewRelayKey returns a random idempotency key
+func NewRelayKey() string {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		panic(fmt.Sprintf("reading random key failed, %s", err.Error()))
+	}
+	return hex.EncodeToString(key[:])
 }
 
 // RelayRequest relays a message to other users
 func (c *Client) RelayRequest(ids []int32, body []byte) error {
+	_, err := c.RelayWithOptions(ids, body, RelayOptions{})
+	return err
+}
+
+// RelayWithOptions relays a message to other users and returns the id of its receipts
+// if they were asked for, see Receipts
+func (c *Client) RelayWithOptions(ids []int32, body []byte, opts RelayOptions) (uint64, error) {
 	if len(body) > messages.BodyMaxLength {
 		body = body[:messages.BodyMaxLength]
 	}
@@ -143,43 +751,174 @@ func (c *Client) RelayRequest(ids []int32, body []byte) error {
 		ids = ids[:messages.MaxReceivers]
 	}
 	relayReq := &messages.RelayRequest{
-		Id:   c.id,
-		Ids:  ids,
-		Body: body,
+		Id:       c.id,
+		Ids:      ids,
+		Body:     body,
+		Ttl:      uint32((opts.TTL + time.Millisecond - 1) / time.Millisecond),
+		Priority: opts.Priority,
+		Group:    opts.Group,
+		Sealed:   opts.sealed,
 	}
-	bytes, err := messages.Encode(relayReq, messages.MsgTypeRelayRequest)
-	if err != nil {
-		return fmt.Errorf("request marshalling failed, %s", err.Error())
+	if opts.Receipt {
+		relayReq.Receipt = atomic.AddUint64(&c.lastReceipt, 1)
+	}
+	if opts.Resend && opts.Key == "" {
+		opts.Key = NewRelayKey()
+	}
+	relayReq.IdempotencyKey = opts.Key
+
+	var timeout time.Duration
+	if opts.Resend {
+		timeout = c.requestTimeout
 	}
-	c.conn.Write(bytes)
+	if err := c.sendWithin(relayReq, messages.MsgTypeRelayRequest, timeout); err != nil {
+		// the hub may have got the relay before the connection broke, the resent
+		// relay carries the same key, so the hub relays at most one of them
+		if opts.Resend && !errors.Is(err, messages.ErrFrameTooLarge) {
+			c.relayLock.Lock()
+			c.unsent = append(c.unsent, relayReq)
+			c.relayLock.Unlock()
+		}
+		return 0, err
+	}
+	return relayReq.Receipt, nil
+}
 
+// Receipts returns receipts of relays sent with RelayOptions.Receipt
+func (c *Client) Receipts() <-chan *messages.RelayReceipt {
+	return c.receipts
+}
+
+// send writes a message to hub
+func (c *Client) send(msg messages.Message, msgType messages.MsgType) error {
+	return c.sendWithin(msg, msgType, 0)
+}
+
+// sendWithin writes a message to hub and fails if it isn't written within timeout, 0 waits as long as it takes
+func (c *Client) sendWithin(msg messages.Message, msgType messages.MsgType, timeout time.Duration) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+
+	err := c.enc.Encode(msg, msgType)
+	if errors.Is(err, messages.ErrFrameTooLarge) {
+		return fmt.Errorf("request marshalling failed, %w", err)
+	}
+	if err == nil {
+		err = c.enc.Flush()
+	}
+	if err != nil {
+		// the hub may have got part of a frame, nothing written after it would be understood
+		c.conn.Close()
+		return fmt.Errorf("request sending failed, %s", err.Error())
+	}
 	return nil
 }
 
+// supports reports whether the hub negotiated the feature, older hubs support all of them
+func (c *Client) supports(feature string) bool {
+	c.helloLock.Lock()
+	defer c.helloLock.Unlock()
+	return c.version == 0 || messages.HasFeature(c.features, feature)
+}
+
 func (c *Client) receiveMessages() {
-	bufReader := bufio.NewReader(c.conn)
+	c.writeLock.Lock()
+	conn, received := c.conn, c.received
+	c.writeLock.Unlock()
+	if received != nil {
+		defer close(received)
+	}
 
+	dec := messages.NewDecoder(conn)
+	dec.SetMaxFrame(messages.DefaultMaxFrame)
+
+	var rejected bool
 	for {
-		bytes, msgType, err := messages.Decode(bufReader)
-		if err == io.EOF {
-			panic("connection lost")
+		bytes, msgType, err := dec.Decode()
+		if err == io.EOF && rejected {
+			return
+		}
+		if errors.Is(err, messages.ErrDecompress) {
+			c.logger.Error("decompressing message failed", zap.Stringer("type", msgType), zap.Error(err))
+			continue
 		}
 		if err != nil {
-			c.logger.Error("receiving message failed", zap.Error(err))
+			c.lose(conn, err)
 			return
 		}
 
 		switch msgType {
 		case messages.MsgTypeRelay:
 			c.handleRelay(bytes)
+		case messages.MsgTypeRelayReceipt:
+			c.handleReceipt(bytes)
+		case messages.MsgTypeStreamOpen, messages.MsgTypeStreamChunk, messages.MsgTypeStreamEnd,
+			messages.MsgTypeStreamAbort, messages.MsgTypeStreamAck:
+			c.handleStream(msgType, bytes)
 		case messages.MsgTypeUnknown:
 			c.logger.Info("received unknown message, skipping")
+		case messages.MsgTypeWelcome:
+			// welcome precedes the identity response, so the negotiated features
+			// are known by the time GetIdentity returns
+			var welcome messages.Welcome
+			if err := proto.Unmarshal(bytes, &welcome); err != nil {
+				c.logger.Error("Unmarshal failed", zap.Error(err))
+				continue
+			}
+			if welcome.Version < messages.MinProtocolVersion && welcome.Reason == "" {
+				welcome.Reason = fmt.Sprintf("unsupported protocol version %d", welcome.Version)
+			}
+			if welcome.Reason != "" {
+				rejected = true
+				bytes, _ = welcome.Marshal()
+				c.responseChan <- messageRaw{msg: bytes, msgType: msgType}
+				continue
+			}
+			c.helloLock.Lock()
+			c.version = welcome.Version
+			c.features = welcome.Features
+			c.helloLock.Unlock()
+			c.writeLock.Lock()
+			c.enc.SetMaxFrame(int(welcome.MaxFrame))
+			c.writeLock.Unlock()
+			c.logger.Info("handshake passed", zap.Uint32("version", welcome.Version), zap.Strings("features", welcome.Features))
+		case messages.MsgTypeIdentityResponse:
+			// hub compresses frames following the response with the chosen codec
+			var idResp messages.IdentityResponse
+			if err := proto.Unmarshal(bytes, &idResp); err == nil && idResp.Codec != "" {
+				dec.SetCodec(messages.LookupCodec(idResp.Codec))
+			}
+			c.responseChan <- messageRaw{msg: bytes, msgType: msgType}
 		default:
 			c.responseChan <- messageRaw{msg: bytes, msgType: msgType}
 		}
 	}
 }
 
+// lose tells the caller watching Lost that conn is gone, unless Reconnect replaced it already
+func (c *Client) lose(conn net.Conn, err error) {
+	c.writeLock.Lock()
+	replaced := c.conn != conn
+	c.writeLock.Unlock()
+	if replaced {
+		return
+	}
+
+	if err == io.EOF {
+		c.logger.Info("connection lost")
+	} else {
+		c.logger.Error("receiving message failed", zap.Error(err))
+	}
+	select {
+	case c.lost <- struct{}{}:
+	default:
+	}
+}
+
 func (c *Client) handleRelay(bytes []byte) {
 	// decode relay
 	var relay messages.Relay
@@ -188,5 +927,73 @@ func (c *Client) handleRelay(bytes []byte) {
 		c.logger.Error("Unmarshal failed", zap.Error(err))
 		return
 	}
-	c.logger.Info("relay message", zap.ByteString("body", relay.Body))
+	// relays of a sender wait while its key is looked up, so they are received in order
+	if c.deferRelay(&relay) {
+		return
+	}
+	// a sealed relay is opened and verified against the key of the sender
+	if relay.Sealed {
+		if err := c.open(&relay); err != nil {
+			c.logger.Error("opening sealed relay failed", zap.Int32("from", relay.From), zap.Error(err))
+			return
+		}
+	}
+	c.receiveRelay(&relay)
+}
+
+func (c *Client) handleReceipt(bytes []byte) {
+	receipt := &messages.RelayReceipt{}
+	if err := proto.Unmarshal(bytes, receipt); err != nil {
+		c.logger.Error("Unmarshal failed", zap.Error(err))
+		return
+	}
+	select {
+	case c.receipts <- receipt:
+	default:
+		c.logger.Info("receipt backlog full, dropping receipt", zap.Uint64("receipt", receipt.Receipt))
+	}
+}
+
+// receiveRelay handles a relay received live or while catching up
+func (c *Client) receiveRelay(relay *messages.Relay) {
+	if relay.MessageId != 0 {
+		c.relayLock.Lock()
+		if c.caughtUp != nil {
+			if _, ok := c.caughtUp[relay.MessageId]; ok {
+				c.relayLock.Unlock()
+				return
+			}
+			c.caughtUp[relay.MessageId] = struct{}{}
+		}
+		if relay.MessageId > c.lastMessage {
+			c.lastMessage = relay.MessageId
+		}
+		c.relayLock.Unlock()
+	}
+	if c.sequences != nil && relay.Sequence != 0 {
+		gap, duplicate := c.sequences.Check(relay)
+		if duplicate {
+			c.logger.Info("dropping duplicate relay", zap.Int32("from", relay.From), zap.Uint64("sequence", relay.Sequence))
+			return
+		}
+		if gap != nil {
+			select {
+			case c.gaps <- *gap:
+			default:
+				c.logger.Info("gap backlog full, dropping gap", zap.Int32("from", gap.From))
+			}
+		}
+	}
+	switch {
+	case relay.Job != 0:
+		c.handleJob(relay)
+		return
+	case relay.Reply != 0:
+		c.handleReply(relay)
+		return
+	case relay.Call != 0:
+		go c.handleCall(relay)
+		return
+	}
+	c.logger.Info("relay message", zap.Int32("from", relay.From), zap.ByteString("body", relay.Body), zap.Uint64("message_id", relay.MessageId))
 }
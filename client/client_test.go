@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"net"
 	"reflect"
 	"testing"
+	"time"
 	"github.com/antonzhukov/go-tcp-messaging/messages"
 
 	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
 )
 
 func TestClient_receiveMessages(t *testing.T) {
@@ -49,8 +54,10 @@ func TestClient_GetIdentity(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	c := &Client{
-		conn:         client,
-		responseChan: make(chan messageRaw, 1),
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
 	}
 	resultChan := make(chan int32)
 
@@ -87,7 +94,8 @@ func TestClient_GetIdentity(t *testing.T) {
 
 	// assert user id
 	response := &messages.IdentityResponse{
-		Id: 123,
+		Id:    123,
+		Token: "0123abcd",
 	}
 	bytes, err = response.Marshal()
 	if err != nil {
@@ -99,14 +107,19 @@ func TestClient_GetIdentity(t *testing.T) {
 	if id != 123 {
 		t.Errorf("GetIdentity failed. Expected %d, got %d", 123, id)
 	}
+	if c.Token() != "0123abcd" {
+		t.Errorf("GetIdentity failed. Expected the session token kept, got %q", c.Token())
+	}
 }
 
 func TestClient_ListUsers(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	c := &Client{
-		conn:         client,
-		responseChan: make(chan messageRaw, 1),
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
 	}
 	resultChan := make(chan []int32)
 
@@ -157,11 +170,165 @@ func TestClient_ListUsers(t *testing.T) {
 	}
 }
 
+func TestClient_ListUserDevices(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := &Client{
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
+	}
+	resultChan := make(chan map[int32]int32)
+
+	// act
+	go func(resultChan chan map[int32]int32) {
+		res, err := c.ListUserDevices()
+		if err != nil {
+			t.Error(err)
+		}
+		resultChan <- res
+	}(resultChan)
+
+	// assert request
+	bytes, _, err := messages.Decode(server)
+	if err != nil {
+		t.Errorf("ListUserDevices failed. Unexpected err: %s", err.Error())
+	}
+	var result messages.Request
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	expectedReq := messages.Request{
+		Type:    messages.Request_LIST,
+		Devices: true,
+	}
+	if !reflect.DeepEqual(result, expectedReq) {
+		t.Errorf("ListUserDevices failed. Expected %#v, got %#v", expectedReq, result)
+	}
+
+	// assert device counts
+	response := &messages.ListResponse{
+		Ids:     []int32{123, 456},
+		Devices: []int32{2, 1},
+	}
+	bytes, err = response.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+	c.responseChan <- messageRaw{bytes, messages.MsgTypeListResponse}
+	devices := <-resultChan
+
+	expected := map[int32]int32{123: 2, 456: 1}
+	if !reflect.DeepEqual(expected, devices) {
+		t.Errorf("ListUserDevices failed. Expected %#v, got %#v", expected, devices)
+	}
+}
+
+func TestClient_UserPages(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := &Client{
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
+	}
+	responses := []*messages.ListResponse{
+		{Ids: []int32{123, 456}, Next: 456},
+		{Ids: []int32{789}},
+	}
+	requests := make(chan messages.Request, len(responses))
+	go func() {
+		for _, response := range responses {
+			bytes, _, err := messages.Decode(server)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var request messages.Request
+			proto.Unmarshal(bytes, &request)
+			requests <- request
+			bytes, _ = response.Marshal()
+			c.responseChan <- messageRaw{bytes, messages.MsgTypeListResponse}
+		}
+	}()
+
+	// act
+	var pages [][]int32
+	it := c.UserPages(2, false)
+	for it.Next() {
+		pages = append(pages, it.IDs())
+	}
+
+	// assert
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	expected := [][]int32{{123, 456}, {789}}
+	if !reflect.DeepEqual(expected, pages) {
+		t.Errorf("UserPages failed. Expected %#v, got %#v", expected, pages)
+	}
+	first, second := <-requests, <-requests
+	if first.Limit != 2 || first.After != 0 || second.After != 456 {
+		t.Errorf("UserPages failed. Unexpected requests %#v, %#v", first, second)
+	}
+}
+
+func TestClient_catchUp(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := &Client{
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
+		logger:         zap.NewNop(),
+	}
+	c.Resume(5)
+	responses := []*messages.HistoryResponse{
+		{Entries: []*messages.HistoryEntry{{Id: 6, Body: []byte("a")}}, Next: 6},
+		{Entries: []*messages.HistoryEntry{{Id: 9, Body: []byte("b")}}},
+	}
+	requests := make(chan messages.Request, len(responses))
+	go func() {
+		for _, response := range responses {
+			bytes, _, err := messages.Decode(server)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var request messages.Request
+			proto.Unmarshal(bytes, &request)
+			requests <- request
+			bytes, _ = response.Marshal()
+			c.responseChan <- messageRaw{bytes, messages.MsgTypeHistoryResponse}
+		}
+	}()
+
+	// act
+	err := c.catchUp(c.LastMessage())
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-requests, <-requests
+	if first.Type != messages.Request_CATCH_UP || first.Since != 5 || second.Since != 6 {
+		t.Errorf("catchUp failed. Unexpected requests %#v, %#v", first, second)
+	}
+	if c.LastMessage() != 9 {
+		t.Errorf("catchUp failed. Expected last message %d, got %d", 9, c.LastMessage())
+	}
+}
+
 func TestClient_RelayRequest(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	c := &Client{
 		conn: client,
+		enc:  messages.NewEncoder(client),
 	}
 
 	// act
@@ -192,3 +359,366 @@ func TestClient_RelayRequest(t *testing.T) {
 		t.Errorf("RelayRequest failed. Expected %#v, got %#v", expectedReq, result)
 	}
 }
+
+func TestClient_RelayWithOptions(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := &Client{
+		conn:     client,
+		enc:      messages.NewEncoder(client),
+		logger:   zap.NewNop(),
+		receipts: make(chan *messages.RelayReceipt, 1),
+	}
+	go c.receiveMessages()
+
+	// act
+	go c.RelayWithOptions([]int32{123}, []byte("g'day"), RelayOptions{TTL: 1500 * time.Microsecond, Receipt: true, Priority: messages.RelayRequest_HIGH})
+	bytes, _, err := messages.Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := &messages.RelayReceipt{Receipt: 1, Id: 123, Status: messages.RelayReceipt_EXPIRED}
+	frame, err := messages.Encode(receipt, messages.MsgTypeRelayReceipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Write(frame)
+
+	// assert
+	var result messages.RelayRequest
+	if err := proto.Unmarshal(bytes, &result); err != nil {
+		t.Fatal(err)
+	}
+	expectedReq := messages.RelayRequest{Ids: []int32{123}, Body: []byte("g'day"), Ttl: 2, Receipt: 1, Priority: messages.RelayRequest_HIGH}
+	if !reflect.DeepEqual(result, expectedReq) {
+		t.Errorf("RelayWithOptions failed. Expected %#v, got %#v", expectedReq, result)
+	}
+	if got := <-c.Receipts(); !reflect.DeepEqual(got, receipt) {
+		t.Errorf("Receipts failed. Expected %#v, got %#v", receipt, got)
+	}
+}
+
+func TestClient_RelayWithOptions_resend(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	conn := &flakyConn{Conn: client, failures: 1}
+	c := &Client{
+		conn:           conn,
+		enc:            messages.NewEncoder(conn),
+		logger:         zap.NewNop(),
+		requestTimeout: time.Second,
+	}
+
+	// act
+	_, err := c.RelayWithOptions([]int32{123}, []byte("g'day"), RelayOptions{Resend: true})
+
+	// assert, the connection is closed after the partial frame
+	if err == nil {
+		t.Fatal("RelayWithOptions failed. Expected error")
+	}
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("RelayWithOptions failed. Expected connection closed, got %v", err)
+	}
+	// the relay is sent again on the next connection
+	server, client = net.Pipe()
+	c.conn, c.enc = client, messages.NewEncoder(client)
+	received := make(chan []byte, 1)
+	go func() {
+		bytes, _, _ := messages.Decode(server)
+		received <- bytes
+	}()
+	if err := c.resend(); err != nil {
+		t.Fatal(err)
+	}
+	var failed, result messages.RelayRequest
+	if err := proto.Unmarshal(conn.failed[messages.HeaderLen:], &failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := proto.Unmarshal(<-received, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.IdempotencyKey == "" || result.IdempotencyKey != failed.IdempotencyKey || string(result.Body) != "g'day" {
+		t.Errorf("resend failed. Expected the relay with key %q, got %#v", failed.IdempotencyKey, result)
+	}
+	if len(c.unsent) != 0 {
+		t.Errorf("resend failed. Expected no relays kept, got %d", len(c.unsent))
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := NewClient(zap.NewNop(), client, time.Second)
+	go serveIdentity(t, server, 7)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// a response left over by the lost connection
+	stale, err := messages.Encode(&messages.IdentityResponse{Id: 7}, messages.MsgTypeIdentityResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Write(stale)
+	server.Close()
+	select {
+	case <-c.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Lost failed. Expected the connection lost")
+	}
+	for _, body := range []string{"g'day", "cheers"} {
+		if _, err := c.RelayWithOptions([]int32{123}, []byte(body), RelayOptions{Resend: true}); err == nil {
+			t.Fatal("RelayWithOptions failed. Expected error")
+		}
+	}
+	keys := map[string]string{}
+	for _, relayReq := range c.unsent {
+		keys[relayReq.IdempotencyKey] = string(relayReq.Body)
+	}
+
+	// act
+	server, client = net.Pipe()
+	received := make(chan []*messages.RelayRequest, 1)
+	go func() {
+		serveIdentity(t, server, 8)
+		var relays []*messages.RelayRequest
+		for {
+			bytes, msgType, err := messages.Decode(server)
+			if err != nil {
+				received <- relays
+				return
+			}
+			var relayReq messages.RelayRequest
+			if msgType == messages.MsgTypeRelayRequest && proto.Unmarshal(bytes, &relayReq) == nil {
+				relays = append(relays, &relayReq)
+			}
+		}
+	}()
+	err = c.Reconnect(client)
+	server.Close()
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.id != 8 {
+		t.Errorf("Reconnect failed. Expected id %d, got %d", 8, c.id)
+	}
+	relays := <-received
+	if len(keys) != 2 || len(relays) != len(keys) {
+		t.Fatalf("Reconnect failed. Expected %d relays, got %d", len(keys), len(relays))
+	}
+	for _, relayReq := range relays {
+		body, ok := keys[relayReq.IdempotencyKey]
+		if !ok || body != string(relayReq.Body) {
+			t.Errorf("Reconnect failed. Unexpected relay %#v", relayReq)
+		}
+		delete(keys, relayReq.IdempotencyKey)
+	}
+}
+
+// serveIdentity reads hello and the identity request of a client as a hub without hello does
+// and gives it id
+func serveIdentity(t *testing.T, server net.Conn, id int32) {
+	for _, expected := range []messages.MsgType{messages.MsgTypeHello, messages.MsgTypeRequest} {
+		if _, msgType, err := messages.Decode(server); err != nil || msgType != expected {
+			t.Errorf("serveIdentity failed. Expected %d, got %d, %v", expected, msgType, err)
+			return
+		}
+	}
+	resp, err := messages.Encode(&messages.IdentityResponse{Id: id}, messages.MsgTypeIdentityResponse)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server.Write(resp)
+}
+
+func TestClient_supports_welcome(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := newTestClient(client)
+	go c.receiveMessages()
+	welcome, err := messages.Encode(&messages.Welcome{Version: messages.ProtocolVersion, MaxFrame: 1024}, messages.MsgTypeWelcome)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act, features are asked for while the welcome is received
+	go server.Write(welcome)
+	deadline := time.Now().Add(time.Second)
+	for c.supports(messages.FeatureStreams) {
+		if time.Now().After(deadline) {
+			t.Fatal("supports failed. Expected the welcome without features received")
+		}
+	}
+}
+
+// flakyConn fails writing the payload of the first frames and keeps what it was given
+type flakyConn struct {
+	net.Conn
+	failures int
+	failed   []byte
+}
+
+func (c *flakyConn) Write(b []byte) (int, error) {
+	if c.failures == 0 {
+		return c.Conn.Write(b)
+	}
+	c.failed = append(c.failed, b...)
+	if len(c.failed) <= messages.HeaderLen {
+		return len(b), nil
+	}
+	c.failures--
+	return 0, errors.New("connection reset")
+}
+
+func TestClient_BlockUsers(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := &Client{
+		conn:           client,
+		enc:            messages.NewEncoder(client),
+		responseChan:   make(chan messageRaw, 1),
+		requestTimeout: time.Second,
+	}
+	resultChan := make(chan []int32)
+
+	// act
+	go func(resultChan chan []int32) {
+		res, err := c.BlockUsers([]int32{123})
+		if err != nil {
+			t.Error(err)
+		}
+		resultChan <- res
+	}(resultChan)
+
+	// assert request
+	bytes, msgType, err := messages.Decode(server)
+	if err != nil {
+		t.Errorf("BlockUsers failed. Unexpected err: %s", err.Error())
+	}
+	var result messages.Request
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if msgType != messages.MsgTypeRequest {
+		t.Errorf("BlockUsers failed. Expected %d, got %d", messages.MsgTypeRequest, msgType)
+	}
+
+	expectedReq := messages.Request{
+		Type: messages.Request_BLOCK,
+		Ids:  []int32{123},
+	}
+	if !reflect.DeepEqual(result, expectedReq) {
+		t.Errorf("BlockUsers failed. Expected %#v, got %#v", expectedReq, result)
+	}
+
+	// assert block list
+	response := &messages.BlockListResponse{
+		Ids: []int32{123},
+	}
+	bytes, err = response.Marshal()
+	if err != nil {
+		t.Error(err)
+	}
+	c.responseChan <- messageRaw{bytes, messages.MsgTypeBlockListResponse}
+	ids := <-resultChan
+
+	if !reflect.DeepEqual(response.Ids, ids) {
+		t.Errorf("BlockUsers failed. Expected %#v, got %#v", response.Ids, ids)
+	}
+}
+
+func TestClient_GetIdentity_compression(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := NewClient(zap.NewNop(), client, time.Second)
+	c.codecs = []string{"gzip", "deflate"}
+	go c.receiveMessages()
+	errChan := make(chan error)
+
+	// act
+	go func() {
+		_, err := c.GetIdentity()
+		if err == nil {
+			err = c.RelayRequest([]int32{456}, bytes.Repeat([]byte("g'day "), 200))
+		}
+		errChan <- err
+	}()
+
+	// assert
+	payload, _, err := messages.Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request messages.Request
+	err = proto.Unmarshal(payload, &request)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(request.Codecs, c.codecs) {
+		t.Errorf("GetIdentity failed. Expected codecs %v, got %v", c.codecs, request.Codecs)
+	}
+	response, err := messages.Encode(&messages.IdentityResponse{Id: 123, Codec: "gzip"}, messages.MsgTypeIdentityResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Write(response)
+
+	frame, err := messages.DecodeFrame(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer frame.Release()
+	if frame.Type() != messages.MsgTypeRelayRequest || !frame.Compressed() {
+		t.Errorf("RelayRequest failed. Expected compressed relay request, got %v compressed %v", frame.Type(), frame.Compressed())
+	}
+	if err := <-errChan; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_Run_hello(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := NewClient(zap.NewNop(), client, time.Second)
+	errChan := make(chan error)
+
+	// act
+	go func() {
+		errChan <- c.Run()
+	}()
+
+	// assert
+	var hello messages.Hello
+	payload, msgType, err := messages.Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = proto.Unmarshal(payload, &hello)
+	if err != nil {
+		t.Error(err)
+	}
+	if msgType != messages.MsgTypeHello || hello.Version != messages.ProtocolVersion {
+		t.Errorf("Run failed. Expected hello version %d, got %v %#v", messages.ProtocolVersion, msgType, hello)
+	}
+	if _, _, err := messages.Decode(server); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := messages.NewEncoder(server)
+	enc.Encode(&messages.Welcome{Version: 1, MaxFrame: 1024, Features: []string{}}, messages.MsgTypeWelcome)
+	enc.Encode(&messages.IdentityResponse{Id: 123}, messages.MsgTypeIdentityResponse)
+	enc.Flush()
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	if c.version != 1 || c.supports(messages.FeatureStreams) {
+		t.Errorf("Run failed. Expected version 1 without streams, got %d %v", c.version, c.features)
+	}
+	if err := c.SendStream([]int32{456}, "cat.gif", 0, nil); err == nil {
+		t.Error("SendStream failed. Expected error without streams")
+	}
+}
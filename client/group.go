@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// jobBacklog is the number of jobs waiting to be taken, later ones are nacked
+// for the hub to send them to another member of their group
+const jobBacklog = 64
+
+// Job is a relay sent to a queue group the client joined, the hub gives it to a single
+// member. Ack it once it is taken care of, jobs not acked by the time the connection is
+// lost or within the ack timeout of the hub are sent to another member.
+type Job struct {
+	ID    uint64
+	From  int32
+	Group string
+	Body  []byte
+
+	client *Client
+}
+
+// Ack tells the hub the job was taken care of
+func (j *Job) Ack() error {
+	return j.client.send(&messages.JobAck{Job: j.ID}, messages.MsgTypeJobAck)
+}
+
+// JoinGroup makes the client a member of the queue group name and returns its groups
+func (c *Client) JoinGroup(name string) ([]string, error) {
+	groups, err := c.groupRequest(messages.Request_JOIN, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group == name {
+			return groups, nil
+		}
+	}
+	return groups, fmt.Errorf("hub refused to join group %q", name)
+}
+
+// LeaveGroup stops the client getting relays sent to the queue group name and returns
+// its groups, jobs of the group it got already still have to be acked
+func (c *Client) LeaveGroup(name string) ([]string, error) {
+	return c.groupRequest(messages.Request_LEAVE, name)
+}
+
+// Jobs returns relays sent to queue groups the client joined
+func (c *Client) Jobs() <-chan *Job {
+	return c.jobs
+}
+
+func (c *Client) groupRequest(reqType messages.Request_Type, name string) ([]string, error) {
+	c.requestLock.Lock()
+	defer c.requestLock.Unlock()
+
+	// send request
+	groupReq := &messages.Request{
+		Id:    c.id,
+		Type:  reqType,
+		Group: name,
+	}
+	err := c.send(groupReq, messages.MsgTypeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// receive response
+	var msgRaw messageRaw
+	select {
+	case <-time.After(c.requestTimeout):
+		return nil, errors.New("group request timed out")
+	case msgRaw = <-c.responseChan:
+	}
+
+	if msgRaw.msgType != messages.MsgTypeGroupResponse {
+		return nil, fmt.Errorf("bad response, expected: %d, got %d", messages.MsgTypeGroupResponse, msgRaw.msgType)
+	}
+	var groupResp messages.GroupResponse
+	err = proto.Unmarshal(msgRaw.msg, &groupResp)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	return groupResp.Groups, nil
+}
+
+// handleJob passes a relay to a queue group on to Jobs, or nacks it if the backlog is full
+func (c *Client) handleJob(relay *messages.Relay) {
+	job := &Job{ID: relay.Job, From: relay.From, Group: relay.Group, Body: relay.Body, client: c}
+	select {
+	case c.jobs <- job:
+	default:
+		c.logger.Info("job backlog full, nacking job", zap.String("group", job.Group), zap.Uint64("job", job.ID))
+		if err := c.send(&messages.JobAck{Job: job.ID, Nack: true}, messages.MsgTypeJobAck); err != nil {
+			c.logger.Error("nacking job failed", zap.Uint64("job", job.ID), zap.Error(err))
+		}
+	}
+}
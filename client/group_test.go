@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestClient_JoinGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []string
+		wantErr bool
+	}{
+		{"joined", []string{"mail", "jobs"}, false},
+		{"refused", []string{"mail"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			server, client := net.Pipe()
+			c := newTestClient(client)
+			go c.receiveMessages()
+			go func() {
+				messages.Decode(server)
+				resp, _ := messages.Encode(&messages.GroupResponse{Groups: tt.groups}, messages.MsgTypeGroupResponse)
+				server.Write(resp)
+			}()
+
+			// act
+			groups, err := c.JoinGroup("jobs")
+
+			// assert
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("JoinGroup failed. Expected err %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(groups, tt.groups) {
+				t.Errorf("JoinGroup failed. Expected %v, got %v", tt.groups, groups)
+			}
+		})
+	}
+}
+
+func TestClient_Jobs(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := newTestClient(client)
+	go c.receiveMessages()
+
+	// act
+	relay := encodeRelay(t, &messages.Relay{From: 234, Body: []byte("work"), Group: "jobs", Job: 7})
+	go server.Write(relay)
+	job := <-c.Jobs()
+	go job.Ack()
+
+	// assert
+	want := &Job{ID: 7, From: 234, Group: "jobs", Body: []byte("work"), client: c}
+	if !reflect.DeepEqual(job, want) {
+		t.Errorf("Jobs failed. Expected %#v, got %#v", want, job)
+	}
+	b, msgType, err := messages.Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ack messages.JobAck
+	if err := proto.Unmarshal(b, &ack); err != nil {
+		t.Fatal(err)
+	}
+	if msgType != messages.MsgTypeJobAck || ack.Job != 7 {
+		t.Errorf("Ack failed. Expected job 7 acked, got %s %#v", msgType, ack)
+	}
+}
+
+func TestClient_Jobs_nack(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := newTestClient(client)
+	go c.receiveMessages()
+
+	// act, the backlog holds a single job
+	go func() {
+		server.Write(encodeRelay(t, &messages.Relay{From: 234, Body: []byte("work"), Group: "jobs", Job: 7}))
+		server.Write(encodeRelay(t, &messages.Relay{From: 234, Body: []byte("more"), Group: "jobs", Job: 8}))
+	}()
+
+	// assert
+	b, msgType, err := messages.Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nack messages.JobAck
+	if err := proto.Unmarshal(b, &nack); err != nil {
+		t.Fatal(err)
+	}
+	if want := (messages.JobAck{Job: 8, Nack: true}); msgType != messages.MsgTypeJobAck || !reflect.DeepEqual(nack, want) {
+		t.Errorf("handleJob failed. Expected %#v, got %s %#v", want, msgType, nack)
+	}
+	if job := <-c.Jobs(); job.ID != 7 {
+		t.Errorf("Jobs failed. Expected job 7, got %d", job.ID)
+	}
+}
@@ -1,19 +1,33 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
 	"go.uber.org/zap"
 )
 
 const (
 	port           = 8888
 	requestTimeout = 5 * time.Second
+	reconnectDelay = time.Second
 )
 
 func main() {
+	userID := flag.Int("id", 0, "log in as an existing user from another device, along with -token")
+	token := flag.String("token", "", "session token of the user given with -id, shown by identity")
+	since := flag.Uint64("since", 0, "catch up on relays received after this message id, if the hub keeps history")
+	key := flag.String("key", "", "device key, the hub gives the same user id to every session with the key")
+	compress := flag.String("compress", strings.Join(messages.CodecNames(), ","),
+		"comma separated compression codecs to offer to hub, empty disables compression")
+	e2e := flag.String("e2e", "", "file keeping the key pair sealing relays end to end, created if missing")
+	flag.Parse()
+
 	// init logger
 	l, err := zap.NewProduction()
 	if err != nil {
@@ -21,20 +35,54 @@ func main() {
 	}
 
 	// init hub connection
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	addr := fmt.Sprintf("localhost:%d", port)
+	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		panic(fmt.Sprintf("Dial failed: %s", err.Error()))
 	}
 
 	// init client
 	client := NewClient(l, conn, requestTimeout)
+	client.id = int32(*userID)
+	client.key = *key
+	client.token = *token
+	client.Resume(*since)
+	for _, codec := range strings.Split(*compress, ",") {
+		if codec = strings.TrimSpace(codec); codec != "" {
+			client.codecs = append(client.codecs, codec)
+		}
+	}
+	if *e2e != "" {
+		keys, err := LoadKeyPair(*e2e)
+		if err != nil {
+			panic(err)
+		}
+		client.SetKeyPair(keys)
+	}
 	err = client.Run()
 	if err != nil {
 		conn.Close()
 		panic(fmt.Sprintf("client.Run failed: %s", err.Error()))
 	}
+	go reconnect(l, client, addr)
 
 	// init command line interface
 	api := NewAPI(client)
 	api.Run()
 }
+
+// reconnect dials the hub again whenever the connection of client is lost
+func reconnect(l *zap.Logger, client *Client, addr string) {
+	for range client.Lost() {
+		for {
+			conn, err := net.Dial("tcp", addr)
+			if err == nil {
+				if err = client.Reconnect(conn); err == nil {
+					break
+				}
+			}
+			l.Error("reconnecting failed", zap.Error(err))
+			time.Sleep(reconnectDelay)
+		}
+	}
+}
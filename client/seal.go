@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Sealed relays are encrypted end to end with NaCl box (X25519, XSalsa20-Poly1305): the sender
+// seals the body for every receiver with the public key the receiver published to the hub
+// directory, the receiver opens it and so verifies it was sealed by the holder of the sender's
+// private key. A sealed body is the sender's public key, a random nonce and the box, the hub
+// relays and keeps it as is. A relay of a user whose key isn't pinned yet is opened only if it
+// was sealed with the key the user published, which is pinned then. Relays sealed with another
+// key are dropped until LookupKeys fetches the key from the directory again.
+
+const (
+	keyLen   = 32
+	nonceLen = 24
+	// sealOverhead is the length a sealed body adds to the plain body
+	sealOverhead = keyLen + nonceLen + box.Overhead
+)
+
+// ErrNoKeyPair is returned when sealing or opening relays without a key pair, see SetKeyPair
+var ErrNoKeyPair = errors.New("client has no key pair")
+
+// KeyPair is the X25519 key pair sealing and opening relays of a client
+type KeyPair struct {
+	Public  *[keyLen]byte
+	Private *[keyLen]byte
+}
+
+// GenerateKeyPair returns a new random key pair
+func GenerateKeyPair() (*KeyPair, error) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key pair failed, %s", err.Error())
+	}
+	return &KeyPair{Public: public, Private: private}, nil
+}
+
+// LoadKeyPair reads the private key kept at path, a new key pair is generated and saved
+// there if the file doesn't exist. Peers pin the public key, so keep the file across sessions.
+func LoadKeyPair(path string) (*KeyPair, error) {
+	private, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		keys, err := GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, keys.Private[:], 0600); err != nil {
+			return nil, fmt.Errorf("saving key pair failed, %s", err.Error())
+		}
+		return keys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading key pair failed, %s", err.Error())
+	}
+	if len(private) != keyLen {
+		return nil, fmt.Errorf("key file %s must hold %d bytes", path, keyLen)
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("deriving public key failed, %s", err.Error())
+	}
+	keys := &KeyPair{Public: new([keyLen]byte), Private: new([keyLen]byte)}
+	copy(keys.Public[:], public)
+	copy(keys.Private[:], private)
+	return keys, nil
+}
+
+// SetKeyPair makes the client seal and open relays with keys, Run publishes the public key
+// to the hub directory. It must be called before Run.
+func (c *Client) SetKeyPair(keys *KeyPair) {
+	c.keys = keys
+}
+
+// publishKey stores the public key in the profile of the user
+func (c *Client) publishKey() error {
+	profiles, err := c.profileRequest(&messages.Request{
+		Id:        c.id,
+		Type:      messages.Request_PUBLISH_KEY,
+		PublicKey: c.keys.Public[:],
+	})
+	if err != nil {
+		return err
+	}
+	if len(profiles) != 1 || !bytes.Equal(profiles[0].PublicKey, c.keys.Public[:]) {
+		return errors.New("hub did not store the public key")
+	}
+	return nil
+}
+
+// LookupKeys fetches the public keys of ids from the hub directory and pins them in place of
+// keys seen before, users without a published key are left out
+func (c *Client) LookupKeys(ids []int32) (map[int32]*[keyLen]byte, error) {
+	profiles, err := c.LookupUsers(ids)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[int32]*[keyLen]byte, len(profiles))
+	c.keyLock.Lock()
+	defer c.keyLock.Unlock()
+	for _, profile := range profiles {
+		if len(profile.PublicKey) != keyLen {
+			continue
+		}
+		key := new([keyLen]byte)
+		copy(key[:], profile.PublicKey)
+		keys[profile.Id] = key
+		c.peerKeys[profile.Id] = key
+	}
+	return keys, nil
+}
+
+// RelaySealed seals body for each of ids and relays it to every one of them on its own,
+// keys of users not seen before are looked up first. Nothing is relayed if a user has no key.
+// A Key of opts is made unique per receiver.
+func (c *Client) RelaySealed(ids []int32, body []byte, opts RelayOptions) error {
+	if c.keys == nil {
+		return ErrNoKeyPair
+	}
+	if len(body) > messages.BodyMaxLength-sealOverhead {
+		return fmt.Errorf("body longer than %d can't be sealed", messages.BodyMaxLength-sealOverhead)
+	}
+	if len(ids) > messages.MaxReceivers {
+		ids = ids[:messages.MaxReceivers]
+	}
+
+	keys := make(map[int32]*[keyLen]byte, len(ids))
+	var unknown []int32
+	c.keyLock.Lock()
+	for _, id := range ids {
+		if key, ok := c.peerKeys[id]; ok {
+			keys[id] = key
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	c.keyLock.Unlock()
+	if len(unknown) > 0 {
+		found, err := c.LookupKeys(unknown)
+		if err != nil {
+			return err
+		}
+		for _, id := range unknown {
+			if found[id] == nil {
+				return fmt.Errorf("user %d has no public key", id)
+			}
+			keys[id] = found[id]
+		}
+	}
+
+	key := opts.Key
+	opts.sealed = true
+	var sendErr error
+	for _, id := range ids {
+		sealed, err := c.seal(body, keys[id])
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			opts.Key = fmt.Sprintf("%s/%d", key, id)
+		}
+		// relays to the rest of the receivers are kept for Reconnect as well
+		if _, err := c.RelayWithOptions([]int32{id}, sealed, opts); err != nil {
+			if !opts.Resend {
+				return err
+			}
+			if sendErr == nil {
+				sendErr = err
+			}
+		}
+	}
+	return sendErr
+}
+
+// seal encrypts body for the holder of the private key of peer
+func (c *Client) seal(body []byte, peer *[keyLen]byte) ([]byte, error) {
+	sealed := make([]byte, keyLen+nonceLen, sealOverhead+len(body))
+	copy(sealed, c.keys.Public[:])
+	var nonce [nonceLen]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("reading nonce failed, %s", err.Error())
+	}
+	copy(sealed[keyLen:], nonce[:])
+	return box.Seal(sealed, body, &nonce, peer, c.keys.Private), nil
+}
+
+// open decrypts the body of a sealed relay in place. The key of a sender not pinned yet is
+// looked up in the hub directory, so open must not be called by the goroutine receiving messages.
+func (c *Client) open(relay *messages.Relay) error {
+	if c.keys == nil {
+		return ErrNoKeyPair
+	}
+	if len(relay.Body) < sealOverhead {
+		return errors.New("sealed body too short")
+	}
+	var sender [keyLen]byte
+	var nonce [nonceLen]byte
+	copy(sender[:], relay.Body)
+	copy(nonce[:], relay.Body[keyLen:])
+
+	c.keyLock.Lock()
+	pinned := c.peerKeys[relay.From]
+	c.keyLock.Unlock()
+	if pinned == nil {
+		keys, err := c.LookupKeys([]int32{relay.From})
+		if err != nil {
+			return fmt.Errorf("looking up key of user %d failed, %s", relay.From, err.Error())
+		}
+		if pinned = keys[relay.From]; pinned == nil {
+			return fmt.Errorf("user %d has no published key", relay.From)
+		}
+	}
+	if *pinned != sender {
+		return fmt.Errorf("user %d sealed relay with another key than pinned", relay.From)
+	}
+	body, ok := box.Open(nil, relay.Body[keyLen+nonceLen:], &nonce, pinned, c.keys.Private)
+	if !ok {
+		return errors.New("sealed body failed to open")
+	}
+	relay.Body = body
+	return nil
+}
+
+// deferRelay queues relay if it was sealed by a user whose key isn't pinned yet or relays of
+// its sender are queued already, and reports whether it did. Queued relays are opened and
+// received in order by a goroutine of their own, as the goroutine receiving messages receives
+// the response to the key lookup.
+func (c *Client) deferRelay(relay *messages.Relay) bool {
+	c.keyLock.Lock()
+	defer c.keyLock.Unlock()
+	if queued, ok := c.pending[relay.From]; ok {
+		c.pending[relay.From] = append(queued, relay)
+		return true
+	}
+	if !relay.Sealed || c.keys == nil || c.peerKeys[relay.From] != nil {
+		return false
+	}
+	if c.pending == nil {
+		c.pending = make(map[int32][]*messages.Relay)
+	}
+	c.pending[relay.From] = []*messages.Relay{relay}
+	go c.receivePending(relay.From)
+	return true
+}
+
+// receivePending opens and receives relays of sender queued by deferRelay
+func (c *Client) receivePending(sender int32) {
+	for {
+		c.keyLock.Lock()
+		relays := c.pending[sender]
+		if len(relays) == 0 {
+			delete(c.pending, sender)
+			c.keyLock.Unlock()
+			return
+		}
+		c.pending[sender] = nil
+		c.keyLock.Unlock()
+
+		for _, relay := range relays {
+			if relay.Sealed {
+				if err := c.open(relay); err != nil {
+					c.logger.Error("opening sealed relay failed", zap.Int32("from", relay.From), zap.Error(err))
+					continue
+				}
+			}
+			c.receiveRelay(relay)
+		}
+	}
+}
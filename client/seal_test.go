@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+func TestClient_RelaySealed(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	sender := newTestClient(client)
+	sender.id = 234
+	sender.SetKeyPair(generateKeyPair(t))
+	go sender.receiveMessages()
+	receiver := newTestClient(nil)
+	receiver.SetKeyPair(generateKeyPair(t))
+	requests := make(chan *messages.RelayRequest, 1)
+	go func() {
+		// the key of the receiver is looked up first
+		b, _, err := messages.Decode(server)
+		if err != nil {
+			return
+		}
+		var lookup messages.Request
+		proto.Unmarshal(b, &lookup)
+		resp, _ := messages.Encode(&messages.ProfileResponse{Profiles: []*messages.Profile{
+			{Id: 123, PublicKey: receiver.keys.Public[:]},
+		}}, messages.MsgTypeProfileResponse)
+		server.Write(resp)
+
+		b, _, err = messages.Decode(server)
+		if err != nil {
+			return
+		}
+		var req messages.RelayRequest
+		proto.Unmarshal(b, &req)
+		requests <- &req
+	}()
+
+	// act
+	err := sender.RelaySealed([]int32{123}, []byte("g'day"), RelayOptions{})
+
+	// assert
+	if err != nil {
+		t.Fatalf("RelaySealed failed. Unexpected err: %s", err.Error())
+	}
+	req := <-requests
+	if !req.Sealed || len(req.Ids) != 1 || req.Ids[0] != 123 || bytes.Contains(req.Body, []byte("g'day")) {
+		t.Fatalf("RelaySealed failed. Expected sealed relay to 123, got %#v", req)
+	}
+	receiver.peerKeys[234] = sender.keys.Public
+	relay := &messages.Relay{From: 234, Body: req.Body, Sealed: true}
+	if err := receiver.open(relay); err != nil {
+		t.Fatalf("open failed. Unexpected err: %s", err.Error())
+	}
+	if string(relay.Body) != "g'day" {
+		t.Errorf("open failed. Expected g'day, got %q", relay.Body)
+	}
+}
+
+func TestClient_handleRelay_unpinned(t *testing.T) {
+	sender := newTestClient(nil)
+	sender.SetKeyPair(generateKeyPair(t))
+	tests := []struct {
+		name      string
+		published *KeyPair
+		// want is the first job received, the sealed one if it opens
+		want uint64
+	}{
+		{"published key", sender.keys, 7},
+		{"another key published", generateKeyPair(t), 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			server, client := net.Pipe()
+			receiver := newTestClient(client)
+			receiver.SetKeyPair(generateKeyPair(t))
+			go receiver.receiveMessages()
+			sealed, err := sender.seal([]byte("g'day"), receiver.keys.Public)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// act
+			go func() {
+				server.Write(encodeRelay(t, &messages.Relay{From: 234, Body: sealed, Sealed: true, Group: "jobs", Job: 7}))
+				// relays of the sender wait for its key
+				server.Write(encodeRelay(t, &messages.Relay{From: 234, Body: []byte("plain"), Group: "jobs", Job: 8}))
+			}()
+
+			// assert, the key of the sender is looked up in the directory
+			b, _, err := messages.Decode(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lookup messages.Request
+			if err := proto.Unmarshal(b, &lookup); err != nil || lookup.Type != messages.Request_LOOKUP || len(lookup.Ids) != 1 || lookup.Ids[0] != 234 {
+				t.Fatalf("open failed. Expected lookup of 234, got %#v", lookup)
+			}
+			resp, _ := messages.Encode(&messages.ProfileResponse{Profiles: []*messages.Profile{
+				{Id: 234, PublicKey: tt.published.Public[:]},
+			}}, messages.MsgTypeProfileResponse)
+			go server.Write(resp)
+			job := <-receiver.Jobs()
+			if job.ID != tt.want {
+				t.Fatalf("handleRelay failed. Expected job %d first, got %d", tt.want, job.ID)
+			}
+			if job.ID == 7 && string(job.Body) != "g'day" {
+				t.Errorf("open failed. Expected g'day, got %q", job.Body)
+			}
+		})
+	}
+}
+
+func TestClient_open_invalid(t *testing.T) {
+	sender := newTestClient(nil)
+	sender.SetKeyPair(generateKeyPair(t))
+	other := generateKeyPair(t)
+	receiver := generateKeyPair(t)
+	sealed, err := sender.seal([]byte("g'day"), receiver.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		body   []byte
+		pinned *KeyPair
+	}{
+		{"tampered", tampered, sender.keys},
+		{"short", sealed[:sealOverhead-1], nil},
+		{"another key pinned", sealed, other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			c := newTestClient(nil)
+			c.SetKeyPair(receiver)
+			if tt.pinned != nil {
+				c.peerKeys[234] = tt.pinned.Public
+			}
+
+			// act
+			err := c.open(&messages.Relay{From: 234, Body: tt.body, Sealed: true})
+
+			// assert
+			if err == nil {
+				t.Error("open failed. Expected error")
+			}
+		})
+	}
+}
+
+func TestLoadKeyPair(t *testing.T) {
+	// arrange
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "e2e.key")
+	created, err := LoadKeyPair(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	loaded, err := LoadKeyPair(path)
+
+	// assert
+	if err != nil {
+		t.Fatalf("LoadKeyPair failed. Unexpected err: %s", err.Error())
+	}
+	if *loaded.Public != *created.Public || *loaded.Private != *created.Private {
+		t.Error("LoadKeyPair failed. Expected the saved key pair")
+	}
+}
+
+func generateKeyPair(t *testing.T) *KeyPair {
+	t.Helper()
+	keys, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
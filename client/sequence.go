@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// gapBacklog is the number of gaps waiting to be taken, later ones are dropped
+const gapBacklog = 64
+
+// Gap is a run of relays from a sender the client missed, numbered First to Last
+type Gap struct {
+	From        int32
+	Priority    messages.RelayRequest_Priority
+	First, Last uint64
+}
+
+// Sequences tracks sequence numbers of relays received per sender and priority. Passing it
+// to the client of the next connection tells relays missed or received twice across reconnects,
+// see Client.ResumeSequences.
+type Sequences struct {
+	lock sync.Mutex
+	last map[sequenceKey]uint64
+}
+
+type sequenceKey struct {
+	from     int32
+	priority messages.RelayRequest_Priority
+}
+
+func NewSequences() *Sequences {
+	return &Sequences{last: make(map[sequenceKey]uint64)}
+}
+
+// Check records the sequence number of relay and returns the relays missed before it, if any,
+// and whether relay was received already. Numbers start from 1 again after a hub restart.
+func (s *Sequences) Check(relay *messages.Relay) (*Gap, bool) {
+	key := sequenceKey{from: relay.From, priority: relay.Priority}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	last := s.last[key]
+	if relay.Sequence <= last && relay.Sequence != 1 {
+		return nil, true
+	}
+	s.last[key] = relay.Sequence
+	// the first relay of a sender tells nothing about earlier ones
+	if last == 0 || relay.Sequence <= last+1 {
+		return nil, false
+	}
+	return &Gap{From: relay.From, Priority: relay.Priority, First: last + 1, Last: relay.Sequence - 1}, false
+}
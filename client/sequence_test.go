@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestSequences_Check(t *testing.T) {
+	sequences := NewSequences()
+	// cases run in order against the same sequences
+	tests := []struct {
+		name      string
+		relay     *messages.Relay
+		gap       *Gap
+		duplicate bool
+	}{
+		{"first relay of a sender", &messages.Relay{From: 1, Sequence: 5}, nil, false},
+		{"next relay", &messages.Relay{From: 1, Sequence: 6}, nil, false},
+		{"missed relays", &messages.Relay{From: 1, Sequence: 9}, &Gap{From: 1, First: 7, Last: 8}, false},
+		{"duplicate", &messages.Relay{From: 1, Sequence: 8}, nil, true},
+		{"other priority", &messages.Relay{From: 1, Sequence: 1, Priority: messages.RelayRequest_HIGH}, nil, false},
+		{"other sender", &messages.Relay{From: 2, Sequence: 1}, nil, false},
+		{"hub restarted", &messages.Relay{From: 1, Sequence: 1}, nil, false},
+		{"after restart", &messages.Relay{From: 1, Sequence: 3}, &Gap{From: 1, First: 2, Last: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gap, duplicate := sequences.Check(tt.relay)
+			if !reflect.DeepEqual(gap, tt.gap) || duplicate != tt.duplicate {
+				t.Errorf("Check() = %#v, %v, want %#v, %v", gap, duplicate, tt.gap, tt.duplicate)
+			}
+		})
+	}
+}
+
+func TestClient_receiveRelay_sequences(t *testing.T) {
+	// arrange
+	c := NewClient(zap.NewNop(), nil, time.Second)
+	previous := NewSequences()
+	previous.Check(&messages.Relay{From: 1, Sequence: 2})
+	c.ResumeSequences(previous)
+
+	// act
+	c.receiveRelay(&messages.Relay{From: 1, Sequence: 2, Body: []byte("again")})
+	c.receiveRelay(&messages.Relay{From: 1, Sequence: 5, Body: []byte("g'day")})
+
+	// assert
+	select {
+	case gap := <-c.Gaps():
+		if want := (Gap{From: 1, First: 3, Last: 4}); gap != want {
+			t.Errorf("receiveRelay failed. Expected gap %#v, got %#v", want, gap)
+		}
+	default:
+		t.Error("receiveRelay failed. Expected a gap after reconnecting")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// streamBacklog is the number of incoming streams waiting to be accepted,
+// streams opened while the backlog is full are aborted
+const streamBacklog = 16
+
+var errStreamStalled = errors.New("stream stalled, receivers stopped acking")
+
+// Stream is an incoming stream, Read returns its chunks in order and io.EOF once
+// the sender ended it. Every chunk taken from the stream is acked to the hub,
+// which lets the sender send another one.
+type Stream struct {
+	ID     uint64
+	From   int32
+	Name   string
+	Length int64
+
+	client *Client
+	chunks chan []byte
+	chunk  []byte
+	// err is set before chunks is closed
+	err  error
+	once sync.Once
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	if len(s.chunk) == 0 {
+		chunk, ok := <-s.chunks
+		if !ok {
+			return 0, s.err
+		}
+		if err := s.client.send(&messages.StreamAck{Stream: s.ID, Credits: 1}, messages.MsgTypeStreamAck); err != nil {
+			return 0, err
+		}
+		s.chunk = chunk
+	}
+
+	n := copy(p, s.chunk)
+	s.chunk = s.chunk[n:]
+	return n, nil
+}
+
+// Close stops receiving the stream, the sender is told if it wasn't finished yet
+func (s *Stream) Close() error {
+	if !s.client.removeIncoming(s.ID, errors.New("stream closed")) {
+		return nil
+	}
+	return s.client.send(&messages.StreamAbort{Stream: s.ID, Reason: "receiver left"}, messages.MsgTypeStreamAbort)
+}
+
+func (s *Stream) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.chunks)
+	})
+}
+
+// outStream is the state of a stream being sent
+type outStream struct {
+	credits int
+	reason  string
+	aborted bool
+	// wake is signalled when credits arrive or the stream is aborted
+	wake chan struct{}
+}
+
+// Streams returns incoming streams, each of them must be read to the end or closed
+func (c *Client) Streams() <-chan *Stream {
+	return c.streams
+}
+
+// SendStream relays everything read from r to other users in chunks. Length is passed
+// to the receivers as is and may be 0 if unknown. SendStream returns when r is exhausted
+// or the stream was aborted.
+func (c *Client) SendStream(ids []int32, name string, length int64, r io.Reader) error {
+	if !c.supports(messages.FeatureStreams) {
+		return errors.New("hub doesn't support streams")
+	}
+	if len(ids) > messages.MaxReceivers {
+		ids = ids[:messages.MaxReceivers]
+	}
+	out := &outStream{wake: make(chan struct{}, 1)}
+	c.streamLock.Lock()
+	if c.outgoing == nil {
+		c.outgoing = make(map[uint64]*outStream)
+	}
+	// clients open streams with odd ids
+	c.lastStream++
+	id := c.lastStream*2 - 1
+	c.outgoing[id] = out
+	c.streamLock.Unlock()
+	defer func() {
+		c.streamLock.Lock()
+		delete(c.outgoing, id)
+		c.streamLock.Unlock()
+	}()
+
+	open := &messages.StreamOpen{
+		Stream: id,
+		Id:     c.id,
+		Ids:    ids,
+		Name:   name,
+		Length: length,
+	}
+	err := c.send(open, messages.MsgTypeStreamOpen)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, messages.ChunkMaxLength)
+	for {
+		err = c.waitCredit(out)
+		if err == errStreamStalled {
+			c.send(&messages.StreamAbort{Stream: id, Reason: err.Error()}, messages.MsgTypeStreamAbort)
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			chunk := &messages.StreamChunk{
+				Stream: id,
+				Data:   buf[:n],
+			}
+			if err := c.send(chunk, messages.MsgTypeStreamChunk); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return c.send(&messages.StreamEnd{Stream: id}, messages.MsgTypeStreamEnd)
+		}
+		if err != nil {
+			c.send(&messages.StreamAbort{Stream: id, Reason: err.Error()}, messages.MsgTypeStreamAbort)
+			return fmt.Errorf("reading stream failed: %s", err.Error())
+		}
+	}
+}
+
+// waitCredit takes a credit for the next chunk, waiting for acks if there is none
+func (c *Client) waitCredit(out *outStream) error {
+	for {
+		c.streamLock.Lock()
+		switch {
+		case out.aborted:
+			c.streamLock.Unlock()
+			return fmt.Errorf("stream aborted: %s", out.reason)
+		case out.credits > 0:
+			out.credits--
+			c.streamLock.Unlock()
+			return nil
+		}
+		c.streamLock.Unlock()
+
+		select {
+		case <-out.wake:
+		case <-time.After(c.requestTimeout):
+			return errStreamStalled
+		}
+	}
+}
+
+func (c *Client) handleStream(msgType messages.MsgType, bytes []byte) {
+	var err error
+	switch msgType {
+	case messages.MsgTypeStreamOpen:
+		err = c.streamOpened(bytes)
+	case messages.MsgTypeStreamChunk:
+		err = c.streamChunk(bytes)
+	case messages.MsgTypeStreamEnd:
+		err = c.streamEnded(bytes)
+	case messages.MsgTypeStreamAbort:
+		err = c.streamAborted(bytes)
+	case messages.MsgTypeStreamAck:
+		err = c.streamAcked(bytes)
+	}
+	if err != nil {
+		c.logger.Error("stream failed", zap.Stringer("type", msgType), zap.Error(err))
+	}
+}
+
+func (c *Client) streamOpened(bytes []byte) error {
+	var open messages.StreamOpen
+	if err := proto.Unmarshal(bytes, &open); err != nil {
+		return fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+	s := &Stream{
+		ID:     open.Stream,
+		From:   open.Id,
+		Name:   open.Name,
+		Length: open.Length,
+		client: c,
+		chunks: make(chan []byte, messages.StreamWindow),
+	}
+
+	c.streamLock.Lock()
+	if c.incoming == nil {
+		c.incoming = make(map[uint64]*Stream)
+	}
+	c.incoming[s.ID] = s
+	c.streamLock.Unlock()
+
+	select {
+	case c.streams <- s:
+		c.logger.Info("stream opened", zap.Uint64("stream", s.ID), zap.Int32("from", s.From), zap.String("name", s.Name))
+		return nil
+	default:
+		c.removeIncoming(s.ID, errors.New("stream not accepted"))
+		return c.send(&messages.StreamAbort{Stream: s.ID, Reason: "stream not accepted"}, messages.MsgTypeStreamAbort)
+	}
+}
+
+func (c *Client) streamChunk(bytes []byte) error {
+	var chunk messages.StreamChunk
+	if err := proto.Unmarshal(bytes, &chunk); err != nil {
+		return fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	// chunks are queued under the lock, so a concurrent Close can't close the channel meanwhile
+	c.streamLock.Lock()
+	s, ok := c.incoming[chunk.Stream]
+	queued := true
+	if ok {
+		select {
+		case s.chunks <- chunk.Data:
+		default:
+			// the hub never sends more than a window of unacked chunks
+			queued = false
+			delete(c.incoming, s.ID)
+			s.finish(errors.New("stream window exceeded"))
+		}
+	}
+	c.streamLock.Unlock()
+
+	if !queued {
+		return c.send(&messages.StreamAbort{Stream: s.ID, Reason: "stream window exceeded"}, messages.MsgTypeStreamAbort)
+	}
+	return nil
+}
+
+func (c *Client) streamEnded(bytes []byte) error {
+	var end messages.StreamEnd
+	if err := proto.Unmarshal(bytes, &end); err != nil {
+		return fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	c.removeIncoming(end.Stream, io.EOF)
+	return nil
+}
+
+// streamAborted handles aborts of streams sent (odd ids) and received (even ids) by the client
+func (c *Client) streamAborted(bytes []byte) error {
+	var abort messages.StreamAbort
+	if err := proto.Unmarshal(bytes, &abort); err != nil {
+		return fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+	if out, ok := c.outgoing[abort.Stream]; ok {
+		out.aborted = true
+		out.reason = abort.Reason
+		wake(out)
+	}
+	if s, ok := c.incoming[abort.Stream]; ok {
+		delete(c.incoming, abort.Stream)
+		s.finish(fmt.Errorf("stream aborted: %s", abort.Reason))
+	}
+	return nil
+}
+
+func (c *Client) streamAcked(bytes []byte) error {
+	var ack messages.StreamAck
+	if err := proto.Unmarshal(bytes, &ack); err != nil {
+		return fmt.Errorf("unmarshal failed: %s", err.Error())
+	}
+
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+	if out, ok := c.outgoing[ack.Stream]; ok {
+		out.credits += int(ack.Credits)
+		wake(out)
+	}
+	return nil
+}
+
+// removeIncoming finishes an incoming stream with err and reports whether it was still open
+func (c *Client) removeIncoming(id uint64, err error) bool {
+	c.streamLock.Lock()
+	defer c.streamLock.Unlock()
+	s, ok := c.incoming[id]
+	if ok {
+		delete(c.incoming, id)
+		s.finish(err)
+	}
+	return ok
+}
+
+func wake(out *outStream) {
+	select {
+	case out.wake <- struct{}{}:
+	default:
+	}
+}
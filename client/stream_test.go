@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestClient_SendStream(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := NewClient(zap.NewNop(), client, time.Second)
+	c.id = 456
+	go c.receiveMessages()
+	body := bytes.Repeat([]byte("g'day"), messages.ChunkMaxLength/4)
+	errChan := make(chan error)
+
+	// act
+	go func() {
+		errChan <- c.SendStream([]int32{123}, "greetings.txt", int64(len(body)), bytes.NewReader(body))
+	}()
+
+	// assert
+	var open messages.StreamOpen
+	decodeMessage(t, server, messages.MsgTypeStreamOpen, &open)
+	expectedOpen := messages.StreamOpen{Stream: 1, Id: 456, Ids: []int32{123}, Name: "greetings.txt", Length: int64(len(body))}
+	if !reflect.DeepEqual(expectedOpen, open) {
+		t.Errorf("SendStream failed. Expected %#v, got %#v", expectedOpen, open)
+	}
+	ack, err := messages.Encode(&messages.StreamAck{Stream: 1, Credits: 2}, messages.MsgTypeStreamAck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Write(ack)
+
+	var received []byte
+	for len(received) < len(body) {
+		var chunk messages.StreamChunk
+		decodeMessage(t, server, messages.MsgTypeStreamChunk, &chunk)
+		received = append(received, chunk.Data...)
+	}
+	if !bytes.Equal(received, body) {
+		t.Errorf("SendStream failed. Expected %d bytes, got %d", len(body), len(received))
+	}
+	var end messages.StreamEnd
+	decodeMessage(t, server, messages.MsgTypeStreamEnd, &end)
+	if err := <-errChan; err != nil {
+		t.Errorf("SendStream failed. Unexpected err: %s", err.Error())
+	}
+}
+
+func TestClient_Streams(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	c := NewClient(zap.NewNop(), client, time.Second)
+	go c.receiveMessages()
+
+	// act
+	go func() {
+		enc := messages.NewEncoder(server)
+		enc.Encode(&messages.StreamOpen{Stream: 2, Id: 456, Name: "greetings.txt"}, messages.MsgTypeStreamOpen)
+		enc.Encode(&messages.StreamChunk{Stream: 2, Data: []byte("g'")}, messages.MsgTypeStreamChunk)
+		enc.Encode(&messages.StreamChunk{Stream: 2, Data: []byte("day")}, messages.MsgTypeStreamChunk)
+		enc.Encode(&messages.StreamEnd{Stream: 2}, messages.MsgTypeStreamEnd)
+		enc.Flush()
+
+		// stream consumer acks every chunk
+		for i := 0; i < 2; i++ {
+			messages.Decode(server)
+		}
+	}()
+
+	// assert
+	stream := <-c.Streams()
+	if stream.ID != 2 || stream.From != 456 || stream.Name != "greetings.txt" {
+		t.Errorf("Streams failed. Unexpected stream %#v", stream)
+	}
+	body, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "g'day" {
+		t.Errorf("Streams failed. Expected %q, got %q", "g'day", body)
+	}
+}
+
+func decodeMessage(t *testing.T, conn net.Conn, msgType messages.MsgType, msg proto.Message) {
+	t.Helper()
+	bytes, gotType, err := messages.Decode(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotType != msgType {
+		t.Fatalf("Expected message %d, got %d", msgType, gotType)
+	}
+	if err := proto.Unmarshal(bytes, msg); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+const (
+	aclAllow = "allow"
+	aclDeny  = "deny"
+)
+
+// ACL decides who may relay to whom and which peers a user may see.
+// A nil *ACL allows everything.
+type ACL struct {
+	path string
+
+	lock    sync.RWMutex
+	rules   aclRules
+	blocked map[int32]map[int32]struct{}
+}
+
+// aclRules is the on-disk format of the rules file
+type aclRules struct {
+	// Default is the action applied when no rule matches, "allow" if empty
+	Default string             `json:"default"`
+	Groups  map[string][]int32 `json:"groups"`
+	Roles   map[string][]int32 `json:"roles"`
+	Rules   []aclRule          `json:"rules"`
+}
+
+// aclRule is checked against the sender (From) and receiver (To).
+// Rules are evaluated in order, the first matching rule wins.
+type aclRule struct {
+	Action string      `json:"action"`
+	From   aclSelector `json:"from"`
+	To     aclSelector `json:"to"`
+}
+
+// aclSelector matches a user by id, group or role. An empty selector matches anyone.
+type aclSelector struct {
+	Users  []int32  `json:"users"`
+	Groups []string `json:"groups"`
+	Roles  []string `json:"roles"`
+}
+
+// NewACL loads rules from path. An empty path gives an ACL without rules,
+// which only enforces per-user block lists.
+func NewACL(path string) (*ACL, error) {
+	a := &ACL{
+		path:    path,
+		blocked: make(map[int32]map[int32]struct{}),
+	}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Reload re-reads the rules file. Block lists are kept.
+func (a *ACL) Reload() error {
+	var rules aclRules
+	if a.path != "" {
+		data, err := ioutil.ReadFile(a.path)
+		if err != nil {
+			return fmt.Errorf("read failed: %s", err.Error())
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return fmt.Errorf("unmarshal failed: %s", err.Error())
+		}
+	}
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	a.rules = rules
+	a.lock.Unlock()
+
+	return nil
+}
+
+// CanRelay reports whether from may relay messages to to
+func (a *ACL) CanRelay(from, to int32) bool {
+	if a == nil {
+		return true
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if _, ok := a.blocked[to][from]; ok {
+		return false
+	}
+
+	return a.rules.allows(from, to)
+}
+
+// CanSee reports whether viewer may see peer in the list of active users
+func (a *ACL) CanSee(viewer, peer int32) bool {
+	return a.CanRelay(viewer, peer)
+}
+
+// Block adds ids to the block list of userID and returns the updated list, lists are capped
+// at messages.BlockListMaxLength ids
+func (a *ACL) Block(userID int32, ids []int32) ([]int32, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	blocked := a.blocked[userID]
+	added := make(map[int32]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := blocked[id]; !ok && id != userID {
+			added[id] = struct{}{}
+		}
+	}
+	if len(blocked)+len(added) > messages.BlockListMaxLength {
+		return nil, fmt.Errorf("block list longer than %d", messages.BlockListMaxLength)
+	}
+	if len(added) == 0 {
+		return a.blockedLocked(userID), nil
+	}
+
+	if blocked == nil {
+		blocked = make(map[int32]struct{}, len(added))
+		a.blocked[userID] = blocked
+	}
+	for id := range added {
+		blocked[id] = struct{}{}
+	}
+
+	return a.blockedLocked(userID), nil
+}
+
+// Unblock removes ids from the block list of userID and returns the updated list
+func (a *ACL) Unblock(userID int32, ids []int32) []int32 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, id := range ids {
+		delete(a.blocked[userID], id)
+	}
+	if len(a.blocked[userID]) == 0 {
+		delete(a.blocked, userID)
+	}
+
+	return a.blockedLocked(userID)
+}
+
+// SetBlocked replaces the block list of userID, the users store keeps lists across restarts
+func (a *ACL) SetBlocked(userID int32, ids []int32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if len(ids) == 0 {
+		delete(a.blocked, userID)
+		return
+	}
+	blocked := make(map[int32]struct{}, len(ids))
+	for _, id := range ids {
+		blocked[id] = struct{}{}
+	}
+	a.blocked[userID] = blocked
+}
+
+// Blocked returns the block list of userID
+func (a *ACL) Blocked(userID int32) []int32 {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.blockedLocked(userID)
+}
+
+func (a *ACL) blockedLocked(userID int32) []int32 {
+	ids := make([]int32, 0, len(a.blocked[userID]))
+	for id := range a.blocked[userID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func (r *aclRules) validate() error {
+	if r.Default != "" && r.Default != aclAllow && r.Default != aclDeny {
+		return fmt.Errorf("bad default action %q", r.Default)
+	}
+	for i, rule := range r.Rules {
+		if rule.Action != aclAllow && rule.Action != aclDeny {
+			return fmt.Errorf("rule %d: bad action %q", i, rule.Action)
+		}
+	}
+
+	return nil
+}
+
+func (r *aclRules) allows(from, to int32) bool {
+	for _, rule := range r.Rules {
+		if r.matches(rule.From, from) && r.matches(rule.To, to) {
+			return rule.Action == aclAllow
+		}
+	}
+
+	return r.Default != aclDeny
+}
+
+func (r *aclRules) matches(s aclSelector, userID int32) bool {
+	if len(s.Users) == 0 && len(s.Groups) == 0 && len(s.Roles) == 0 {
+		return true
+	}
+	if containsID(s.Users, userID) {
+		return true
+	}
+	for _, group := range s.Groups {
+		if containsID(r.Groups[group], userID) {
+			return true
+		}
+	}
+	for _, role := range s.Roles {
+		if containsID(r.Roles[role], userID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsID(ids []int32, id int32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+func TestACL_CanRelay(t *testing.T) {
+	rules := `{
+		"default": "deny",
+		"groups": {"staff": [1, 2]},
+		"roles": {"admin": [3]},
+		"rules": [
+			{"action": "deny", "from": {"users": [2]}, "to": {"roles": ["admin"]}},
+			{"action": "allow", "from": {"groups": ["staff"]}},
+			{"action": "allow", "from": {"roles": ["admin"]}},
+			{"action": "allow", "to": {"users": [5]}}
+		]
+	}`
+	acl := newTestACL(t, rules)
+
+	tests := []struct {
+		name string
+		from int32
+		to   int32
+		want bool
+	}{
+		{"group member", 1, 4, true},
+		{"denied before group rule", 2, 3, false},
+		{"role member", 3, 1, true},
+		{"receiver rule", 4, 5, true},
+		{"default", 4, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.CanRelay(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanRelay(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACL_Block(t *testing.T) {
+	// arrange
+	acl := newTestACL(t, "")
+
+	// act
+	blocked, err := acl.Block(1, []int32{3, 2, 1})
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blocked, []int32{2, 3}) {
+		t.Errorf("Block failed. Expected %#v, got %#v", []int32{2, 3}, blocked)
+	}
+	if acl.CanRelay(2, 1) {
+		t.Error("Block failed. Blocked user can relay")
+	}
+	if !acl.CanRelay(1, 2) {
+		t.Error("Block failed. Blocking user can't relay")
+	}
+
+	blocked = acl.Unblock(1, []int32{2})
+	if !reflect.DeepEqual(blocked, []int32{3}) {
+		t.Errorf("Unblock failed. Expected %#v, got %#v", []int32{3}, blocked)
+	}
+	if !acl.CanRelay(2, 1) {
+		t.Error("Unblock failed. Unblocked user can't relay")
+	}
+}
+
+func TestACL_Block_limits(t *testing.T) {
+	// arrange
+	acl := newTestACL(t, "")
+	ids := make([]int32, messages.BlockListMaxLength)
+	for i := range ids {
+		ids[i] = int32(i + 2)
+	}
+
+	// act
+	_, err := acl.Block(1, ids)
+	_, over := acl.Block(1, []int32{1, 2, messages.BlockListMaxLength + 2})
+	none, _ := acl.Block(3, nil)
+
+	// assert
+	if err != nil {
+		t.Fatalf("Block failed. Unexpected err: %s", err.Error())
+	}
+	if over == nil {
+		t.Errorf("Block failed. Expected error for more than %d ids", messages.BlockListMaxLength)
+	}
+	if len(acl.Blocked(1)) != messages.BlockListMaxLength || len(none) != 0 || len(acl.blocked) != 1 {
+		t.Errorf("Block failed. Expected one full list, got %d lists", len(acl.blocked))
+	}
+}
+
+func TestACL_Reload(t *testing.T) {
+	// arrange
+	f, err := ioutil.TempFile("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"default": "deny"}`)
+	f.Close()
+
+	acl, err := NewACL(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl.Block(1, []int32{2})
+
+	// act
+	err = ioutil.WriteFile(f.Name(), []byte(`{"default": "allow"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acl.Reload()
+
+	// assert
+	if err != nil {
+		t.Errorf("Reload failed. Unexpected err: %s", err.Error())
+	}
+	if !acl.CanRelay(3, 4) {
+		t.Error("Reload failed. New rules are not applied")
+	}
+	if acl.CanRelay(2, 1) {
+		t.Error("Reload failed. Block list is lost")
+	}
+
+	err = ioutil.WriteFile(f.Name(), []byte(`{"default": "maybe"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = acl.Reload(); err == nil {
+		t.Error("Reload failed. Expected error for bad default action")
+	}
+	if !acl.CanRelay(3, 4) {
+		t.Error("Reload failed. Bad rules replaced the current ones")
+	}
+}
+
+func newTestACL(t *testing.T, rules string) *ACL {
+	path := ""
+	if rules != "" {
+		f, err := ioutil.TempFile("", "acl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString(rules)
+		f.Close()
+		path = f.Name()
+	}
+
+	acl, err := NewACL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acl
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Admin serves the operator HTTP/JSON API of a running hub:
+//
+//	GET    /users                   connected sessions of users with their counters
+//	POST   /users/{id}/kick         close all sessions of a user
+//	POST   /users/{id}/ban          kick a user and reject further connections from its hosts
+//	POST   /sessions/{session}/kick close a single session
+//	GET    /bans                    banned hosts
+//	DELETE /bans/{host}             lift a ban
+//	POST   /relay                   relay {"ids": [...], "body": "..."} on behalf of the hub
+//	GET    /cluster                 known nodes of the cluster
+//	GET    /config                  effective configuration
+//	GET    /log/level               current log level
+//	PUT    /log/level               change log level with {"level": "debug"}
+//
+// Every request must carry "Authorization: Bearer <token>".
+type Admin struct {
+	hub    *Hub
+	token  string
+	level  zap.AtomicLevel
+	config atomic.Value
+	logger *zap.Logger
+}
+
+// adminRelaySyntax is the room for keys and punctuation of an adminRelay
+const adminRelaySyntax = 1 << 10
+
+type adminRelay struct {
+	Ids  []int32 `json:"ids"`
+	Body string  `json:"body"`
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func NewAdmin(logger *zap.Logger, hub *Hub, token string, level zap.AtomicLevel, config Config) *Admin {
+	a := &Admin{
+		hub:    hub,
+		token:  token,
+		level:  level,
+		logger: logger,
+	}
+	a.SetConfig(config)
+
+	return a
+}
+
+// SetConfig replaces the configuration shown by GET /config, secrets are redacted
+func (a *Admin) SetConfig(config Config) {
+	a.config.Store(config.Redacted())
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, adminError{"unauthorized"})
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "users" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.hub.Subscribers())
+	case len(parts) == 3 && parts[0] == "users" && r.Method == http.MethodPost:
+		a.userAction(w, parts[1], parts[2])
+	case len(parts) == 3 && parts[0] == "sessions" && parts[2] == "kick" && r.Method == http.MethodPost:
+		a.kickSession(w, parts[1])
+	case path == "bans" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.hub.Banned())
+	case len(parts) == 2 && parts[0] == "bans" && r.Method == http.MethodDelete:
+		a.hub.Unban(parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	case path == "relay" && r.Method == http.MethodPost:
+		a.relay(w, r)
+	case path == "cluster" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.hub.cluster.Nodes())
+	case path == "config" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.config.Load())
+	case path == "log/level":
+		a.level.ServeHTTP(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, adminError{"not found"})
+	}
+}
+
+func (a *Admin) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+func (a *Admin) userAction(w http.ResponseWriter, idStr, action string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, adminError{"bad user id"})
+		return
+	}
+
+	var ok bool
+	switch action {
+	case "kick":
+		ok = a.hub.Kick(int32(id))
+	case "ban":
+		if ok, err = a.hub.Ban(int32(id)); err != nil {
+			writeJSON(w, http.StatusConflict, adminError{err.Error()})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusNotFound, adminError{"not found"})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, adminError{"user is not connected"})
+		return
+	}
+	a.logger.Info("admin action", zap.String("action", action), zap.Int("id", id))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Admin) kickSession(w http.ResponseWriter, sessionStr string) {
+	session, err := strconv.ParseUint(sessionStr, 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, adminError{"bad session id"})
+		return
+	}
+	if !a.hub.KickSession(session) {
+		writeJSON(w, http.StatusNotFound, adminError{"session is not connected"})
+		return
+	}
+	a.logger.Info("admin action", zap.String("action", "kick session"), zap.Uint64("session", session))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Admin) relay(w http.ResponseWriter, r *http.Request) {
+	// a body escaped in JSON takes up to 6 bytes per byte, an id up to 12 with its comma
+	limits := a.hub.currentSettings().limits
+	r.Body = http.MaxBytesReader(w, r.Body, int64(6*limits.MaxBody+12*limits.MaxReceivers+adminRelaySyntax))
+	var req adminRelay
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, adminError{err.Error()})
+		return
+	}
+	if len(req.Ids) == 0 {
+		writeJSON(w, http.StatusBadRequest, adminError{"no receivers"})
+		return
+	}
+
+	ids, bodyLen := limitRelay(limits, req.Ids, len(req.Body))
+	a.hub.relay(serverUserID, ids, []byte(req.Body[:bodyLen]), relayOptions{})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
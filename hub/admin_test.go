@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestAdmin_unauthorized(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+
+	// act
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	admin.ServeHTTP(rec, req)
+
+	// assert
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestAdmin_users(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	server, _ := net.Pipe()
+	sub := newTestSubscriber(123, server)
+	sub.received(10)
+	admin.hub.addSession(sub)
+
+	// act
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodGet, "/users", ""))
+
+	// assert
+	if rec.Code != http.StatusOK {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusOK, rec.Code)
+	}
+	var users []SubscriberInfo
+	if err := json.NewDecoder(rec.Body).Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != 123 || users[0].FramesIn != 1 || users[0].BytesIn != 10 {
+		t.Errorf("ServeHTTP failed. Unexpected users %#v", users)
+	}
+}
+
+func TestAdmin_kick(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	server, client := net.Pipe()
+	admin.hub.addSession(newTestSubscriber(123, server))
+
+	// act
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/users/123/kick", ""))
+
+	// assert
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("ServeHTTP failed. Connection is not closed")
+	}
+
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/users/456/kick", ""))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAdmin_kickSession(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	phone, phoneClient := net.Pipe()
+	laptop, laptopClient := net.Pipe()
+	sub := newTestSubscriber(123, phone)
+	admin.hub.addSession(sub)
+	admin.hub.addSession(newTestSubscriber(123, laptop))
+
+	// act
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, fmt.Sprintf("/sessions/%d/kick", sub.session), ""))
+
+	// assert
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if _, err := phoneClient.Read(make([]byte, 1)); err == nil {
+		t.Error("ServeHTTP failed. Kicked session is not closed")
+	}
+	laptopClient.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := laptopClient.Read(make([]byte, 1)); err == io.EOF || err == io.ErrClosedPipe {
+		t.Error("ServeHTTP failed. Other session is closed")
+	}
+}
+
+func TestAdmin_relay(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	server, client := net.Pipe()
+	admin.hub.addSession(newTestSubscriber(123, server))
+
+	// act
+	rec := httptest.NewRecorder()
+	go admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/relay", `{"ids": [123], "body": "maintenance"}`))
+
+	// assert
+	bytes, msgType, err := messages.Decode(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgType != messages.MsgTypeRelay {
+		t.Errorf("relay failed. Expected %d, got %d", messages.MsgTypeRelay, msgType)
+	}
+	var result messages.Relay
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	expectedRelay := messages.Relay{
+		Body:     []byte("maintenance"),
+		Sequence: 1,
+	}
+	if !reflect.DeepEqual(expectedRelay, result) {
+		t.Errorf("relay failed. Expected %#v, got %#v", expectedRelay, result)
+	}
+}
+
+func TestAdmin_relay_limits(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	admin.hub.Configure(Limits{MaxBody: 4, MaxReceivers: 1, MaxFrame: messages.DefaultMaxFrame}, Timeouts{})
+	server, client := net.Pipe()
+	admin.hub.addSession(newTestSubscriber(123, server))
+	frames := readFrames(client)
+
+	// act
+	rec := httptest.NewRecorder()
+	go admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/relay", `{"ids": [123, 124], "body": "maintenance"}`))
+
+	// assert
+	expectFrame(t, frames, messages.MsgTypeRelay, &messages.Relay{Body: []byte("main"), Sequence: 1})
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/relay", `{"ids": [123], "body": "`+strings.Repeat("a", 2<<10)+`"}`))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP failed. Expected %d for a request over the limit, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestAdmin_ban_unix(t *testing.T) {
+	// arrange
+	admin := newTestAdmin()
+	server, client := net.Pipe()
+	admin.hub.addSession(newTestSubscriber(123, unixConn{server}))
+
+	// act
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, newAdminRequest(http.MethodPost, "/users/123/ban", ""))
+
+	// assert
+	if rec.Code != http.StatusConflict {
+		t.Errorf("ServeHTTP failed. Expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if banned := admin.hub.Banned(); len(banned) != 0 {
+		t.Errorf("Ban failed. Expected no banned hosts, got %v", banned)
+	}
+	client.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); err == io.EOF || err == io.ErrClosedPipe {
+		t.Error("ServeHTTP failed. Session is closed")
+	}
+}
+
+// unixConn is a connection of a unix socket peer, which has no address
+type unixConn struct {
+	net.Conn
+}
+
+func (unixConn) RemoteAddr() net.Addr {
+	return &net.UnixAddr{Net: "unix"}
+}
+
+func newTestAdmin() *Admin {
+	h := NewHub(zap.L(), nil, nil)
+	return NewAdmin(zap.L(), h, "secret", zap.NewAtomicLevel(), DefaultConfig())
+}
+
+func newAdminRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	return req
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+const (
+	// missedGossips is the number of gossip intervals a peer may stay silent before it is dropped
+	missedGossips = 10
+	// peerMaxFrame is the longest frame accepted from peers, gossip of busy nodes is the largest
+	peerMaxFrame = 16 << 20
+	peerNonceLen = 16
+	// peerBacklog is the number of frames queued for a peer, later ones are dropped
+	peerBacklog = 1024
+)
+
+// Cluster joins hubs into one user namespace. Every hub is a node which prefixes the user ids
+// it issues with its node id (see Users), so the node owning a receiver is known from the id.
+// Nodes keep a connection to each other, gossip presence of their users so List covers the
+// whole cluster, and forward relays to the node owning the receivers.
+//
+// Peers talk the client framing from messages: both sides send PeerHello with a random nonce
+// first and answer the nonce of the other with PeerAuth, proving they know the cluster secret.
+// Gossip and PeerRelay frames follow in any order. A node relays on behalf of its own users
+// and of itself only, and gossips presence of every node with ids of that node only.
+// Frames to a peer are queued and written by a goroutine of its own, so a slow peer
+// holds up neither clients nor other peers.
+type Cluster struct {
+	node     int32
+	addr     string
+	secret   []byte
+	interval time.Duration
+	hub      *Hub
+	logger   *zap.Logger
+
+	lock sync.RWMutex
+	// peers are the connected nodes
+	peers map[int32]*peer
+	// nodes is the latest presence known for every other node
+	nodes map[int32]*messages.NodePresence
+	// dialing are the addresses being connected to
+	dialing map[string]struct{}
+	done    chan struct{}
+}
+
+// peer is a connection to another node
+type peer struct {
+	node     int32
+	addr     string
+	conn     net.Conn
+	outbound bool
+	enc      *messages.Encoder
+	// queue holds frames written by writeFrames until done is closed with the connection
+	queue chan peerFrame
+	done  chan struct{}
+}
+
+type peerFrame struct {
+	msg     messages.Message
+	msgType messages.MsgType
+}
+
+// NodeInfo is a snapshot of a known node for operators
+type NodeInfo struct {
+	Node      int32  `json:"node"`
+	Addr      string `json:"addr"`
+	Connected bool   `json:"connected"`
+	Users     int    `json:"users"`
+}
+
+// NewCluster creates node of a cluster, addr is where other nodes can reach it.
+// Nodes only connect to peers sharing secret.
+func NewCluster(logger *zap.Logger, hub *Hub, node int32, addr, secret string, interval time.Duration) *Cluster {
+	return &Cluster{
+		node:     node,
+		addr:     addr,
+		secret:   []byte(secret),
+		interval: interval,
+		hub:      hub,
+		logger:   logger.With(zap.Int32("node", node)),
+		peers:    make(map[int32]*peer),
+		nodes:    make(map[int32]*messages.NodePresence),
+		dialing:  make(map[string]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Serve accepts connections from other nodes
+func (c *Cluster) Serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			c.logger.Info("peer listener closed", zap.Error(err))
+			return
+		}
+		go c.handlePeer(conn, false)
+	}
+}
+
+// Join connects to the node at addr, the rest of the cluster is discovered through gossip
+func (c *Cluster) Join(addr string) error {
+	c.lock.Lock()
+	if _, ok := c.dialing[addr]; ok {
+		c.lock.Unlock()
+		return nil
+	}
+	c.dialing[addr] = struct{}{}
+	c.lock.Unlock()
+
+	conn, err := net.DialTimeout("tcp", addr, c.peerTimeout())
+
+	c.lock.Lock()
+	delete(c.dialing, addr)
+	c.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("dial peer %s failed, %s", addr, err.Error())
+	}
+
+	go c.handlePeer(conn, true)
+	return nil
+}
+
+// Run gossips presence to peers until Close
+func (c *Cluster) Run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.gossip()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close stops gossip and disconnects from all peers
+func (c *Cluster) Close() {
+	close(c.done)
+	c.lock.RLock()
+	for _, p := range c.peers {
+		p.conn.Close()
+	}
+	c.lock.RUnlock()
+}
+
+func (c *Cluster) handlePeer(conn net.Conn, outbound bool) {
+	defer conn.Close()
+
+	nonce := make([]byte, peerNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		c.logger.Error("peer handshake failed", zap.Error(err))
+		return
+	}
+	enc := messages.NewEncoder(conn)
+	err := enc.Encode(&messages.PeerHello{Node: c.node, Addr: c.addr, Nonce: nonce}, messages.MsgTypePeerHello)
+	if err != nil {
+		panic(fmt.Sprintf("PeerHello marshalling failed, %s", err))
+	}
+	conn.SetDeadline(time.Now().Add(c.peerTimeout()))
+	if err := enc.Flush(); err != nil {
+		c.logger.Error("peer handshake failed", zap.Error(err))
+		return
+	}
+
+	dec := messages.NewDecoder(conn)
+	dec.SetMaxFrame(peerMaxFrame)
+	bytes, msgType, err := dec.Decode()
+	if err != nil || msgType != messages.MsgTypePeerHello {
+		c.logger.Error("peer handshake failed", zap.Stringer("type", msgType), zap.Error(err))
+		return
+	}
+	var remote messages.PeerHello
+	if err := proto.Unmarshal(bytes, &remote); err != nil {
+		c.logger.Error("unmarshal failed", zap.Error(err))
+		return
+	}
+	if remote.Node == c.node {
+		c.logger.Warn("peer has the same node id, disconnecting", zap.String("addr", remote.Addr))
+		return
+	}
+	if !c.authenticate(enc, dec, &remote, nonce) {
+		c.logger.Warn("peer failed authentication, disconnecting", zap.Int32("peer", remote.Node), zap.Stringer("addr", conn.RemoteAddr()))
+		return
+	}
+
+	p := &peer{
+		node:     remote.Node,
+		addr:     remote.Addr,
+		conn:     conn,
+		outbound: outbound,
+		enc:      enc,
+		queue:    make(chan peerFrame, peerBacklog),
+		done:     make(chan struct{}),
+	}
+	if !c.addPeer(p) {
+		return
+	}
+	defer c.removePeer(p)
+	go c.writeFrames(p)
+	defer close(p.done)
+	c.sendGossip(p)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.peerTimeout()))
+		bytes, msgType, err := dec.Decode()
+		if err != nil {
+			c.logger.Info("peer disconnected", zap.Int32("peer", p.node), zap.Error(err))
+			return
+		}
+
+		switch msgType {
+		case messages.MsgTypeGossip:
+			var gossip messages.Gossip
+			if err := proto.Unmarshal(bytes, &gossip); err != nil {
+				c.logger.Error("unmarshal failed", zap.Error(err))
+				continue
+			}
+			c.merge(p.node, gossip.Nodes)
+		case messages.MsgTypePeerRelay:
+			var relay messages.PeerRelay
+			if err := proto.Unmarshal(bytes, &relay); err != nil {
+				c.logger.Error("unmarshal failed", zap.Error(err))
+				continue
+			}
+			if relay.From != serverUserID && nodeOf(relay.From) != p.node {
+				c.logger.Warn("peer relayed for a user of another node, dropping", zap.Int32("peer", p.node), zap.Int32("from", relay.From))
+				continue
+			}
+			c.hub.relayFromPeer(&relay)
+		case messages.MsgTypePeerReceipt:
+			var receipt messages.PeerReceipt
+			if err := proto.Unmarshal(bytes, &receipt); err != nil {
+				c.logger.Error("unmarshal failed", zap.Error(err))
+				continue
+			}
+			if receipt.Receipt == nil || nodeOf(receipt.To) != c.node || nodeOf(receipt.Receipt.Id) != p.node {
+				c.logger.Warn("peer sent receipt for users of other nodes, dropping", zap.Int32("peer", p.node), zap.Int32("to", receipt.To))
+				continue
+			}
+			go c.hub.receiptFromPeer(&receipt)
+		default:
+			c.logger.Info("received unexpected peer message, skipping", zap.Stringer("type", msgType))
+		}
+	}
+}
+
+// authenticate answers the nonce of remote and checks its answer to nonce
+func (c *Cluster) authenticate(enc *messages.Encoder, dec *messages.Decoder, remote *messages.PeerHello, nonce []byte) bool {
+	if len(remote.Nonce) != peerNonceLen {
+		return false
+	}
+	if err := enc.Encode(&messages.PeerAuth{Mac: c.mac(c.node, remote.Nonce)}, messages.MsgTypePeerAuth); err != nil {
+		panic(fmt.Sprintf("PeerAuth marshalling failed, %s", err))
+	}
+	if err := enc.Flush(); err != nil {
+		return false
+	}
+	bytes, msgType, err := dec.Decode()
+	if err != nil || msgType != messages.MsgTypePeerAuth {
+		return false
+	}
+	var auth messages.PeerAuth
+	if err := proto.Unmarshal(bytes, &auth); err != nil {
+		return false
+	}
+	return hmac.Equal(auth.Mac, c.mac(remote.Node, nonce))
+}
+
+// mac proves node knows the secret to the peer which sent nonce, the node id keeps
+// a peer from passing its nonce back to have it answered
+func (c *Cluster) mac(node int32, nonce []byte) []byte {
+	m := hmac.New(sha256.New, c.secret)
+	var id [4]byte
+	binary.BigEndian.PutUint32(id[:], uint32(node))
+	m.Write(id[:])
+	m.Write(nonce)
+	return m.Sum(nil)
+}
+
+// addPeer registers p unless there's already a connection to the node. When two nodes dial
+// each other at once, both keep the connection initiated by the node with the lower id.
+func (c *Cluster) addPeer(p *peer) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if existing, ok := c.peers[p.node]; ok {
+		if c.initiator(p) >= c.initiator(existing) {
+			return false
+		}
+		existing.conn.Close()
+	}
+	c.peers[p.node] = p
+	c.logger.Info("peer connected", zap.Int32("peer", p.node), zap.String("addr", p.addr))
+	return true
+}
+
+func (c *Cluster) removePeer(p *peer) {
+	c.lock.Lock()
+	if c.peers[p.node] == p {
+		delete(c.peers, p.node)
+	}
+	c.lock.Unlock()
+}
+
+func (c *Cluster) initiator(p *peer) int32 {
+	if p.outbound {
+		return c.node
+	}
+	return p.node
+}
+
+// merge keeps the newest presence of every node gossiped by node from and connects to newly
+// discovered ones, presence listing ids of other nodes is dropped
+func (c *Cluster) merge(from int32, nodes []*messages.NodePresence) {
+	var discovered []string
+	c.lock.Lock()
+	for _, np := range nodes {
+		if np == nil || np.Node == c.node {
+			continue
+		}
+		if !ownsIDs(np) {
+			c.logger.Warn("peer gossiped users of another node, dropping", zap.Int32("peer", from), zap.Int32("node", np.Node))
+			continue
+		}
+		if known, ok := c.nodes[np.Node]; ok && known.Version >= np.Version {
+			continue
+		}
+		c.nodes[np.Node] = np
+
+		// the node with the lower id dials to avoid connecting twice
+		_, connected := c.peers[np.Node]
+		if !connected && c.node < np.Node && np.Addr != "" {
+			discovered = append(discovered, np.Addr)
+		}
+	}
+	c.lock.Unlock()
+
+	for _, addr := range discovered {
+		go func(addr string) {
+			if err := c.Join(addr); err != nil {
+				c.logger.Error("join failed", zap.Error(err))
+			}
+		}(addr)
+	}
+}
+
+// ownsIDs reports whether all ids of np were issued by its node
+func ownsIDs(np *messages.NodePresence) bool {
+	for _, id := range np.Ids {
+		if nodeOf(id) != np.Node {
+			return false
+		}
+	}
+	return true
+}
+
+// gossip sends presence of this and all known nodes to every peer
+func (c *Cluster) gossip() {
+	c.lock.RLock()
+	peers := make([]*peer, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, p)
+	}
+	c.lock.RUnlock()
+
+	for _, p := range peers {
+		c.sendGossip(p)
+	}
+}
+
+func (c *Cluster) sendGossip(p *peer) {
+	ids, devices := c.hub.localPresence()
+	own := &messages.NodePresence{
+		Node:    c.node,
+		Addr:    c.addr,
+		Version: time.Now().UnixNano(),
+		Ids:     ids,
+		Devices: devices,
+	}
+
+	c.lock.RLock()
+	nodes := make([]*messages.NodePresence, 0, len(c.nodes)+1)
+	nodes = append(nodes, own)
+	for _, np := range c.nodes {
+		nodes = append(nodes, np)
+	}
+	c.lock.RUnlock()
+
+	// presence entries are replaced on merge rather than modified, so they are safe to encode unlocked
+	if !c.enqueue(p, &messages.Gossip{Nodes: nodes}, messages.MsgTypeGossip) {
+		c.logger.Warn("peer queue is full, dropping gossip", zap.Int32("peer", p.node))
+	}
+}
+
+// forward queues a relay to the node owning ids, reports false if the node is not connected
+// or can't keep up. relay must not be modified afterwards.
+func (c *Cluster) forward(node int32, relay *messages.PeerRelay) bool {
+	c.lock.RLock()
+	p, ok := c.peers[node]
+	c.lock.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if !c.enqueue(p, relay, messages.MsgTypePeerRelay) {
+		c.logger.Warn("peer queue is full, dropping relay", zap.Int32("peer", node))
+		return false
+	}
+	return true
+}
+
+// receipt sends a receipt for a relay forwarded by another node back to the node of its sender
+func (c *Cluster) receipt(sender int32, session uint64, receipt *messages.RelayReceipt) {
+	node := nodeOf(sender)
+	c.lock.RLock()
+	p, ok := c.peers[node]
+	c.lock.RUnlock()
+	if !ok {
+		c.logger.Info("node of sender is not connected, dropping receipt", zap.Int32("peer", node), zap.Int32("to", sender))
+		return
+	}
+
+	if !c.enqueue(p, &messages.PeerReceipt{To: sender, Session: session, Receipt: receipt}, messages.MsgTypePeerReceipt) {
+		c.logger.Warn("peer queue is full, dropping receipt", zap.Int32("peer", node), zap.Int32("to", sender))
+	}
+}
+
+// enqueue queues a frame for p without waiting, it reports false if the queue is full
+func (c *Cluster) enqueue(p *peer, msg messages.Message, msgType messages.MsgType) bool {
+	select {
+	case p.queue <- peerFrame{msg: msg, msgType: msgType}:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeFrames writes frames queued for p until its connection is gone, a write failing
+// closes the connection
+func (c *Cluster) writeFrames(p *peer) {
+	for {
+		select {
+		case f := <-p.queue:
+			if err := c.send(p, f.msg, f.msgType); err != nil {
+				c.logger.Error("writing to peer failed", zap.Int32("peer", p.node), zap.Stringer("type", f.msgType), zap.Error(err))
+				p.conn.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (c *Cluster) send(p *peer, msg messages.Message, msgType messages.MsgType) error {
+	if err := p.enc.Encode(msg, msgType); err != nil {
+		return err
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(c.peerTimeout()))
+	return p.enc.Flush()
+}
+
+func (c *Cluster) peerTimeout() time.Duration {
+	return missedGossips * c.interval
+}
+
+// isRemote reports whether id is owned by another node
+func (c *Cluster) isRemote(id int32) bool {
+	return c != nil && nodeOf(id) != c.node
+}
+
+// Users returns users connected to reachable nodes along with the number of their sessions
+func (c *Cluster) Users() map[int32]int32 {
+	if c == nil {
+		return nil
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	users := make(map[int32]int32)
+	for node, np := range c.nodes {
+		if _, ok := c.peers[node]; !ok {
+			continue
+		}
+		for i, id := range np.Ids {
+			devices := int32(1)
+			if i < len(np.Devices) {
+				devices = np.Devices[i]
+			}
+			users[id] += devices
+		}
+	}
+	return users
+}
+
+// Nodes returns a snapshot of known nodes ordered by id
+func (c *Cluster) Nodes() []NodeInfo {
+	if c == nil {
+		return []NodeInfo{}
+	}
+	c.lock.RLock()
+	infos := make([]NodeInfo, 0, len(c.nodes))
+	for node, np := range c.nodes {
+		_, connected := c.peers[node]
+		infos = append(infos, NodeInfo{
+			Node:      node,
+			Addr:      np.Addr,
+			Connected: connected,
+			Users:     len(np.Ids),
+		})
+	}
+	c.lock.RUnlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Node < infos[j].Node })
+
+	return infos
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestCluster_relay(t *testing.T) {
+	// arrange, nodes 2 and 3 only know node 1 and discover each other through gossip
+	h1, c1 := newTestNode(t, 1)
+	h2, c2 := newTestNode(t, 2)
+	h3, c3 := newTestNode(t, 3)
+	if err := c2.Join(c1.addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := c3.Join(c1.addr); err != nil {
+		t.Fatal(err)
+	}
+
+	phone, phoneClient := net.Pipe()
+	receiver, _ := h3.usersProvider.AuthenticateNewUser()
+	h3.addSession(newTestSubscriber(receiver, phone))
+	sender, _ := h2.usersProvider.AuthenticateNewUser()
+	other, _ := h1.usersProvider.AuthenticateNewUser()
+	h1.addSession(newTestSubscriber(other, phone))
+
+	// act
+	waitFor(t, func() bool {
+		_, ok := c2.Users()[receiver]
+		return ok && len(c2.Users()) == 2
+	})
+	h2.relay(sender, []int32{receiver}, []byte("g'day"), relayOptions{})
+
+	// assert
+	bytes, msgType, err := messages.Decode(phoneClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgType != messages.MsgTypeRelay {
+		t.Errorf("relay failed. Expected %d, got %d", messages.MsgTypeRelay, msgType)
+	}
+	var result messages.Relay
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(result.Body) != "g'day" {
+		t.Errorf("relay failed. Expected %q, got %q", "g'day", result.Body)
+	}
+}
+
+func TestCluster_receipt(t *testing.T) {
+	// arrange
+	h1, c1 := newTestNode(t, 1)
+	h2, c2 := newTestNode(t, 2)
+	if err := c2.Join(c1.addr); err != nil {
+		t.Fatal(err)
+	}
+	phone, phoneClient := net.Pipe()
+	receiver, _ := h2.usersProvider.AuthenticateNewUser()
+	h2.addSession(newTestSubscriber(receiver, phone))
+	received := readFrames(phoneClient)
+	laptop, laptopClient := net.Pipe()
+	sender, _ := h1.usersProvider.AuthenticateNewUser()
+	senderSub := newTestSubscriber(sender, laptop)
+	h1.addSession(senderSub)
+	receipts := readFrames(laptopClient)
+	waitFor(t, func() bool {
+		_, ok := c1.Users()[receiver]
+		return ok
+	})
+
+	// act
+	h1.relay(sender, []int32{receiver}, []byte("g'day"), newRelayOptions(senderSub, 0, 5, messages.RelayRequest_NORMAL))
+
+	// assert, the receipt goes back to the session of the sender
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'day"), From: sender, Sequence: 1})
+	expectFrame(t, receipts, messages.MsgTypeRelayReceipt, &messages.RelayReceipt{Receipt: 5, Id: receiver, Status: messages.RelayReceipt_DELIVERED})
+}
+
+func TestCluster_addPeer(t *testing.T) {
+	// arrange
+	c := NewCluster(zap.L(), nil, 2, "", "secret", time.Second)
+	first, _ := net.Pipe()
+	second, secondClient := net.Pipe()
+	inbound := &peer{node: 3, conn: first}
+	outbound := &peer{node: 3, conn: second, outbound: true}
+
+	// act
+	added := c.addPeer(inbound) && c.addPeer(outbound)
+
+	// assert, connection initiated by the lower node wins
+	if !added || c.peers[3] != outbound {
+		t.Errorf("addPeer failed. Expected outbound peer, got %#v", c.peers[3])
+	}
+	if c.addPeer(&peer{node: 3, conn: first}) {
+		t.Error("addPeer failed. Duplicate peer is added")
+	}
+	secondClient.Close()
+}
+
+func TestCluster_handlePeer(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		// relays are sent by the peer, node 3, in order
+		relays []int32
+		want   int32
+	}{
+		{"wrong secret", "guess", []int32{3<<nodeShift | 1}, 0},
+		{"user of another node", "secret", []int32{2<<nodeShift | 1, 3<<nodeShift | 1}, 3<<nodeShift | 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			h, c := newTestNode(t, 1)
+			phone, phoneClient := net.Pipe()
+			receiver, _ := h.usersProvider.AuthenticateNewUser()
+			h.addSession(newTestSubscriber(receiver, phone))
+			frames := readFrames(phoneClient)
+			server, client := net.Pipe()
+			go c.handlePeer(server, false)
+
+			// act
+			authenticated := peerHandshake(t, client, 3, tt.secret)
+			for _, from := range tt.relays {
+				go client.Write(encode(t, &messages.PeerRelay{From: from, Ids: []int32{receiver}, Body: []byte("g'day")}, messages.MsgTypePeerRelay))
+			}
+
+			// assert
+			if !authenticated {
+				if tt.want != 0 {
+					t.Fatal("handlePeer failed. Expected peer authenticated")
+				}
+				return
+			}
+			expectFrame(t, frames, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'day"), From: tt.want, Sequence: 1})
+		})
+	}
+}
+
+func TestCluster_handlePeer_gossip(t *testing.T) {
+	// arrange
+	_, c := newTestNode(t, 1)
+	server, client := net.Pipe()
+	go c.handlePeer(server, false)
+	if !peerHandshake(t, client, 3, "secret") {
+		t.Fatal("handlePeer failed. Expected peer authenticated")
+	}
+
+	// act, the peer claims a user of node 2
+	go client.Write(encode(t, &messages.Gossip{Nodes: []*messages.NodePresence{
+		{Node: 3, Version: 1, Ids: []int32{3<<nodeShift | 1, 2<<nodeShift | 1}},
+		{Node: 4, Version: 1, Ids: []int32{4<<nodeShift | 1}},
+	}}, messages.MsgTypeGossip))
+
+	// assert
+	waitFor(t, func() bool { return len(c.Nodes()) != 0 })
+	if nodes := c.Nodes(); len(nodes) != 1 || nodes[0].Node != 4 {
+		t.Errorf("merge failed. Expected node 4 only, got %#v", nodes)
+	}
+}
+
+func TestCluster_forward_slowPeer(t *testing.T) {
+	// arrange, the peer doesn't read
+	c := NewCluster(zap.L(), nil, 2, "", "secret", time.Second)
+	conn, client := net.Pipe()
+	defer client.Close()
+	p := &peer{
+		node:  3,
+		conn:  conn,
+		enc:   messages.NewEncoder(conn),
+		queue: make(chan peerFrame, peerBacklog),
+		done:  make(chan struct{}),
+	}
+	c.addPeer(p)
+	go c.writeFrames(p)
+	defer close(p.done)
+
+	// act
+	start := time.Now()
+	var queued int
+	for i := 0; i < peerBacklog+2; i++ {
+		if c.forward(3, &messages.PeerRelay{From: 2<<nodeShift | 1, Ids: []int32{3<<nodeShift | 1}}) {
+			queued++
+		}
+	}
+
+	// assert
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("forward failed. Expected no waiting for the peer, took %s", elapsed)
+	}
+	if queued > peerBacklog+1 || queued < peerBacklog {
+		t.Errorf("forward failed. Expected up to %d relays queued, got %d", peerBacklog+1, queued)
+	}
+}
+
+// peerHandshake connects to a node over conn as node, it reports whether the node accepted secret
+func peerHandshake(t *testing.T, conn net.Conn, node int32, secret string) bool {
+	t.Helper()
+	bytes, _, err := messages.Decode(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remote messages.PeerHello
+	if err := proto.Unmarshal(bytes, &remote); err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("0123456789abcdef")
+	go conn.Write(encode(t, &messages.PeerHello{Node: node, Nonce: nonce}, messages.MsgTypePeerHello))
+	if _, msgType, err := messages.Decode(conn); err != nil || msgType != messages.MsgTypePeerAuth {
+		t.Fatalf("Expected PeerAuth, got %s %v", msgType, err)
+	}
+	mac := (&Cluster{secret: []byte(secret)}).mac(node, remote.Nonce)
+	go conn.Write(encode(t, &messages.PeerAuth{Mac: mac}, messages.MsgTypePeerAuth))
+	// an accepted peer gets gossip, a refused one is disconnected
+	_, msgType, err := messages.Decode(conn)
+	return err == nil && msgType == messages.MsgTypeGossip
+}
+
+func TestUsers_node(t *testing.T) {
+	// arrange
+	users := NewNodeUsers(2)
+
+	// act
+	id, err := users.AuthenticateNewUser()
+
+	// assert
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 2<<nodeShift|1 {
+		t.Errorf("AuthenticateNewUser failed. Expected %d, got %d", 2<<nodeShift|1, id)
+	}
+	if nodeOf(id) != 2 {
+		t.Errorf("nodeOf failed. Expected %d, got %d", 2, nodeOf(id))
+	}
+	if !users.Exists(id) || users.Exists(id+1) || NewNodeUsers(3).Exists(id) {
+		t.Error("Exists failed. Unexpected result")
+	}
+}
+
+func newTestNode(t *testing.T, node int32) (*Hub, *Cluster) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHub(zap.L(), nil, nil)
+	c := NewCluster(zap.L(), h, node, ln.Addr().String(), "secret", 20*time.Millisecond)
+	h.SetCluster(c)
+	go c.Serve(ln)
+	go c.Run()
+	t.Cleanup(func() {
+		ln.Close()
+		c.Close()
+	})
+
+	return h, c
+}
+
+// waitFor polls condition until it holds or a second passes
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const envPrefix = "HUB_"
+
+// Config is the hub configuration. Values are merged in order:
+// defaults, config file, HUB_SECTION_KEY environment variables, -section.key flags.
+type Config struct {
+	Listen   ListenConfig  `json:"listen"`
+	Limits   Limits        `json:"limits"`
+	Timeouts Timeouts      `json:"timeouts"`
+	TLS      TLSConfig     `json:"tls"`
+	Log      LogConfig     `json:"log"`
+	Store    StoreConfig   `json:"store"`
+	History  HistoryConfig `json:"history"`
+	Dedup    DedupConfig   `json:"dedup"`
+	Groups   GroupsConfig  `json:"groups"`
+	ACL      ACLConfig     `json:"acl"`
+	Admin    AdminConfig   `json:"admin"`
+	Cluster  ClusterConfig `json:"cluster"`
+}
+
+type ListenConfig struct {
+	// Addr lists client listeners, see Listeners
+	Addr    string `json:"addr" help:"comma separated client listeners: [tcp://|tls://|unix://]address[?option=value&...]"`
+	Metrics string `json:"metrics" help:"address to serve Prometheus metrics on, disabled if empty"`
+	Admin   string `json:"admin" help:"address to serve the admin API on, disabled if empty"`
+	// WebSocket clients connect to /ws, TLS applies to them as well
+	WebSocket string `json:"websocket" help:"address to accept WebSocket clients on at /ws, disabled if empty"`
+	// Protocol is auto, protobuf or json, see ProtocolAuto
+	Protocol string `json:"protocol" help:"default client protocol of listeners: auto (detects JSON lines), protobuf or json"`
+	// Compression lists codecs clients may negotiate
+	Compression string `json:"compression" help:"comma separated compression codecs accepted from clients (deflate, gzip), empty disables compression"`
+}
+
+// Codecs returns the compression codecs named in Compression
+func (c ListenConfig) Codecs() ([]messages.Codec, error) {
+	var codecs []messages.Codec
+	for _, name := range strings.Split(c.Compression, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		codec := messages.LookupCodec(name)
+		if codec == nil {
+			return nil, fmt.Errorf("unknown codec %q", name)
+		}
+		codecs = append(codecs, codec)
+	}
+	return codecs, nil
+}
+
+// ListenerConfig is a client listener from ListenConfig.Addr
+type ListenerConfig struct {
+	// Network is tcp or unix
+	Network string
+	Addr    string
+	TLS     bool
+	// Protocol is auto, protobuf or json
+	Protocol string
+	// RequireAuth rejects requests of clients until they sent an identity request
+	RequireAuth bool
+	// Limits override the hub limits where set, MaxConnections applies to this listener
+	Limits Limits
+}
+
+// Listeners parses Addr, a comma separated list of [scheme://]address[?option=value&...].
+// Schemes are tcp, tls and unix, addresses without scheme use TLS if tls is set.
+// Options are protocol, require_auth and limits: max_body, max_receivers,
+// max_connections and max_frame.
+func (c ListenConfig) Listeners(tls bool) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	for _, spec := range strings.Split(c.Addr, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		l, err := parseListener(spec, tls)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", spec, err.Error())
+		}
+		if l.Protocol == "" {
+			l.Protocol = c.Protocol
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func parseListener(spec string, tls bool) (ListenerConfig, error) {
+	l := ListenerConfig{Network: "tcp", TLS: tls}
+	if i := strings.Index(spec, "://"); i >= 0 {
+		switch scheme := spec[:i]; scheme {
+		case "tcp":
+			l.TLS = false
+		case "tls":
+			l.TLS = true
+		case "unix":
+			l.Network, l.TLS = "unix", false
+		default:
+			return l, fmt.Errorf("unknown scheme %q", scheme)
+		}
+		spec = spec[i+3:]
+	}
+
+	var query string
+	if i := strings.Index(spec, "?"); i >= 0 {
+		spec, query = spec[:i], spec[i+1:]
+	}
+	if spec == "" {
+		return l, fmt.Errorf("address is required")
+	}
+	l.Addr = spec
+
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		return l, err
+	}
+	limits := map[string]*int{
+		"max_body":        &l.Limits.MaxBody,
+		"max_receivers":   &l.Limits.MaxReceivers,
+		"max_connections": &l.Limits.MaxConnections,
+		"max_frame":       &l.Limits.MaxFrame,
+	}
+	for name, values := range options {
+		value := values[len(values)-1]
+		if limit, ok := limits[name]; ok {
+			if *limit, err = strconv.Atoi(value); err != nil || *limit <= 0 {
+				return l, fmt.Errorf("%s must be a positive number", name)
+			}
+			continue
+		}
+		switch name {
+		case "protocol":
+			l.Protocol = value
+		case "require_auth":
+			if l.RequireAuth, err = strconv.ParseBool(value); err != nil {
+				return l, fmt.Errorf("require_auth must be true or false")
+			}
+		default:
+			return l, fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return l, nil
+}
+
+// Limits can be changed at runtime
+type Limits struct {
+	MaxBody        int `json:"max_body" help:"max relay body length in bytes, longer bodies are truncated"`
+	MaxReceivers   int `json:"max_receivers" help:"max receivers per relay, the rest are ignored"`
+	MaxConnections int `json:"max_connections" help:"max simultaneous client connections, 0 for unlimited"`
+	MaxFrame       int `json:"max_frame" help:"max frame payload in bytes accepted from clients, longer frames close the connection"`
+}
+
+// Override returns l with the fields set in o replaced
+func (l Limits) Override(o Limits) Limits {
+	if o.MaxBody > 0 {
+		l.MaxBody = o.MaxBody
+	}
+	if o.MaxReceivers > 0 {
+		l.MaxReceivers = o.MaxReceivers
+	}
+	if o.MaxConnections > 0 {
+		l.MaxConnections = o.MaxConnections
+	}
+	if o.MaxFrame > 0 {
+		l.MaxFrame = o.MaxFrame
+	}
+	return l
+}
+
+// frameOverhead is the room a relay frame needs besides its body for receivers and field keys
+const frameOverhead = 64 * 1024
+
+// Timeouts can be changed at runtime, zero disables a timeout
+type Timeouts struct {
+	Read  Duration `json:"read" help:"close connections idle for longer than this"`
+	Write Duration `json:"write" help:"close connections not accepting a frame for longer than this"`
+}
+
+type TLSConfig struct {
+	CertFile string `json:"cert_file" help:"TLS certificate, enables TLS together with key_file"`
+	KeyFile  string `json:"key_file" help:"TLS private key"`
+}
+
+type LogConfig struct {
+	Level  string `json:"level" help:"log level: debug, info, warn or error"`
+	Format string `json:"format" help:"log format: json or console"`
+}
+
+type StoreConfig struct {
+	Users string `json:"users" help:"user registry backend: memory or file"`
+	Path  string `json:"path" help:"file keeping users, device keys and profiles of the file backend"`
+}
+
+type HistoryConfig struct {
+	Size          int `json:"size" help:"relays kept per conversation, 0 disables history"`
+	Conversations int `json:"conversations" help:"max conversations kept, the least recently active are dropped"`
+}
+
+type DedupConfig struct {
+	Window Duration `json:"window" help:"drop relays repeating an idempotency key within this window, 0 disables it"`
+	Size   int      `json:"size" help:"max idempotency keys kept, the oldest are forgotten first"`
+}
+
+type GroupsConfig struct {
+	Balance    string   `json:"balance" help:"picks the member of a queue group getting a relay: round_robin or least_loaded"`
+	AckTimeout Duration `json:"ack_timeout" help:"send a relay to a queue group to another member if it isn't acked within this, 0 waits until the member is lost"`
+}
+
+type ACLConfig struct {
+	File string `json:"file" help:"JSON file with access-control rules"`
+}
+
+type AdminConfig struct {
+	Token string `json:"token" help:"bearer token required by the admin API"`
+}
+
+// ClusterConfig makes the hub a node of a cluster if Node is set
+type ClusterConfig struct {
+	Node      int      `json:"node" help:"node id (1-127) prefixing issued user ids, 0 runs a standalone hub"`
+	Addr      string   `json:"addr" help:"address to accept connections from other hubs on"`
+	Advertise string   `json:"advertise" help:"address other hubs should connect to, defaults to addr"`
+	Peers     string   `json:"peers" help:"comma separated addresses of hubs to join"`
+	Secret    string   `json:"secret" help:"secret shared by all hubs of the cluster, peers without it are refused"`
+	Gossip    Duration `json:"gossip" help:"interval of presence gossip between hubs"`
+}
+
+// Duration is a time.Duration read from strings like "30s" or numbers of seconds
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("bad duration %s", data)
+	}
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Listen: ListenConfig{
+			Addr:        ":8888",
+			Protocol:    ProtocolAuto,
+			Compression: "deflate,gzip",
+		},
+		Limits: Limits{
+			MaxBody:      messages.BodyMaxLength,
+			MaxReceivers: messages.MaxReceivers,
+			MaxFrame:     messages.DefaultMaxFrame,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Store: StoreConfig{
+			Users: "memory",
+		},
+		History: HistoryConfig{
+			Conversations: 10000,
+		},
+		Dedup: DedupConfig{
+			Window: Duration(5 * time.Minute),
+			Size:   100000,
+		},
+		Groups: GroupsConfig{
+			Balance:    balanceRoundRobin,
+			AckTimeout: Duration(30 * time.Second),
+		},
+		Cluster: ClusterConfig{
+			Gossip: Duration(time.Second),
+		},
+	}
+}
+
+// ConfigLoader loads Config from a file, environment and command-line flags
+type ConfigLoader struct {
+	path  *string
+	flags map[string]string
+}
+
+// NewConfigLoader registers -config and a -section.key flag for every Config field in fs
+func NewConfigLoader(fs *flag.FlagSet) *ConfigLoader {
+	l := &ConfigLoader{
+		path:  fs.String("config", "", "path to the config file (.json, .yaml, .yml or .toml)"),
+		flags: make(map[string]string),
+	}
+	defaults := DefaultConfig()
+	walkConfig(&defaults, func(key string, field reflect.StructField, value reflect.Value) {
+		fs.Var(&flagValue{key: key, flags: l.flags, def: formatValue(value)}, key, field.Tag.Get("help"))
+	})
+
+	return l
+}
+
+// Load builds the configuration and validates it
+func (l *ConfigLoader) Load() (Config, error) {
+	cfg := DefaultConfig()
+	if *l.path != "" {
+		if err := loadConfigFile(*l.path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	var err error
+	walkConfig(&cfg, func(key string, field reflect.StructField, value reflect.Value) {
+		raw, ok := os.LookupEnv(envName(key))
+		if flagRaw, flagOK := l.flags[key]; flagOK {
+			raw, ok = flagRaw, true
+		}
+		if ok && err == nil {
+			if setErr := setValue(value, raw); setErr != nil {
+				err = fmt.Errorf("%s: %s", key, setErr.Error())
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	if !validProtocol(c.Listen.Protocol) {
+		return fmt.Errorf("listen.protocol must be auto, protobuf or json")
+	}
+	listeners, err := c.Listen.Listeners(c.TLS.CertFile != "")
+	if err != nil {
+		return fmt.Errorf("listen.addr: %s", err.Error())
+	}
+	if len(listeners) == 0 {
+		return fmt.Errorf("listen.addr is required")
+	}
+	for _, l := range listeners {
+		if !validProtocol(l.Protocol) {
+			return fmt.Errorf("listen.addr: %s: protocol must be auto, protobuf or json", l.Addr)
+		}
+		if l.TLS && c.TLS.CertFile == "" {
+			return fmt.Errorf("listen.addr: %s: tls.cert_file is required for TLS", l.Addr)
+		}
+		if limits := c.Limits.Override(l.Limits); limits.MaxFrame < limits.MaxBody+frameOverhead {
+			return fmt.Errorf("listen.addr: %s: max_frame must exceed max_body by at least %d bytes", l.Addr, frameOverhead)
+		}
+	}
+	if _, err := c.Listen.Codecs(); err != nil {
+		return fmt.Errorf("listen.compression: %s", err.Error())
+	}
+	if c.Limits.MaxBody <= 0 {
+		return fmt.Errorf("limits.max_body must be positive")
+	}
+	if c.Limits.MaxReceivers <= 0 {
+		return fmt.Errorf("limits.max_receivers must be positive")
+	}
+	if c.Limits.MaxFrame < c.Limits.MaxBody+frameOverhead {
+		return fmt.Errorf("limits.max_frame must exceed limits.max_body by at least %d bytes", frameOverhead)
+	}
+	if c.Limits.MaxConnections < 0 {
+		return fmt.Errorf("limits.max_connections must not be negative")
+	}
+	if c.Timeouts.Read < 0 || c.Timeouts.Write < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf("log.level: %s", err.Error())
+	}
+	if c.Log.Format != "json" && c.Log.Format != "console" {
+		return fmt.Errorf("log.format must be json or console")
+	}
+	switch c.Store.Users {
+	case "memory":
+	case "file":
+		if c.Store.Path == "" {
+			return fmt.Errorf("store.path is required by the file backend")
+		}
+	default:
+		return fmt.Errorf("unknown store.users backend %q", c.Store.Users)
+	}
+	if c.History.Size < 0 || c.History.Conversations < 0 {
+		return fmt.Errorf("history must not be negative")
+	}
+	if c.History.Size > 0 && c.History.Conversations == 0 {
+		return fmt.Errorf("history.conversations is required to keep history")
+	}
+	if c.Dedup.Window < 0 || c.Dedup.Size < 0 {
+		return fmt.Errorf("dedup must not be negative")
+	}
+	if c.Dedup.Window > 0 && c.Dedup.Size == 0 {
+		return fmt.Errorf("dedup.size is required to drop repeated relays")
+	}
+	if c.Groups.Balance != balanceRoundRobin && c.Groups.Balance != balanceLeastLoaded {
+		return fmt.Errorf("groups.balance must be %s or %s", balanceRoundRobin, balanceLeastLoaded)
+	}
+	if c.Groups.AckTimeout < 0 {
+		return fmt.Errorf("groups.ack_timeout must not be negative")
+	}
+	if c.Listen.Admin != "" && c.Admin.Token == "" {
+		return fmt.Errorf("admin.token is required to serve the admin API")
+	}
+	if c.Cluster.Node < 0 || c.Cluster.Node > MaxNodeID {
+		return fmt.Errorf("cluster.node must be between 0 and %d", MaxNodeID)
+	}
+	if c.Cluster.Node != 0 && c.Cluster.Addr == "" {
+		return fmt.Errorf("cluster.addr is required to join a cluster")
+	}
+	if c.Cluster.Node != 0 && c.Cluster.Secret == "" {
+		return fmt.Errorf("cluster.secret is required to join a cluster")
+	}
+	if c.Cluster.Node == 0 && c.Cluster.Peers != "" {
+		return fmt.Errorf("cluster.node is required to join peers")
+	}
+	if c.Cluster.Gossip <= 0 {
+		return fmt.Errorf("cluster.gossip must be positive")
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the configuration safe to show to operators
+func (c Config) Redacted() Config {
+	if c.Admin.Token != "" {
+		c.Admin.Token = "<redacted>"
+	}
+	if c.Cluster.Secret != "" {
+		c.Cluster.Secret = "<redacted>"
+	}
+	return c
+}
+
+func validProtocol(protocol string) bool {
+	switch protocol {
+	case ProtocolAuto, ProtocolProtobuf, ProtocolJSON:
+		return true
+	}
+	return false
+}
+
+// StaticChanges lists fields which differ from other but only take effect on restart
+func (c *Config) StaticChanges(other Config) []string {
+	var changed []string
+	if c.Listen != other.Listen {
+		changed = append(changed, "listen")
+	}
+	if c.TLS != other.TLS {
+		changed = append(changed, "tls")
+	}
+	if c.Log.Format != other.Log.Format {
+		changed = append(changed, "log.format")
+	}
+	if c.Store != other.Store {
+		changed = append(changed, "store")
+	}
+	if c.History != other.History {
+		changed = append(changed, "history")
+	}
+	if c.Dedup != other.Dedup {
+		changed = append(changed, "dedup")
+	}
+	if c.Groups != other.Groups {
+		changed = append(changed, "groups")
+	}
+	if c.ACL != other.ACL {
+		changed = append(changed, "acl")
+	}
+	if c.Admin != other.Admin {
+		changed = append(changed, "admin")
+	}
+	if c.Cluster != other.Cluster {
+		changed = append(changed, "cluster")
+	}
+	return changed
+}
+
+func loadConfigFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config failed: %s", err.Error())
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+	case ".yaml", ".yml":
+		data, err = yamlToJSON(data)
+	case ".toml":
+		data, err = tomlToJSON(data)
+	default:
+		return fmt.Errorf("unknown config format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse config failed: %s", err.Error())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parse config failed: %s", err.Error())
+	}
+	return nil
+}
+
+// walkConfig calls fn for every leaf field of cfg with its "section.key" name
+func walkConfig(cfg *Config, fn func(key string, field reflect.StructField, value reflect.Value)) {
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		sectionValue := root.Field(i)
+		for j := 0; j < sectionValue.NumField(); j++ {
+			field := sectionValue.Type().Field(j)
+			fn(section.Tag.Get("json")+"."+field.Tag.Get("json"), field, sectionValue.Field(j))
+		}
+	}
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == reflect.TypeOf(Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// flagValue records flags set on the command line so they can override the config file
+type flagValue struct {
+	key   string
+	def   string
+	flags map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	if v, ok := f.flags[f.key]; ok {
+		return v
+	}
+	return f.def
+}
+
+func (f *flagValue) Set(v string) error {
+	f.flags[f.key] = v
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigLoader_Load(t *testing.T) {
+	files := map[string]string{
+		"hub.yaml": `
+# hub settings
+listen:
+  addr: ":9999"   # clients
+  metrics: ':9100'
+limits:
+  max_body: 1024
+timeouts:
+  read: 30s
+`,
+		"hub.toml": `
+[listen]
+addr = ":9999"
+metrics = ":9100"
+
+[limits]
+max_body = 1024
+
+[timeouts]
+read = "30s"
+`,
+		"hub.json": `{
+			"listen": {"addr": ":9999", "metrics": ":9100"},
+			"limits": {"max_body": 1024},
+			"timeouts": {"read": 30}
+		}`,
+	}
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			// arrange
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			fs := flag.NewFlagSet("hub", flag.ContinueOnError)
+			loader := NewConfigLoader(fs)
+			if err := fs.Parse([]string{"-config", path}); err != nil {
+				t.Fatal(err)
+			}
+
+			// act
+			cfg, err := loader.Load()
+
+			// assert
+			if err != nil {
+				t.Fatalf("Load failed. Unexpected err: %s", err.Error())
+			}
+			expected := DefaultConfig()
+			expected.Listen.Addr = ":9999"
+			expected.Listen.Metrics = ":9100"
+			expected.Limits.MaxBody = 1024
+			expected.Timeouts.Read = Duration(30 * time.Second)
+			if cfg != expected {
+				t.Errorf("Load failed. Expected %#v, got %#v", expected, cfg)
+			}
+		})
+	}
+}
+
+func TestConfigLoader_Load_scalars(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		token   string
+	}{
+		{"hub.yaml", `
+listen: {addr: ":9999"}
+admin:
+  token: 0042
+tls:
+  cert_file: |-
+    hub.pem
+  key_file: hub.key
+`, "0042"},
+		{"hub.toml", `
+listen = { addr = ":9999" }
+
+[admin]
+token = 42
+
+[tls]
+cert_file = """
+hub.pem"""
+key_file = "hub.key"
+`, "42"},
+	}
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			path := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg := DefaultConfig()
+
+			// act
+			err := loadConfigFile(path, &cfg)
+
+			// assert
+			if err != nil {
+				t.Fatalf("loadConfigFile failed. Unexpected err: %s", err.Error())
+			}
+			if cfg.Listen.Addr != ":9999" || cfg.Admin.Token != tt.token || cfg.TLS.CertFile != "hub.pem" {
+				t.Errorf("loadConfigFile failed. Expected token %q, got %#v", tt.token, cfg)
+			}
+		})
+	}
+}
+
+func TestConfigLoader_precedence(t *testing.T) {
+	// arrange
+	f, err := ioutil.TempFile("", "config*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("limits:\n  max_body: 10\n  max_receivers: 20\n  max_connections: 30\n")
+	f.Close()
+
+	os.Setenv("HUB_LIMITS_MAX_RECEIVERS", "21")
+	os.Setenv("HUB_LIMITS_MAX_CONNECTIONS", "31")
+	defer os.Unsetenv("HUB_LIMITS_MAX_RECEIVERS")
+	defer os.Unsetenv("HUB_LIMITS_MAX_CONNECTIONS")
+
+	fs := flag.NewFlagSet("hub", flag.ContinueOnError)
+	loader := NewConfigLoader(fs)
+	err = fs.Parse([]string{"-config", f.Name(), "-limits.max_connections", "32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	cfg, err := loader.Load()
+
+	// assert
+	if err != nil {
+		t.Fatalf("Load failed. Unexpected err: %s", err.Error())
+	}
+	expected := Limits{MaxBody: 10, MaxReceivers: 21, MaxConnections: 32, MaxFrame: DefaultConfig().Limits.MaxFrame}
+	if cfg.Limits != expected {
+		t.Errorf("Load failed. Expected %#v, got %#v", expected, cfg.Limits)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"no listen address", func(c *Config) { c.Listen.Addr = "" }},
+		{"zero max body", func(c *Config) { c.Limits.MaxBody = 0 }},
+		{"negative timeout", func(c *Config) { c.Timeouts.Write = -1 }},
+		{"tls without key", func(c *Config) { c.TLS.CertFile = "cert.pem" }},
+		{"bad log level", func(c *Config) { c.Log.Level = "loud" }},
+		{"unknown store", func(c *Config) { c.Store.Users = "mongo" }},
+		{"file store without path", func(c *Config) { c.Store.Users = "file" }},
+		{"negative history", func(c *Config) { c.History.Size = -1 }},
+		{"dedup without size", func(c *Config) { c.Dedup.Size = 0 }},
+		{"unknown group balance", func(c *Config) { c.Groups.Balance = "random" }},
+		{"negative ack timeout", func(c *Config) { c.Groups.AckTimeout = -1 }},
+		{"admin without token", func(c *Config) { c.Listen.Admin = ":9200" }},
+		{"cluster without secret", func(c *Config) { c.Cluster.Node, c.Cluster.Addr = 1, ":7000" }},
+		{"unknown codec", func(c *Config) { c.Listen.Compression = "deflate,lz4" }},
+		{"unknown protocol", func(c *Config) { c.Listen.Protocol = "xml" }},
+		{"max frame below max body", func(c *Config) { c.Limits.MaxFrame = c.Limits.MaxBody }},
+		{"unknown listener scheme", func(c *Config) { c.Listen.Addr = "udp://:8888" }},
+		{"unknown listener option", func(c *Config) { c.Listen.Addr = ":8888?auth=yes" }},
+		{"unknown listener protocol", func(c *Config) { c.Listen.Addr = ":8888?protocol=xml" }},
+		{"tls listener without cert", func(c *Config) { c.Listen.Addr = "tls://:8889" }},
+		{"listener max body above max frame", func(c *Config) { c.Listen.Addr = ":8888?max_body=8388608" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate failed. Expected error")
+			}
+		})
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate failed. Unexpected err for defaults: %s", err.Error())
+	}
+}
+
+func TestListenConfig_Listeners(t *testing.T) {
+	// arrange
+	c := ListenConfig{
+		Addr:     ":8888, unix:///run/hub.sock?require_auth=false&protocol=json, tls://:8889?require_auth=true&max_body=1024&max_connections=10",
+		Protocol: ProtocolAuto,
+	}
+
+	// act
+	listeners, err := c.Listeners(true)
+
+	// assert
+	if err != nil {
+		t.Fatalf("Listeners failed. Unexpected err: %s", err.Error())
+	}
+	expected := []ListenerConfig{
+		{Network: "tcp", Addr: ":8888", TLS: true, Protocol: ProtocolAuto},
+		{Network: "unix", Addr: "/run/hub.sock", Protocol: ProtocolJSON},
+		{Network: "tcp", Addr: ":8889", TLS: true, Protocol: ProtocolAuto, RequireAuth: true, Limits: Limits{MaxBody: 1024, MaxConnections: 10}},
+	}
+	if !reflect.DeepEqual(expected, listeners) {
+		t.Errorf("Listeners failed. Expected %#v, got %#v", expected, listeners)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML and TOML files are read with full parsers and converted to JSON decoded into Config.
+// Scalars given to string fields keep their text, so `secret: 0042` reads as "0042" rather
+// than a number; TOML numbers there are written out as they read.
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return []byte("{}"), nil
+	}
+
+	v, err := yamlValue(doc.Content[0], reflect.TypeOf(Config{}))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// yamlValue converts node read for a field of type t, nil if the field is unknown
+func yamlValue(node *yaml.Node, t reflect.Type) (interface{}, error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch {
+	case node.Kind == yaml.MappingNode:
+		m := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			v, err := yamlValue(node.Content[i+1], fieldType(t, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case node.Kind == yaml.ScalarNode && node.Tag != "!!null" && isString(t):
+		return node.Value, nil
+	}
+
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, fmt.Errorf("line %d: %s", node.Line, err.Error())
+	}
+	return v, nil
+}
+
+func tomlToJSON(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tomlValue(doc, reflect.TypeOf(Config{})))
+}
+
+// tomlValue converts v read for a field of type t, nil if the field is unknown
+func tomlValue(v interface{}, t reflect.Type) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = tomlValue(value, fieldType(t, key))
+		}
+		return v
+	case string, []interface{}, []map[string]interface{}:
+		return v
+	}
+	if isString(t) {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+// fieldType returns the type of the field of struct t decoded from JSON key, nil if there is none
+func fieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("json"), ",")[0] == key {
+			return field.Type
+		}
+	}
+	return nil
+}
+
+func isString(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.String
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Dedup remembers idempotency keys of relays for a window, so retries of a relay the hub
+// already took are dropped. Keys are scoped to their sender, the oldest are forgotten first
+// once size keys are kept. A nil Dedup remembers nothing.
+type Dedup struct {
+	window time.Duration
+	size   int
+
+	lock sync.Mutex
+	seen map[dedupKey]struct{}
+	// order holds keys oldest first
+	order []dedupEntry
+}
+
+type dedupKey struct {
+	sender int32
+	key    string
+}
+
+type dedupEntry struct {
+	dedupKey
+	at time.Time
+}
+
+// NewDedup remembers up to size keys for window each
+func NewDedup(window time.Duration, size int) *Dedup {
+	return &Dedup{
+		window: window,
+		size:   size,
+		seen:   make(map[dedupKey]struct{}),
+	}
+}
+
+// Seen reports whether sender relayed with key within the window and remembers the key otherwise
+func (d *Dedup) Seen(sender int32, key string) bool {
+	if d == nil {
+		return false
+	}
+	now := time.Now()
+	k := dedupKey{sender: sender, key: key}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.expire(now)
+	if _, ok := d.seen[k]; ok {
+		return true
+	}
+	for len(d.order) >= d.size && len(d.order) > 0 {
+		d.forgetOldest()
+	}
+	d.seen[k] = struct{}{}
+	d.order = append(d.order, dedupEntry{dedupKey: k, at: now})
+	return false
+}
+
+// expire forgets keys older than the window, the caller must hold the lock
+func (d *Dedup) expire(now time.Time) {
+	for len(d.order) > 0 && now.Sub(d.order[0].at) >= d.window {
+		d.forgetOldest()
+	}
+}
+
+func (d *Dedup) forgetOldest() {
+	delete(d.seen, d.order[0].dedupKey)
+	d.order[0] = dedupEntry{}
+	d.order = d.order[1:]
+}
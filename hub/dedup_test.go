@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestDedup_Seen(t *testing.T) {
+	// arrange
+	dedup := NewDedup(20*time.Millisecond, 2)
+
+	// act, assert
+	if dedup.Seen(1, "a") || !dedup.Seen(1, "a") {
+		t.Error("Seen failed. Expected a repeated key within the window")
+	}
+	if dedup.Seen(2, "a") {
+		t.Error("Seen failed. Expected keys scoped to their sender")
+	}
+	// a third key pushes out the oldest one
+	if dedup.Seen(1, "b") || dedup.Seen(1, "a") {
+		t.Error("Seen failed. Expected the oldest key forgotten")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if dedup.Seen(1, "b") {
+		t.Error("Seen failed. Expected the key forgotten after the window")
+	}
+	if (*Dedup)(nil).Seen(1, "a") {
+		t.Error("Seen failed. Expected nil Dedup to remember nothing")
+	}
+}
+
+func TestHub_relayRequest_dedup(t *testing.T) {
+	// arrange
+	receiverConn, receiverClient := net.Pipe()
+	senderConn, _ := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+		dedup:       NewDedup(time.Minute, 10),
+	}
+	h.addSession(newTestSubscriber(123, receiverConn))
+	sender := newTestSubscriber(234, senderConn)
+	received := readFrames(receiverClient)
+
+	// act
+	requests := []*messages.RelayRequest{
+		// an invalid relay doesn't take its key
+		{Ids: []int32{123}, Body: []byte("invalid"), IdempotencyKey: "k1", Group: strings.Repeat("g", messages.GroupNameMaxLength+1)},
+		{Ids: []int32{123}, Body: []byte("first"), IdempotencyKey: "k1"},
+		{Ids: []int32{123}, Body: []byte("retry"), IdempotencyKey: "k1"},
+		{Ids: []int32{123}, Body: []byte("other"), IdempotencyKey: "k2"},
+	}
+	for _, request := range requests {
+		frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, request, messages.MsgTypeRelayRequest)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.relayRequest(sender, frame)
+	}
+
+	// assert
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("first"), From: 234, Sequence: 1})
+	// the retry is dropped without taking a sequence number
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("other"), From: 234, Sequence: 2})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// Queue groups spread relays over the sessions which joined them: a relay sent to a group
+// goes to a single member, picked round robin or as the member with the shortest outbound
+// queue. The member acks the relay with JobAck once it took it, relays a member didn't ack
+// by the time its session is lost or within the ack timeout are sent to another member, so a
+// relay may be handled twice but is not lost while the group has members. A member nacking a
+// relay gets it again after the ack timeout if the group has no other member. Groups are local
+// to a hub, their relays are neither sequenced nor kept in history.
+const (
+	balanceRoundRobin  = "round_robin"
+	balanceLeastLoaded = "least_loaded"
+)
+
+// groupJob is a relay to a queue group waiting for the ack of the member it was given to
+type groupJob struct {
+	id     uint64
+	from   int32
+	group  string
+	body   []byte
+	opts   relayOptions
+	member *subscriber
+	// timer sends the job to another member once the ack timeout passes, nil without timeout
+	timer *time.Timer
+}
+
+type queueGroup struct {
+	members []*subscriber
+	// next is the member to be tried first
+	next int
+}
+
+// groupTable holds queue groups with their unacked jobs, its zero value balances round robin
+// and waits for acks until members are lost
+type groupTable struct {
+	lock       sync.Mutex
+	balance    string
+	ackTimeout time.Duration
+	groups     map[string]*queueGroup
+	// joined lists the groups of every member session
+	joined map[*subscriber][]string
+	// unacked holds the jobs of every member session by id
+	unacked map[*subscriber]map[uint64]*groupJob
+	lastJob uint64
+}
+
+// join adds sub to the group name and returns its groups, the caller must hold the lock
+func (t *groupTable) join(sub *subscriber, name string) ([]string, error) {
+	joined := t.joined[sub]
+	for _, group := range joined {
+		if group == name {
+			return joined, nil
+		}
+	}
+	if len(joined) >= messages.MaxGroups {
+		return joined, fmt.Errorf("session is in %d groups already", len(joined))
+	}
+	if t.groups == nil {
+		t.groups = make(map[string]*queueGroup)
+		t.joined = make(map[*subscriber][]string)
+		t.unacked = make(map[*subscriber]map[uint64]*groupJob)
+	}
+	group := t.groups[name]
+	if group == nil {
+		group = &queueGroup{}
+		t.groups[name] = group
+	}
+	group.members = append(group.members, sub)
+	t.joined[sub] = append(joined, name)
+	return t.joined[sub], nil
+}
+
+// leave removes sub from the group name and returns its groups, the caller must hold the lock
+func (t *groupTable) leave(sub *subscriber, name string) []string {
+	joined := t.joined[sub]
+	for i, group := range joined {
+		if group == name {
+			joined = append(joined[:i:i], joined[i+1:]...)
+			break
+		}
+	}
+	if len(joined) == 0 {
+		delete(t.joined, sub)
+	} else {
+		t.joined[sub] = joined
+	}
+
+	group := t.groups[name]
+	if group == nil {
+		return joined
+	}
+	for i, member := range group.members {
+		if member == sub {
+			group.members = append(group.members[:i:i], group.members[i+1:]...)
+			if group.next > i {
+				group.next--
+			}
+			break
+		}
+	}
+	if len(group.members) == 0 {
+		delete(t.groups, name)
+	}
+	return joined
+}
+
+// pick returns the member of group next in turn or, balancing by load, the one with the
+// fewest relays queued, nil if allowed rejects all of them. The caller must hold the lock.
+func (t *groupTable) pick(group *queueGroup, allowed func(*subscriber) bool) *subscriber {
+	n := len(group.members)
+	picked, pickedAt, pickedDepth := -1, 0, 0
+	for i := 0; i < n; i++ {
+		at := (group.next + i) % n
+		member := group.members[at]
+		if !allowed(member) {
+			continue
+		}
+		if t.balance != balanceLeastLoaded {
+			picked, pickedAt = i, at
+			break
+		}
+		// ties go to the member next in turn
+		if depth := member.outbox.depth(); picked < 0 || depth < pickedDepth {
+			picked, pickedAt, pickedDepth = i, at, depth
+		}
+	}
+	if picked < 0 {
+		return nil
+	}
+	group.next = (pickedAt + 1) % n
+	return group.members[pickedAt]
+}
+
+// hold keeps job given to member until it is acked, the caller must hold the lock
+func (t *groupTable) hold(member *subscriber, job *groupJob) {
+	job.member = member
+	if t.unacked[member] == nil {
+		t.unacked[member] = make(map[uint64]*groupJob)
+	}
+	t.unacked[member][job.id] = job
+}
+
+// release forgets the job of member and returns it, nil if the job isn't waiting for
+// an ack of member. The caller must hold the lock.
+func (t *groupTable) release(member *subscriber, id uint64) *groupJob {
+	job, ok := t.unacked[member][id]
+	if !ok {
+		return nil
+	}
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	delete(t.unacked[member], id)
+	if len(t.unacked[member]) == 0 {
+		delete(t.unacked, member)
+	}
+	return job
+}
+
+// SetGroupBalance picks the member of a queue group getting a relay, balanceRoundRobin
+// or balanceLeastLoaded, it must be called before Run
+func (h *Hub) SetGroupBalance(balance string) {
+	h.groups.balance = balance
+}
+
+// SetGroupAckTimeout sends relays to queue groups to another member if the member they were
+// given to doesn't ack them within timeout, 0 waits until the member is lost. It must be
+// called before Run.
+func (h *Hub) SetGroupAckTimeout(timeout time.Duration) {
+	h.groups.ackTimeout = timeout
+}
+
+// groupRequest adds the session to a queue group or removes it and responds with its groups
+func (h *Hub) groupRequest(sub *subscriber, request *messages.Request) error {
+	var err error
+	var groups []string
+	h.groups.lock.Lock()
+	switch {
+	case sub.id == 0:
+		err = fmt.Errorf("group request before identity")
+	case request.Group == "" || len(request.Group) > messages.GroupNameMaxLength:
+		err = fmt.Errorf("group name must be 1 to %d bytes", messages.GroupNameMaxLength)
+	case request.Type == messages.Request_JOIN:
+		groups, err = h.groups.join(sub, request.Group)
+	default:
+		groups = h.groups.leave(sub, request.Group)
+	}
+	if err != nil {
+		groups = h.groups.joined[sub]
+	}
+	groupResp := &messages.GroupResponse{Groups: append([]string(nil), groups...)}
+	h.groups.lock.Unlock()
+
+	// the response tells the client which groups it is in even if the request failed
+	if sendErr := h.send(sub, groupResp, messages.MsgTypeGroupResponse); err == nil {
+		err = sendErr
+	}
+	return err
+}
+
+// groupRelay queues a relay to one member of its queue group, the body is copied as the
+// relay may have to be sent again to another member
+func (h *Hub) groupRelay(sender int32, group string, body []byte, opts relayOptions) {
+	job := &groupJob{from: sender, group: group, body: append([]byte(nil), body...), opts: opts}
+	h.groups.lock.Lock()
+	delivered := h.routeJob(job, nil)
+	h.groups.lock.Unlock()
+	if !delivered {
+		h.logger.Info("no member of group, dropping relay", zap.Int32("from", sender), zap.String("group", group))
+		h.metrics.MessageDropped(dropOffline)
+		h.metrics.RelayFanout(0)
+		if opts.call != 0 {
+			h.noResponder(sender, opts.call)
+		}
+		return
+	}
+	h.metrics.RelayFanout(1)
+}
+
+// routeJob queues job to a member of its group other than skipped and waits for the ack, it
+// returns false if the group has no member the sender may relay to. The caller must hold the
+// groups lock.
+func (h *Hub) routeJob(job *groupJob, skipped *subscriber) bool {
+	group := h.groups.groups[job.group]
+	if group == nil {
+		return false
+	}
+	member := h.groups.pick(group, func(member *subscriber) bool {
+		return member != skipped && (job.from == serverUserID || h.acl.CanRelay(job.from, member.id))
+	})
+	if member == nil {
+		return false
+	}
+
+	if job.id == 0 {
+		h.groups.lastJob++
+		job.id = h.groups.lastJob
+		job.opts.job = job.id
+	}
+	h.groups.hold(member, job)
+	h.awaitAck(job)
+
+	frame := encodeJob(job)
+	h.metrics.FrameQueued()
+	if member.outbox.push(queuedRelay{frame: frame, opts: job.opts, from: job.from}) {
+		go h.drain(member)
+	}
+	return true
+}
+
+// awaitAck sends job to another member if its member doesn't ack it within the ack timeout,
+// the caller must hold the groups lock
+func (h *Hub) awaitAck(job *groupJob) {
+	if h.groups.ackTimeout <= 0 {
+		job.timer = nil
+		return
+	}
+	member := job.member
+	job.timer = time.AfterFunc(h.groups.ackTimeout, func() {
+		h.retryJob(member, job.id, false)
+	})
+}
+
+// retryJob sends a job member didn't take to another member of its group. If there is no
+// other member, a job nacked by member waits for the ack timeout and one not acked in time
+// goes to member again.
+func (h *Hub) retryJob(member *subscriber, id uint64, nacked bool) {
+	h.groups.lock.Lock()
+	job := h.groups.release(member, id)
+	if job == nil {
+		// acked or sent to another member meanwhile
+		h.groups.lock.Unlock()
+		return
+	}
+	if _, expired := job.opts.ttl(); expired {
+		h.groups.lock.Unlock()
+		h.metrics.MessageDropped(dropExpired)
+		return
+	}
+	if h.routeJob(job, member) {
+		h.groups.lock.Unlock()
+		h.logger.Info("job not taken, sent to another member", zap.Uint64("job", id), zap.String("group", job.group), zap.Bool("nacked", nacked))
+		h.metrics.GroupFailover()
+		return
+	}
+	if nacked {
+		h.groups.hold(member, job)
+		h.awaitAck(job)
+		h.groups.lock.Unlock()
+		return
+	}
+	delivered := h.routeJob(job, nil)
+	h.groups.lock.Unlock()
+	if !delivered {
+		h.logger.Info("no member of group left, dropping relay", zap.Int32("from", job.from), zap.String("group", job.group))
+		h.metrics.MessageDropped(dropOffline)
+		if job.opts.call != 0 {
+			h.noResponder(job.from, job.opts.call)
+		}
+	}
+}
+
+// jobAck forgets a job acked by the member it was given to, a nacked job is sent to
+// another member
+func (h *Hub) jobAck(sub *subscriber, bytes []byte) error {
+	var ack messages.JobAck
+	if err := proto.Unmarshal(bytes, &ack); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+	if ack.Nack {
+		h.retryJob(sub, ack.Job, true)
+		return nil
+	}
+	h.groups.lock.Lock()
+	job := h.groups.release(sub, ack.Job)
+	h.groups.lock.Unlock()
+	if job == nil {
+		return fmt.Errorf("unknown job %d", ack.Job)
+	}
+	return nil
+}
+
+// leaveGroups removes a lost session from its queue groups and sends the jobs it didn't
+// ack to other members in the order they were sent
+func (h *Hub) leaveGroups(sub *subscriber) {
+	h.groups.lock.Lock()
+	for _, name := range append([]string(nil), h.groups.joined[sub]...) {
+		h.groups.leave(sub, name)
+	}
+	jobs := make([]*groupJob, 0, len(h.groups.unacked[sub]))
+	for id := range h.groups.unacked[sub] {
+		jobs = append(jobs, h.groups.release(sub, id))
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].id < jobs[j].id })
+
+	var lost []*groupJob
+	for _, job := range jobs {
+		if _, expired := job.opts.ttl(); expired {
+			h.metrics.MessageDropped(dropExpired)
+			continue
+		}
+		if !h.routeJob(job, nil) {
+			lost = append(lost, job)
+			continue
+		}
+		h.metrics.GroupFailover()
+	}
+	h.groups.lock.Unlock()
+
+	for _, job := range lost {
+		h.logger.Info("no member of group left, dropping relay", zap.Int32("from", job.from), zap.String("group", job.group))
+		h.metrics.MessageDropped(dropOffline)
+		if job.opts.call != 0 {
+			h.noResponder(job.from, job.opts.call)
+		}
+	}
+}
+
+func encodeJob(job *groupJob) *messages.Frame {
+	relay := &messages.Relay{
+		Body:   job.body,
+		Call:   job.opts.call,
+		Reply:  job.opts.reply,
+		Error:  job.opts.replyError,
+		Group:  job.group,
+		Job:    job.id,
+		Sealed: job.opts.sealed,
+	}
+	frame, err := messages.EncodeFrame(relay, messages.MsgTypeRelay)
+	if err != nil {
+		panic(fmt.Sprintf("Relay marshalling failed, %s", err))
+	}
+	return frame
+}
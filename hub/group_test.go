@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestGroupTable_pick(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance string
+		// queued are the relays waiting in the outboxes of members
+		queued []int
+		want   []int
+	}{
+		{"round robin", balanceRoundRobin, []int{0, 3, 1}, []int{0, 1, 2, 0}},
+		{"least loaded", balanceLeastLoaded, []int{2, 0, 1}, []int{1, 1, 1, 1}},
+		{"least loaded ties in turn", balanceLeastLoaded, []int{1, 0, 0}, []int{1, 2, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			table := groupTable{balance: tt.balance}
+			var members []*subscriber
+			for i, queued := range tt.queued {
+				conn, _ := net.Pipe()
+				member := newTestSubscriber(int32(i+1), conn)
+				for j := 0; j < queued; j++ {
+					member.outbox.push(testRelay('n', 1, messages.RelayRequest_NORMAL))
+				}
+				table.join(member, "jobs")
+				members = append(members, member)
+			}
+
+			// act
+			var got []int
+			for range tt.want {
+				picked := table.pick(table.groups["jobs"], func(*subscriber) bool { return true })
+				got = append(got, int(picked.id-1))
+			}
+
+			// assert
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("pick failed. Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupTable_leave(t *testing.T) {
+	// arrange
+	var table groupTable
+	var members []*subscriber
+	for i := int32(1); i <= 3; i++ {
+		conn, _ := net.Pipe()
+		members = append(members, newTestSubscriber(i, conn))
+		table.join(members[i-1], "jobs")
+	}
+	all := func(*subscriber) bool { return true }
+	table.pick(table.groups["jobs"], all)
+	table.pick(table.groups["jobs"], all)
+
+	// act
+	groups := table.leave(members[0], "jobs")
+
+	// assert
+	if len(groups) != 0 {
+		t.Errorf("leave failed. Expected no groups left, got %v", groups)
+	}
+	// the member next in turn stays next
+	if picked := table.pick(table.groups["jobs"], all); picked != members[2] {
+		t.Errorf("pick failed. Expected member %d, got %d", members[2].id, picked.id)
+	}
+	table.leave(members[1], "jobs")
+	table.leave(members[2], "jobs")
+	if len(table.groups) != 0 || len(table.joined) != 0 {
+		t.Errorf("leave failed. Expected empty group removed, got %v", table.groups)
+	}
+}
+
+func TestHub_groupRelay_failover(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	var members []*subscriber
+	var received []<-chan testFrame
+	for _, id := range []int32{123, 124} {
+		conn, client := net.Pipe()
+		member := newTestSubscriber(id, conn)
+		h.addSession(member)
+		frames := readFrames(client)
+		go h.groupRequest(member, &messages.Request{Type: messages.Request_JOIN, Group: "jobs"})
+		expectFrame(t, frames, messages.MsgTypeGroupResponse, &messages.GroupResponse{Groups: []string{"jobs"}})
+		members = append(members, member)
+		received = append(received, frames)
+	}
+	senderConn, _ := net.Pipe()
+	sender := newTestSubscriber(234, senderConn)
+	relay := func(body string) {
+		request := &messages.RelayRequest{Body: []byte(body), Group: "jobs"}
+		frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, request, messages.MsgTypeRelayRequest)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.relayRequest(sender, frame)
+		frame.Release()
+	}
+
+	// act
+	relay("first")
+	relay("second")
+	expectFrame(t, received[0], messages.MsgTypeRelay, &messages.Relay{Body: []byte("first"), From: 234, Group: "jobs", Job: 1})
+	expectFrame(t, received[1], messages.MsgTypeRelay, &messages.Relay{Body: []byte("second"), From: 234, Group: "jobs", Job: 2})
+	if err := h.jobAck(members[1], marshal(t, &messages.JobAck{Job: 2})); err != nil {
+		t.Fatal(err)
+	}
+	// the first member is lost before acking
+	h.removeSession(members[0])
+
+	// assert
+	expectFrame(t, received[1], messages.MsgTypeRelay, &messages.Relay{Body: []byte("first"), From: 234, Group: "jobs", Job: 1})
+	if err := h.jobAck(members[0], marshal(t, &messages.JobAck{Job: 1})); err == nil {
+		t.Error("jobAck failed. Expected error for a job given to another member")
+	}
+	if err := h.jobAck(members[1], marshal(t, &messages.JobAck{Job: 1})); err != nil {
+		t.Errorf("jobAck failed. Unexpected err: %s", err.Error())
+	}
+	h.removeSession(members[1])
+	if len(h.groups.groups) != 0 || len(h.groups.unacked) != 0 {
+		t.Errorf("removeSession failed. Expected no groups and jobs left, got %v %v", h.groups.groups, h.groups.unacked)
+	}
+}
+
+func TestHub_groupRelay_ackTimeout(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	h.SetGroupAckTimeout(100 * time.Millisecond)
+	var members []*subscriber
+	var received []<-chan testFrame
+	for _, id := range []int32{123, 124} {
+		conn, client := net.Pipe()
+		member := newTestSubscriber(id, conn)
+		h.addSession(member)
+		frames := readFrames(client)
+		go h.groupRequest(member, &messages.Request{Type: messages.Request_JOIN, Group: "jobs"})
+		expectFrame(t, frames, messages.MsgTypeGroupResponse, &messages.GroupResponse{Groups: []string{"jobs"}})
+		members = append(members, member)
+		received = append(received, frames)
+	}
+	job := &messages.Relay{Body: []byte("work"), From: 234, Group: "jobs", Job: 1}
+
+	// act
+	h.groupRelay(234, "jobs", []byte("work"), relayOptions{})
+	expectFrame(t, received[0], messages.MsgTypeRelay, job)
+
+	// assert, the job the first member didn't ack in time goes to the other
+	expectFrame(t, received[1], messages.MsgTypeRelay, job)
+	// a nacked job goes back right away
+	if err := h.jobAck(members[1], marshal(t, &messages.JobAck{Job: 1, Nack: true})); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, received[0], messages.MsgTypeRelay, job)
+	if err := h.jobAck(members[0], marshal(t, &messages.JobAck{Job: 1})); err != nil {
+		t.Errorf("jobAck failed. Unexpected err: %s", err.Error())
+	}
+	if len(h.groups.unacked) != 0 {
+		t.Errorf("jobAck failed. Expected no jobs left, got %v", h.groups.unacked)
+	}
+}
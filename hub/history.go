@@ -0,0 +1,285 @@
+package main
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// History keeps the latest relays of every conversation between two users in memory. Relays to
+// several receivers are kept in the multi-party conversation of the sender and receivers as well.
+// Message ids are unique per hub and increase across restarts, they start from the
+// time of the first relay in nanoseconds.
+type History struct {
+	// size is the number of relays kept per conversation
+	size int
+	// maxConversations caps conversations, the least recently active one is dropped first
+	maxConversations int
+
+	lock          sync.Mutex
+	lastID        uint64
+	conversations map[conversation]*list.Element
+	// recent orders conversations from the most recently active
+	recent *list.List
+	// byUser indexes conversations of every user for catch-up
+	byUser map[int32]map[conversation]struct{}
+	// metrics counts relays dropped as they expired, may be nil
+	metrics *Metrics
+}
+
+// conversation is a pair of users, the lower id first, or the members of a multi-party
+// conversation encoded by newParty
+type conversation struct {
+	a, b  int32
+	party string
+}
+
+type conversationLog struct {
+	conversation
+	entries []*messages.HistoryEntry
+}
+
+func newConversation(from, to int32) conversation {
+	if from > to {
+		from, to = to, from
+	}
+	return conversation{a: from, b: to}
+}
+
+// newParty returns the conversation of members, a pair if there are two of them and none
+// if there are fewer
+func newParty(members []int32) conversation {
+	sorted := append([]int32(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	distinct := sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			distinct = append(distinct, id)
+		}
+	}
+	switch len(distinct) {
+	case 0, 1:
+		return conversation{}
+	case 2:
+		return newConversation(distinct[0], distinct[1])
+	}
+
+	party := make([]byte, 4*len(distinct))
+	for i, id := range distinct {
+		binary.BigEndian.PutUint32(party[4*i:], uint32(id))
+	}
+	return conversation{party: string(party)}
+}
+
+// NewHistory keeps size relays in each of up to maxConversations conversations
+func NewHistory(size, maxConversations int) *History {
+	return &History{
+		size:             size,
+		maxConversations: maxConversations,
+		conversations:    make(map[conversation]*list.Element),
+		recent:           list.New(),
+		byUser:           make(map[int32]map[conversation]struct{}),
+	}
+}
+
+// Record keeps body relayed from sender to receivers until unix milliseconds expires, 0 keeps
+// it until it is pushed out, sealed bodies are kept as they were relayed. It returns the
+// message id and timestamp, body must not be modified afterwards.
+func (h *History) Record(from int32, to []int32, body []byte, expires int64, sealed bool) (uint64, int64) {
+	now := time.Now()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	id := h.lastID + 1
+	if nanos := uint64(now.UnixNano()); nanos > id {
+		id = nanos
+	}
+	h.lastID = id
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+
+	for _, receiver := range to {
+		if receiver == from {
+			continue
+		}
+		log := h.conversation(newConversation(from, receiver))
+		log.entries = append(log.entries, &messages.HistoryEntry{
+			Id:        id,
+			Timestamp: timestamp,
+			From:      from,
+			To:        receiver,
+			Body:      body,
+			Expires:   expires,
+			Sealed:    sealed,
+		})
+		h.trim(log)
+	}
+
+	if party := newParty(append([]int32{from}, to...)); party.party != "" {
+		receivers := make([]int32, 0, len(to))
+		for _, receiver := range to {
+			if receiver != from {
+				receivers = append(receivers, receiver)
+			}
+		}
+		log := h.conversation(party)
+		log.entries = append(log.entries, &messages.HistoryEntry{
+			Id:        id,
+			Timestamp: timestamp,
+			From:      from,
+			Receivers: receivers,
+			Body:      body,
+			Expires:   expires,
+			Sealed:    sealed,
+		})
+		h.trim(log)
+	}
+	return id, timestamp
+}
+
+// trim drops the oldest relay of log once it keeps more than size, the caller must hold the lock
+func (h *History) trim(log *conversationLog) {
+	if len(log.entries) > h.size {
+		log.entries[0] = nil
+		log.entries = log.entries[1:]
+	}
+}
+
+// conversation returns the log of c marked as the most recently active, the caller must hold the lock
+func (h *History) conversation(c conversation) *conversationLog {
+	if e, ok := h.conversations[c]; ok {
+		h.recent.MoveToFront(e)
+		return e.Value.(*conversationLog)
+	}
+
+	for len(h.conversations) >= h.maxConversations && h.recent.Len() > 0 {
+		oldest := h.recent.Remove(h.recent.Back()).(*conversationLog)
+		delete(h.conversations, oldest.conversation)
+		if oldest.party == "" {
+			h.unindex(oldest.a, oldest.conversation)
+			h.unindex(oldest.b, oldest.conversation)
+		}
+	}
+	log := &conversationLog{conversation: c}
+	h.conversations[c] = h.recent.PushFront(log)
+	// relays of multi-party conversations are caught up on in the pairs keeping them too
+	if c.party == "" {
+		h.index(c.a, c)
+		h.index(c.b, c)
+	}
+	return log
+}
+
+// purge drops expired relays from log, the caller must hold the lock
+func (h *History) purge(log *conversationLog, now int64) {
+	kept := log.entries[:0]
+	for _, entry := range log.entries {
+		if entry.Expires != 0 && entry.Expires <= now {
+			h.metrics.MessageDropped(dropExpired)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	for i := len(kept); i < len(log.entries); i++ {
+		log.entries[i] = nil
+	}
+	log.entries = kept
+}
+
+func (h *History) index(user int32, c conversation) {
+	if h.byUser[user] == nil {
+		h.byUser[user] = make(map[conversation]struct{})
+	}
+	h.byUser[user][c] = struct{}{}
+}
+
+func (h *History) unindex(user int32, c conversation) {
+	delete(h.byUser[user], c)
+	if len(h.byUser[user]) == 0 {
+		delete(h.byUser, user)
+	}
+}
+
+// Conversation returns up to limit relays between user and peer older than before, or the
+// newest ones if before is 0, oldest first. Bodies of a page add up to at most maxBytes
+// unless a single one is longer. next is the cursor of the older page, 0 if there is none.
+func (h *History) Conversation(user, peer int32, before uint64, limit, maxBytes int) ([]*messages.HistoryEntry, uint64) {
+	return h.page(newConversation(user, peer), before, limit, maxBytes)
+}
+
+// Party returns a page of the multi-party conversation of user and members like Conversation,
+// the conversation with the only member if there is one
+func (h *History) Party(user int32, members []int32, before uint64, limit, maxBytes int) ([]*messages.HistoryEntry, uint64) {
+	return h.page(newParty(append([]int32{user}, members...)), before, limit, maxBytes)
+}
+
+func (h *History) page(c conversation, before uint64, limit, maxBytes int) ([]*messages.HistoryEntry, uint64) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	e, ok := h.conversations[c]
+	if !ok {
+		return nil, 0
+	}
+	log := e.Value.(*conversationLog)
+	h.purge(log, now)
+	entries := log.entries
+	end := len(entries)
+	if before != 0 {
+		end = sort.Search(len(entries), func(i int) bool { return entries[i].Id >= before })
+	}
+
+	start, bytes := end, 0
+	for start > 0 && end-start < limit {
+		bytes += len(entries[start-1].Body)
+		if bytes > maxBytes && start < end {
+			break
+		}
+		start--
+	}
+	page := append([]*messages.HistoryEntry(nil), entries[start:end]...)
+	var next uint64
+	if start > 0 {
+		next = entries[start].Id
+	}
+	return page, next
+}
+
+// Since returns up to limit relays received by user after the relay with id since, oldest
+// first. Bodies of a page add up to at most maxBytes unless a single one is longer. next is
+// the cursor of the following page, 0 if there is none.
+func (h *History) Since(user int32, since uint64, limit, maxBytes int) ([]*messages.HistoryEntry, uint64) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	h.lock.Lock()
+	var received []*messages.HistoryEntry
+	for c := range h.byUser[user] {
+		log := h.conversations[c].Value.(*conversationLog)
+		h.purge(log, now)
+		entries := log.entries
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].Id > since })
+		for _, entry := range entries[i:] {
+			if entry.To == user {
+				received = append(received, entry)
+			}
+		}
+	}
+	h.lock.Unlock()
+	sort.Slice(received, func(i, j int) bool { return received[i].Id < received[j].Id })
+
+	end, bytes := 0, 0
+	for end < len(received) && end < limit {
+		bytes += len(received[end].Body)
+		if bytes > maxBytes && end > 0 {
+			break
+		}
+		end++
+	}
+	var next uint64
+	if end < len(received) {
+		next = received[end-1].Id
+	}
+	return received[:end], next
+}
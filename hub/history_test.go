@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestHistory_Conversation(t *testing.T) {
+	// arrange
+	history := NewHistory(3, 10)
+	var ids []uint64
+	for _, body := range []string{"a", "b", "c", "d"} {
+		id, _ := history.Record(1, []int32{2, 3}, []byte(body), 0, false)
+		ids = append(ids, id)
+	}
+	history.Record(3, []int32{1}, []byte("e"), 0, false)
+
+	// act
+	newest, next := history.Conversation(2, 1, 0, 2, 1024)
+	oldest, last := history.Conversation(1, 2, next, 2, 1024)
+
+	// assert
+	if bodies(newest) != "cd" || next != ids[2] {
+		t.Errorf("Conversation failed. Expected cd before %d, got %s before %d", ids[2], bodies(newest), next)
+	}
+	// the oldest relay was dropped as the conversation keeps 3
+	if bodies(oldest) != "b" || last != 0 {
+		t.Errorf("Conversation failed. Expected b and no older page, got %s before %d", bodies(oldest), last)
+	}
+	if entries, _ := history.Conversation(1, 3, 0, 10, 1024); bodies(entries) != "cde" {
+		t.Errorf("Conversation failed. Expected cde, got %s", bodies(entries))
+	}
+	// a page is cut to maxBytes but holds at least one relay
+	if entries, next := history.Conversation(1, 2, 0, 10, 1); bodies(entries) != "d" || next != ids[3] {
+		t.Errorf("Conversation failed. Expected d before %d, got %s before %d", ids[3], bodies(entries), next)
+	}
+}
+
+func TestHistory_Since(t *testing.T) {
+	// arrange
+	history := NewHistory(10, 10)
+	first, _ := history.Record(1, []int32{2}, []byte("a"), 0, false)
+	history.Record(3, []int32{2}, []byte("b"), 0, false)
+	history.Record(2, []int32{1}, []byte("c"), 0, false)
+	history.Record(1, []int32{2, 3}, []byte("d"), 0, false)
+
+	// act
+	page, next := history.Since(2, first, 1, 1024)
+	rest, last := history.Since(2, next, 10, 1024)
+
+	// assert
+	if bodies(page) != "b" {
+		t.Errorf("Since failed. Expected b, got %s", bodies(page))
+	}
+	// relays sent by the user are left out
+	if bodies(rest) != "d" || last != 0 {
+		t.Errorf("Since failed. Expected d and no next page, got %s %d", bodies(rest), last)
+	}
+}
+
+func TestHistory_Party(t *testing.T) {
+	// arrange
+	history := NewHistory(10, 10)
+	history.Record(1, []int32{2, 3}, []byte("a"), 0, false)
+	history.Record(2, []int32{3, 1}, []byte("b"), 0, false)
+	history.Record(1, []int32{2}, []byte("c"), 0, false)
+	history.Record(1, []int32{2, 3, 4}, []byte("d"), 0, false)
+
+	// act
+	entries, next := history.Party(3, []int32{2, 1, 2}, 0, 10, 1024)
+
+	// assert
+	if bodies(entries) != "ab" || next != 0 {
+		t.Fatalf("Party failed. Expected ab and no older page, got %s before %d", bodies(entries), next)
+	}
+	if entries[0].To != 0 || len(entries[0].Receivers) != 2 || entries[0].Receivers[0] != 2 || entries[0].Receivers[1] != 3 {
+		t.Errorf("Party failed. Expected receivers 2 and 3, got %#v", entries[0])
+	}
+	// a party of two is the conversation of the pair
+	if entries, _ := history.Party(1, []int32{2}, 0, 10, 1024); bodies(entries) != "abcd" {
+		t.Errorf("Party failed. Expected abcd, got %s", bodies(entries))
+	}
+	if entries, _ := history.Party(4, []int32{1, 2}, 0, 10, 1024); len(entries) != 0 {
+		t.Errorf("Party failed. Expected no relays of another party, got %s", bodies(entries))
+	}
+	// relays of a party are caught up on once
+	if entries, _ := history.Since(3, 0, 10, 1024); bodies(entries) != "abd" {
+		t.Errorf("Since failed. Expected abd, got %s", bodies(entries))
+	}
+}
+
+func TestHistory_evictConversation(t *testing.T) {
+	// arrange
+	history := NewHistory(10, 2)
+	history.Record(1, []int32{2}, []byte("a"), 0, false)
+	history.Record(1, []int32{3}, []byte("b"), 0, false)
+	history.Record(1, []int32{2}, []byte("c"), 0, false)
+
+	// act
+	history.Record(1, []int32{4}, []byte("d"), 0, false)
+
+	// assert
+	if entries, _ := history.Conversation(1, 3, 0, 10, 1024); len(entries) != 0 {
+		t.Errorf("Conversation failed. Expected least recently active conversation dropped, got %s", bodies(entries))
+	}
+	if entries, _ := history.Since(3, 0, 10, 1024); len(entries) != 0 {
+		t.Errorf("Since failed. Expected no relays, got %s", bodies(entries))
+	}
+	if entries, _ := history.Conversation(1, 2, 0, 10, 1024); bodies(entries) != "ac" {
+		t.Errorf("Conversation failed. Expected ac, got %s", bodies(entries))
+	}
+}
+
+func TestHistory_expired(t *testing.T) {
+	// arrange
+	history := NewHistory(10, 10)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	history.Record(1, []int32{2}, []byte("a"), now-1, false)
+	history.Record(1, []int32{2}, []byte("b"), now+time.Minute.Nanoseconds()/int64(time.Millisecond), false)
+	history.Record(1, []int32{2}, []byte("c"), 0, false)
+
+	// act
+	entries, _ := history.Conversation(2, 1, 0, 10, 1024)
+	received, _ := history.Since(2, 0, 10, 1024)
+
+	// assert
+	if bodies(entries) != "bc" {
+		t.Errorf("Conversation failed. Expected bc, got %s", bodies(entries))
+	}
+	if bodies(received) != "bc" {
+		t.Errorf("Since failed. Expected bc, got %s", bodies(received))
+	}
+}
+
+func TestHub_relayRequest_history(t *testing.T) {
+	// arrange
+	receiverConn, receiverClient := net.Pipe()
+	senderConn, senderClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+		history:     NewHistory(10, 10),
+	}
+	h.addSession(newTestSubscriber(123, receiverConn))
+	sender := newTestSubscriber(234, senderConn)
+	received := readFrames(receiverClient)
+	responses := readFrames(senderClient)
+
+	// act
+	frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, &messages.RelayRequest{Ids: []int32{123}, Body: []byte("g'day")}, messages.MsgTypeRelayRequest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.relayRequest(sender, frame)
+	relay := <-received
+	go h.historyRequest(sender, &messages.Request{Type: messages.Request_HISTORY, Peer: 123})
+
+	// assert
+	var result messages.Relay
+	if err := result.Unmarshal(relay.bytes); err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Body) != "g'day" || result.MessageId == 0 || result.Timestamp == 0 {
+		t.Fatalf("relayRequest failed. Expected relay with message id, got %#v", result)
+	}
+	expectFrame(t, responses, messages.MsgTypeHistoryResponse, &messages.HistoryResponse{
+		Entries: []*messages.HistoryEntry{
+			{Id: result.MessageId, Timestamp: result.Timestamp, From: 234, To: 123, Body: []byte("g'day")},
+		},
+	})
+}
+
+func TestHub_historyRequest_party(t *testing.T) {
+	// arrange
+	senderConn, senderClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+		history:     NewHistory(10, 10),
+	}
+	sender := newTestSubscriber(234, senderConn)
+	responses := readFrames(senderClient)
+	id, timestamp := h.history.Record(123, []int32{234, 345}, []byte("g'day"), 0, false)
+
+	// act
+	go h.historyRequest(sender, &messages.Request{Type: messages.Request_HISTORY, Ids: []int32{123, 345}})
+
+	// assert
+	expectFrame(t, responses, messages.MsgTypeHistoryResponse, &messages.HistoryResponse{
+		Entries: []*messages.HistoryEntry{
+			{Id: id, Timestamp: timestamp, From: 123, Receivers: []int32{234, 345}, Body: []byte("g'day")},
+		},
+	})
+}
+
+func TestHub_relayRequest_sealed(t *testing.T) {
+	// arrange
+	receiverConn, receiverClient := net.Pipe()
+	senderConn, _ := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+		history:     NewHistory(10, 10),
+	}
+	h.addSession(newTestSubscriber(123, receiverConn))
+	sender := newTestSubscriber(234, senderConn)
+	received := readFrames(receiverClient)
+
+	// act
+	frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, &messages.RelayRequest{Ids: []int32{123}, Body: []byte("sealed"), Sealed: true}, messages.MsgTypeRelayRequest)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.relayRequest(sender, frame)
+
+	// assert
+	var result messages.Relay
+	if err := result.Unmarshal((<-received).bytes); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Sealed || string(result.Body) != "sealed" {
+		t.Errorf("relayRequest failed. Expected sealed relay, got %#v", result)
+	}
+	entries, _ := h.history.Conversation(234, 123, 0, 10, 1024)
+	if len(entries) != 1 || !entries[0].Sealed {
+		t.Errorf("relayRequest failed. Expected sealed history entry, got %v", entries)
+	}
+}
+
+func bodies(entries []*messages.HistoryEntry) string {
+	var s string
+	for _, entry := range entries {
+		s += string(entry.Body)
+	}
+	return s
+}
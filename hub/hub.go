@@ -1,207 +1,1475 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"github.com/antonzhukov/go-tcp-messaging/messages"
 
 	"io"
+	"strings"
 
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"go.uber.org/zap"
 )
 
+// serverUserID is the sender of relays originated by the hub itself
+const serverUserID = 0
+
 type Hub struct {
-	ln            net.Listener
+	listeners     []*listener
 	usersProvider UserProvider
-	subscribers   map[int32]net.Conn
+	acl           *ACL
+	subscribers   map[int32]sessions
+	banned        map[string]struct{}
 	lock          sync.RWMutex
 	logger        *zap.Logger
+	metrics       *Metrics
+	cluster       *Cluster
+	history       *History
+	dedup         *Dedup
+	codecs        []messages.Codec
+	streams       streamTable
+	sequences     sequenceTable
+	groups        groupTable
+	settings      atomic.Value // *hubSettings
+	connections   int64
+}
+
+// hubSettings are the parts of Config which can be changed at runtime
+type hubSettings struct {
+	limits   Limits
+	timeouts Timeouts
 }
 
-func NewHub(logger *zap.Logger, ln net.Listener) *Hub {
+// listener accepts clients with its own settings, the users of all listeners are shared
+type listener struct {
+	net.Listener
+	config      ListenerConfig
+	connections int64
+}
+
+func NewHub(logger *zap.Logger, acl *ACL, metrics *Metrics) *Hub {
 	return &Hub{
-		ln:            ln,
 		usersProvider: NewUsers(),
-		subscribers:   make(map[int32]net.Conn),
+		acl:           acl,
+		subscribers:   make(map[int32]sessions),
+		banned:        make(map[string]struct{}),
 		logger:        logger,
+		metrics:       metrics,
+	}
+}
+
+// Configure applies limits and timeouts, it is safe to call while the hub is running
+func (h *Hub) Configure(limits Limits, timeouts Timeouts) {
+	h.settings.Store(&hubSettings{
+		limits:   limits,
+		timeouts: timeouts,
+	})
+}
+
+// SetCodecs sets compression codecs clients may negotiate, it must be called before Run
+func (h *Hub) SetCodecs(codecs []messages.Codec) {
+	h.codecs = codecs
+}
+
+// Listen adds a listener served by Run, it must be called before Run
+func (h *Hub) Listen(ln net.Listener, config ListenerConfig) {
+	h.listeners = append(h.listeners, &listener{Listener: ln, config: config})
+}
+
+// SetCluster makes the hub a node of cluster, it must be called before Run.
+// It resets users to an in-memory registry of the node, see SetUsers.
+func (h *Hub) SetCluster(cluster *Cluster) {
+	h.cluster = cluster
+	h.usersProvider = NewNodeUsers(cluster.node)
+}
+
+// SetHistory keeps relays in history, it must be called before Run
+func (h *Hub) SetHistory(history *History) {
+	if history != nil {
+		history.metrics = h.metrics
+	}
+	h.history = history
+}
+
+// SetDedup drops repeated relays with keys remembered by dedup, it must be called before Run
+func (h *Hub) SetDedup(dedup *Dedup) {
+	h.dedup = dedup
+}
+
+// SetUsers replaces the user registry, it must be called before Run and after SetCluster.
+// Block lists kept by users are applied to the ACL.
+func (h *Hub) SetUsers(users UserProvider) {
+	h.usersProvider = users
+	if h.acl == nil {
+		return
+	}
+	for id, ids := range users.BlockLists() {
+		h.acl.SetBlocked(id, ids)
+	}
+}
+
+func (h *Hub) currentSettings() *hubSettings {
+	if s, ok := h.settings.Load().(*hubSettings); ok {
+		return s
+	}
+	return &hubSettings{
+		limits: Limits{
+			MaxBody:      messages.BodyMaxLength,
+			MaxReceivers: messages.MaxReceivers,
+			MaxFrame:     messages.DefaultMaxFrame,
+		},
 	}
 }
 
+// Run serves all listeners until they are closed
 func (h *Hub) Run() {
-	// Accept connections
+	var wg sync.WaitGroup
+	for _, l := range h.listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			h.accept(l)
+		}(l)
+	}
+	wg.Wait()
+}
+
+// accept connections
+func (h *Hub) accept(l *listener) {
 	for {
-		conn, err := h.ln.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			fmt.Println(err)
+			h.logger.Error("accepting connections failed", zap.String("addr", l.config.Addr), zap.Error(err))
 			break
 		}
 
-		go h.handleConnection(conn)
+		if !h.admit(conn, l) {
+			continue
+		}
+
+		go h.serveConn(conn, l)
+	}
+}
+
+// serveConn detects the protocol of a client accepted from l and serves it
+func (h *Hub) serveConn(conn net.Conn, l *listener) {
+	defer h.release(l)
+	client, err := h.clientConn(conn, l.config.Protocol, h.currentSettings().limits.Override(l.config.Limits))
+	if err != nil {
+		h.logger.Debug("protocol detection failed", zap.Stringer("addr", conn.RemoteAddr()), zap.Error(err))
+		conn.Close()
+		return
+	}
+
+	h.serve(client, l)
+}
+
+// admit checks connection limits and bans, rejected connections are closed.
+// l is nil for connections of no listener, e.g. WebSocket clients.
+// An admitted connection counts against the limits until it is released.
+func (h *Hub) admit(conn net.Conn, l *listener) bool {
+	if h.isBanned(conn.RemoteAddr()) {
+		h.logger.Info("rejected banned connection", zap.Stringer("addr", conn.RemoteAddr()))
+		conn.Close()
+		return false
+	}
+
+	maxConnections := h.currentSettings().limits.MaxConnections
+	if n := atomic.AddInt64(&h.connections, 1); maxConnections > 0 && n > int64(maxConnections) {
+		atomic.AddInt64(&h.connections, -1)
+		h.logger.Warn("too many connections, rejecting", zap.Stringer("addr", conn.RemoteAddr()))
+		conn.Close()
+		return false
+	}
+	if l == nil {
+		return true
+	}
+	if n := atomic.AddInt64(&l.connections, 1); l.config.Limits.MaxConnections > 0 && n > int64(l.config.Limits.MaxConnections) {
+		h.release(l)
+		h.logger.Warn("too many connections on listener, rejecting", zap.String("listener", l.config.Addr), zap.Stringer("addr", conn.RemoteAddr()))
+		conn.Close()
+		return false
+	}
+	return true
+}
+
+// release frees the connection limits taken by admit
+func (h *Hub) release(l *listener) {
+	atomic.AddInt64(&h.connections, -1)
+	if l != nil {
+		atomic.AddInt64(&l.connections, -1)
 	}
 }
 
-// handleConnection reads requests from users
+// handleConnection reads requests from users connected through no listener
 func (h *Hub) handleConnection(conn net.Conn) {
+	h.serve(conn, nil)
+}
+
+// serve reads requests from a user connected through l
+func (h *Hub) serve(conn net.Conn, l *listener) {
+	h.metrics.ClientConnected()
+	sub := newSubscriber(conn)
+	sub.listener = l
+	// use channel to notify of closed connection
+	closeChan := make(chan bool)
 	// Close connection when this function ends
 	defer func() {
+		close(closeChan)
 		conn.Close()
+		h.dropStreams(sub)
+		h.metrics.ClientDisconnected()
 	}()
 
-	bufReader := bufio.NewReader(conn)
-	// use channel to notify of closed connection
-	closeChan := make(chan bool, 1)
+	dec := messages.NewDecoder(conn)
+	dec.SetMaxFrame(h.limits(sub).MaxFrame)
 
-	for {
-		bytes, msgType, err := messages.Decode(bufReader)
+	for first := true; ; first = false {
+		if timeout := h.currentSettings().timeouts.Read; timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(timeout)))
+		}
+		frame, err := dec.DecodeFrame()
 		if err == io.EOF {
-			closeChan <- true
 			break
 		}
 		if err != nil {
+			h.metrics.DecodeError()
 			h.logger.Error("receiving message failed", zap.Error(err))
 			return
 		}
+		msgType := frame.Type()
+		size := len(frame.Bytes())
+		sub.received(size)
+		h.metrics.FrameReceived(msgType, size)
+
+		// relay requests are routed with their bodies compressed
+		payload := frame.Payload()
+		if frame.Compressed() && msgType != messages.MsgTypeRelayRequest {
+			payload, err = messages.Decompress(frame, sub.codec, messages.MaxDecompressedLength)
+			if err != nil {
+				h.metrics.DecodeError()
+				h.logger.Error("decompressing message failed", zap.Stringer("type", msgType), zap.Error(err))
+				frame.Release()
+				continue
+			}
+		}
 
 		switch msgType {
 		case messages.MsgTypeUnknown:
 			h.logger.Info("received unknown message, skipping")
+		case messages.MsgTypeHello:
+			if !first {
+				h.logger.Info("received hello after the first frame, skipping")
+				break
+			}
+			if err := h.hello(sub, payload); err != nil {
+				h.logger.Info("handshake failed", zap.Error(err))
+				frame.Release()
+				return
+			}
 		case messages.MsgTypeRequest:
-			h.handleRequest(conn, bytes, closeChan)
+			h.handleRequest(sub, payload, closeChan)
 		case messages.MsgTypeRelayRequest:
 			h.logger.Info("new relay request")
-			h.relayRequest(bytes)
+			if h.authenticated(sub, msgType.String()) {
+				h.relayRequest(sub, frame)
+			}
+		case messages.MsgTypeStreamOpen, messages.MsgTypeStreamChunk, messages.MsgTypeStreamEnd,
+			messages.MsgTypeStreamAbort, messages.MsgTypeStreamAck:
+			if h.authenticated(sub, msgType.String()) {
+				h.handleStream(sub, msgType, payload)
+			}
+		case messages.MsgTypeJobAck:
+			if err := h.jobAck(sub, payload); err != nil {
+				h.logger.Info("job ack failed", zap.Error(err))
+			}
+		}
+		// receivers of a relay hold their own references to the frame
+		frame.Release()
+	}
+}
+
+// hello negotiates protocol version and features with the first frame of a client,
+// clients starting without hello are served as protocol version 1 with all features
+func (h *Hub) hello(sub *subscriber, bytes []byte) error {
+	var hello messages.Hello
+	if err := proto.Unmarshal(bytes, &hello); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+
+	local := messages.NewHello(h.limits(sub).MaxFrame)
+	if len(h.codecs) == 0 || sub.json {
+		local.Features = removeFeature(local.Features, messages.FeatureCompression)
+	}
+	welcome := messages.Negotiate(local, &hello)
+	if err := h.send(sub, welcome, messages.MsgTypeWelcome); err != nil {
+		return err
+	}
+	if welcome.Reason != "" {
+		return fmt.Errorf("client version %d rejected: %s", hello.Version, welcome.Reason)
+	}
+
+	sub.version = welcome.Version
+	sub.features = welcome.Features
+	sub.writeLock.Lock()
+	sub.enc.SetMaxFrame(int(hello.MaxFrame))
+	sub.writeLock.Unlock()
+	h.logger.Info("handshake passed", zap.Uint32("version", sub.version), zap.Strings("features", sub.features))
+	return nil
+}
+
+func removeFeature(features []string, feature string) []string {
+	kept := features[:0]
+	for _, f := range features {
+		if f != feature {
+			kept = append(kept, f)
 		}
 	}
+	return kept
 }
 
-func (h *Hub) handleRequest(conn net.Conn, bytes []byte, closeChan chan bool) {
+func (h *Hub) handleRequest(sub *subscriber, bytes []byte, closeChan chan bool) {
 	// parse message
 	var request messages.Request
 	err := proto.Unmarshal(bytes, &request)
 	if err != nil {
+		h.metrics.DecodeError()
 		h.logger.Error("unmarshal failed", zap.Error(err))
 		return
 	}
+	if request.Type != messages.Request_IDENTITY && !h.authenticated(sub, request.Type.String()) {
+		return
+	}
+	defer h.metrics.RequestHandled(strings.ToLower(request.Type.String()), time.Now())
 
 	switch request.Type {
 	case messages.Request_IDENTITY:
 		h.logger.Info("new identity request")
-		id, err := h.identityRequest(conn)
+		if sub.id != 0 {
+			h.logger.Error("identityRequest failed, session has identity already", zap.Int32("id", sub.id))
+			break
+		}
+		id, err := h.identityRequest(sub, &request)
 		if err != nil {
 			h.logger.Error("identityRequest failed", zap.Error(err))
 			break
 		}
+		sub.id = id
 		// subscribe all authenticated users to relay events
-		go h.subscribeUser(id, conn, closeChan)
+		go h.subscribeUser(sub, closeChan)
 	case messages.Request_LIST:
 		h.logger.Info("new list request")
-		h.listRequest(request.Id, conn)
+		h.listRequest(sub.id, &request, sub)
+	case messages.Request_BLOCK, messages.Request_UNBLOCK, messages.Request_BLOCK_LIST:
+		h.logger.Info("new block request", zap.Stringer("type", request.Type))
+		err := h.blockRequest(sub.id, request.Type, request.Ids, sub)
+		if err != nil {
+			h.logger.Error("blockRequest failed", zap.Error(err))
+		}
+	case messages.Request_PROFILE:
+		h.logger.Info("new profile request")
+		if err := h.profileRequest(sub, request.Profile); err != nil {
+			h.logger.Error("profileRequest failed", zap.Error(err))
+		}
+	case messages.Request_DIRECTORY:
+		h.logger.Info("new directory request")
+		if err := h.directoryRequest(sub.id, request.Query, sub); err != nil {
+			h.logger.Error("directoryRequest failed", zap.Error(err))
+		}
+	case messages.Request_HISTORY, messages.Request_CATCH_UP:
+		h.logger.Info("new history request", zap.Stringer("type", request.Type))
+		if err := h.historyRequest(sub, &request); err != nil {
+			h.logger.Error("historyRequest failed", zap.Error(err))
+		}
+	case messages.Request_LOOKUP:
+		h.logger.Info("new lookup request")
+		if err := h.lookupRequest(sub.id, request.Ids, sub); err != nil {
+			h.logger.Error("lookupRequest failed", zap.Error(err))
+		}
+	case messages.Request_PUBLISH_KEY:
+		h.logger.Info("new publish key request")
+		if err := h.publishKeyRequest(sub, request.PublicKey); err != nil {
+			h.logger.Error("publishKeyRequest failed", zap.Error(err))
+		}
+	case messages.Request_JOIN, messages.Request_LEAVE:
+		h.logger.Info("new group request", zap.Stringer("type", request.Type), zap.String("group", request.Group))
+		if err := h.groupRequest(sub, &request); err != nil {
+			h.logger.Error("groupRequest failed", zap.Error(err))
+		}
+	}
+}
+
+// authenticated reports whether sub may send a request. Listeners requiring auth
+// reject requests of clients without identity, the rejection is logged.
+func (h *Hub) authenticated(sub *subscriber, request string) bool {
+	if sub.id != 0 || sub.listener == nil || !sub.listener.config.RequireAuth {
+		return true
 	}
+	h.logger.Warn("request before identity rejected", zap.String("request", request), zap.Stringer("addr", sub.conn.RemoteAddr()))
+	return false
 }
 
-// identityRequest handles request and sends the response with id
-func (h *Hub) identityRequest(conn net.Conn) (int32, error) {
+// identityRequest handles request and sends the response with id,
+// a device key gets the id bound to it, a known id along with its session token opens
+// another session of that user, otherwise a new user gets an id and a session token.
+// The first of offered codecs accepted by the hub compresses frames after the response.
+func (h *Hub) identityRequest(sub *subscriber, request *messages.Request) (int32, error) {
 	// authenticate user and handle connection
-	id := h.usersProvider.AuthenticateNewUser()
+	var id int32
+	var token string
+	var err error
+	switch key := request.Key; {
+	case len(key) > messages.KeyMaxLength || len(request.Token) > messages.KeyMaxLength:
+		return 0, fmt.Errorf("device key or token longer than %d", messages.KeyMaxLength)
+	case key != "":
+		id, err = h.usersProvider.AuthenticateKey(key)
+	case request.Id != 0 && request.Token != "" && h.usersProvider.AuthenticateToken(request.Id, request.Token):
+		id, token = request.Id, request.Token
+	default:
+		if id, err = h.usersProvider.AuthenticateNewUser(); err == nil {
+			token, err = h.usersProvider.IssueToken(id)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
 	idResp := &messages.IdentityResponse{
-		Id: id,
+		Id:    id,
+		Token: token,
+	}
+	codec := sub.codec
+	if codec == nil && sub.supports(messages.FeatureCompression) {
+		codec = h.negotiateCodec(request.Codecs)
+	}
+	if codec != nil {
+		idResp.Codec = codec.Name()
 	}
 
-	bytes, err := messages.Encode(idResp, messages.MsgTypeIdentityResponse)
-	if err != nil {
-		return 0, fmt.Errorf("encode failed, %s", err.Error())
+	if err := h.send(sub, idResp, messages.MsgTypeIdentityResponse); err != nil {
+		return 0, err
+	}
+	if codec != sub.codec {
+		sub.writeLock.Lock()
+		sub.enc.SetCodec(codec)
+		sub.writeLock.Unlock()
+		sub.codec = codec
 	}
-	conn.Write(bytes)
 
 	return id, nil
 }
 
-// subscribeUser subscribes user to relay messages
-func (h *Hub) subscribeUser(userID int32, conn net.Conn, closeChan chan bool) {
+// negotiateCodec picks the first offered codec the hub accepts
+func (h *Hub) negotiateCodec(offered []string) messages.Codec {
+	for _, name := range offered {
+		for _, codec := range h.codecs {
+			if codec.Name() == name {
+				return codec
+			}
+		}
+	}
+	return nil
+}
+
+// subscribeUser subscribes user session to relay messages
+func (h *Hub) subscribeUser(sub *subscriber, closeChan chan bool) {
+	h.addSession(sub)
+
+	// unsubscribe session from relay messages if connection is lost
+	<-closeChan
+	h.removeSession(sub)
+}
+
+func (h *Hub) addSession(sub *subscriber) {
 	h.lock.Lock()
-	h.subscribers[userID] = conn
-	h.logger.Info("subscribers", zap.Any("list", h.subscribers))
+	userSessions, ok := h.subscribers[sub.id]
+	if !ok {
+		userSessions = make(sessions)
+		h.subscribers[sub.id] = userSessions
+	}
+	userSessions[sub.session] = sub
+	h.sequences.connected(sub.id)
+	h.logger.Info("user subscribed", zap.Int32("id", sub.id), zap.Uint64("session", sub.session),
+		zap.Int("sessions", len(userSessions)), zap.Int("subscribers", len(h.subscribers)))
 	h.lock.Unlock()
+}
 
-	// unsubscribe user from relay messages if connection is lost
-	<-closeChan
+func (h *Hub) removeSession(sub *subscriber) {
 	h.lock.Lock()
-	delete(h.subscribers, userID)
-	h.logger.Info("subscribers", zap.Any("list", h.subscribers))
+	userSessions := h.subscribers[sub.id]
+	delete(userSessions, sub.session)
+	if len(userSessions) == 0 {
+		delete(h.subscribers, sub.id)
+		h.sequences.disconnected(sub.id, time.Now())
+	}
+	h.logger.Info("user unsubscribed", zap.Int32("id", sub.id), zap.Uint64("session", sub.session),
+		zap.Int("sessions", len(userSessions)), zap.Int("subscribers", len(h.subscribers)))
 	h.lock.Unlock()
+	h.leaveGroups(sub)
 }
 
-// listRequest handles request and responds with a list of currently subscribed users
-func (h *Hub) listRequest(userID int32, conn net.Conn) {
-	h.lock.RLock()
-	ids := make([]int32, 0, len(h.subscribers))
-	for id := range h.subscribers {
-		if id != userID {
+// listRequest handles request and responds with a page of currently subscribed users
+// ordered by id, along with the number of sessions of every user if devices is set
+func (h *Hub) listRequest(userID int32, request *messages.Request, sub *subscriber) {
+	if userID == 0 {
+		h.logger.Error("listRequest failed, list request before identity")
+		return
+	}
+	users := h.onlineUsers()
+	if request.CountOnly {
+		var total int32
+		for id := range users {
+			if id != userID && h.acl.CanSee(userID, id) {
+				total++
+			}
+		}
+		if err := h.send(sub, &messages.ListResponse{Total: total}, messages.MsgTypeListResponse); err != nil {
+			h.logger.Error("listRequest failed", zap.Error(err))
+		}
+		return
+	}
+
+	ids := make([]int32, 0, len(users))
+	for id := range users {
+		if id > request.After && id != userID && h.acl.CanSee(userID, id) {
 			ids = append(ids, id)
 		}
 	}
-	h.lock.RUnlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	limit := int(request.Limit)
+	if limit == 0 || limit > messages.ListMaxPageSize {
+		limit = messages.ListMaxPageSize
+	}
+	var next int32
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+
+	var counts []int32
+	if request.Devices {
+		counts = make([]int32, len(ids))
+		for i, id := range ids {
+			counts[i] = users[id]
+		}
+	}
 
 	listResp := &messages.ListResponse{
-		Ids: ids,
+		Ids:     ids,
+		Devices: counts,
+		Next:    next,
+	}
+
+	if err := h.send(sub, listResp, messages.MsgTypeListResponse); err != nil {
+		h.logger.Error("listRequest failed", zap.Error(err))
+	}
+}
+
+// onlineUsers returns the number of sessions of every connected user of the cluster
+func (h *Hub) onlineUsers() map[int32]int32 {
+	// users of other nodes come first so local sessions are counted on top of them
+	users := h.cluster.Users()
+	if users == nil {
+		users = make(map[int32]int32)
+	}
+	h.lock.RLock()
+	for id, userSessions := range h.subscribers {
+		users[id] += int32(len(userSessions))
+	}
+	h.lock.RUnlock()
+	return users
+}
+
+// directoryRequest responds with a page of users userID may see which match query.
+// Offline users are those with a profile on this hub.
+func (h *Hub) directoryRequest(userID int32, query *messages.DirectoryQuery, sub *subscriber) error {
+	if userID == 0 {
+		return fmt.Errorf("directory request before identity")
+	}
+	if query == nil {
+		query = &messages.DirectoryQuery{}
+	}
+	limit := int(query.Limit)
+	if limit == 0 {
+		limit = messages.DirectoryPageSize
+	}
+	if limit > messages.DirectoryMaxPageSize {
+		limit = messages.DirectoryMaxPageSize
+	}
+
+	records := make(map[int32]*messages.UserRecord)
+	if query.Presence != messages.DirectoryQuery_ONLINE {
+		for _, profile := range h.usersProvider.Profiles() {
+			records[profile.Id] = &messages.UserRecord{Profile: profile}
+		}
+	}
+	for id, devices := range h.onlineUsers() {
+		if query.Presence == messages.DirectoryQuery_OFFLINE {
+			delete(records, id)
+			continue
+		}
+		record, ok := records[id]
+		if !ok {
+			record = &messages.UserRecord{Profile: h.usersProvider.Profile(id)}
+			if record.Profile == nil {
+				// users of other nodes have no profile here
+				record.Profile = &messages.Profile{Id: id}
+			}
+			records[id] = record
+		}
+		record.Online = true
+		record.Devices = devices
+	}
+
+	ids := make([]int32, 0, len(records))
+	for id, record := range records {
+		if id > query.After && id != userID && h.acl.CanSee(userID, id) && matchesQuery(record.Profile, query) {
+			ids = append(ids, id)
+		}
 	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
 
-	bytes, err := messages.Encode(listResp, messages.MsgTypeListResponse)
+	resp := &messages.DirectoryResponse{}
+	if len(ids) > limit {
+		ids = ids[:limit]
+		resp.Next = ids[limit-1]
+	}
+	resp.Users = make([]*messages.UserRecord, len(ids))
+	for i, id := range ids {
+		resp.Users[i] = records[id]
+	}
+	return h.send(sub, resp, messages.MsgTypeDirectoryResponse)
+}
+
+// matchesQuery reports whether profile has the name prefix and attributes of query
+func matchesQuery(profile *messages.Profile, query *messages.DirectoryQuery) bool {
+	if !strings.HasPrefix(strings.ToLower(profile.Name), strings.ToLower(query.NamePrefix)) {
+		return false
+	}
+	for _, want := range query.Attributes {
+		if want == nil {
+			continue
+		}
+		var found bool
+		for _, attr := range profile.Metadata {
+			if attr.Key == want.Key && (want.Value == "" || attr.Value == want.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// blockRequest updates the block list of userID, keeps it in the users store and responds
+// with the resulting list
+func (h *Hub) blockRequest(userID int32, reqType messages.Request_Type, ids []int32, sub *subscriber) error {
+	if h.acl == nil {
+		return fmt.Errorf("acl is not configured")
+	}
+	if userID == 0 {
+		return fmt.Errorf("block request before identity")
+	}
+
+	previous := h.acl.Blocked(userID)
+	blocked := previous
+	var err error
+	switch reqType {
+	case messages.Request_BLOCK:
+		blocked, err = h.acl.Block(userID, ids)
+	case messages.Request_UNBLOCK:
+		blocked = h.acl.Unblock(userID, ids)
+	}
 	if err != nil {
-		panic(fmt.Sprintf("ListResponse marshalling failed, %s", err))
+		return err
+	}
+	// blocking only adds ids and unblocking only removes them
+	if len(blocked) != len(previous) {
+		if err := h.usersProvider.SetBlocked(userID, blocked); err != nil {
+			h.acl.SetBlocked(userID, previous)
+			return err
+		}
+	}
+
+	blockResp := &messages.BlockListResponse{
+		Ids: blocked,
+	}
+	return h.send(sub, blockResp, messages.MsgTypeBlockListResponse)
+}
+
+// profileRequest stores the profile of the user on sub and responds with it,
+// the public key published before is kept
+func (h *Hub) profileRequest(sub *subscriber, profile *messages.Profile) error {
+	if sub.id == 0 {
+		return fmt.Errorf("profile request before identity")
+	}
+	if profile == nil {
+		profile = &messages.Profile{}
+	}
+	profile.Id = sub.id
+	profile.PublicKey = h.usersProvider.Profile(sub.id).GetPublicKey()
+	if err := h.usersProvider.SetProfile(profile); err != nil {
+		return err
 	}
-	conn.Write(bytes)
+	return h.send(sub, &messages.ProfileResponse{Profiles: []*messages.Profile{profile}}, messages.MsgTypeProfileResponse)
+}
+
+// publishKeyRequest stores the public key of the user on sub in its profile and responds
+// with the profile. The hub serves the key to peers sealing relays to the user.
+func (h *Hub) publishKeyRequest(sub *subscriber, key []byte) error {
+	if sub.id == 0 {
+		return fmt.Errorf("publish key request before identity")
+	}
+	profile := &messages.Profile{Id: sub.id}
+	if current := h.usersProvider.Profile(sub.id); current != nil {
+		profile.Name = current.Name
+		profile.Metadata = current.Metadata
+	}
+	profile.PublicKey = key
+	if err := h.usersProvider.SetProfile(profile); err != nil {
+		return err
+	}
+	return h.send(sub, &messages.ProfileResponse{Profiles: []*messages.Profile{profile}}, messages.MsgTypeProfileResponse)
+}
+
+// lookupRequest responds with the profiles of ids userID may see, unknown ids are left out
+func (h *Hub) lookupRequest(userID int32, ids []int32, sub *subscriber) error {
+	if userID == 0 {
+		return fmt.Errorf("lookup request before identity")
+	}
+	profiles := make([]*messages.Profile, 0, len(ids))
+	for _, id := range ids {
+		if !h.acl.CanSee(userID, id) {
+			continue
+		}
+		if profile := h.usersProvider.Profile(id); profile != nil {
+			profiles = append(profiles, profile)
+		}
+	}
+	return h.send(sub, &messages.ProfileResponse{Profiles: profiles}, messages.MsgTypeProfileResponse)
+}
 
+// historyRequest responds with a page of a conversation of the user on sub with a peer or
+// with several users, or with
+// relays the user received since a message for CATCH_UP
+func (h *Hub) historyRequest(sub *subscriber, request *messages.Request) error {
+	if h.history == nil {
+		return fmt.Errorf("history is not configured")
+	}
+	if sub.id == 0 {
+		return fmt.Errorf("history request before identity")
+	}
+	limit := int(request.Limit)
+	if limit == 0 || limit > messages.HistoryPageSize {
+		limit = messages.HistoryPageSize
+	}
+	maxBytes := h.limits(sub).MaxBody
+
+	historyResp := &messages.HistoryResponse{}
+	switch {
+	case request.Type == messages.Request_HISTORY && len(request.Ids) > 0:
+		if len(request.Ids) > messages.MaxReceivers {
+			return fmt.Errorf("history request with %d users", len(request.Ids))
+		}
+		historyResp.Entries, historyResp.Next = h.history.Party(sub.id, request.Ids, request.Before, limit, maxBytes)
+	case request.Type == messages.Request_HISTORY:
+		historyResp.Entries, historyResp.Next = h.history.Conversation(sub.id, request.Peer, request.Before, limit, maxBytes)
+	default:
+		historyResp.Entries, historyResp.Next = h.history.Since(sub.id, request.Since, limit, maxBytes)
+	}
+	return h.send(sub, historyResp, messages.MsgTypeHistoryResponse)
 }
 
 // relayRequest handles relay message and sends it to all currently active users
-func (h *Hub) relayRequest(bytes []byte) {
+// The relay request frame is parsed in place and rewritten into the Relay frame,
+// which is shared by all receivers, so the body is never copied.
+func (h *Hub) relayRequest(sub *subscriber, frame *messages.Frame) {
+	defer h.metrics.RequestHandled("relay", time.Now())
+
 	// parse message
-	var request messages.RelayRequest
-	err := proto.Unmarshal(bytes, &request)
+	request, err := messages.ParseRelayRequest(frame.Payload())
 	if err != nil {
+		h.metrics.DecodeError()
 		h.logger.Error("unmarshal failed", zap.Error(err))
 		return
 	}
 
-	if len(request.Ids) == 0 {
+	sender := sub.id
+	if sender == 0 {
+		h.logger.Error("relay request before identity, dropping relay")
+		return
+	}
+	// relays dropped as invalid don't take their idempotency key
+	if len(request.Error) > messages.ReplyErrorMaxLength || len(request.Group) > messages.GroupNameMaxLength {
+		h.metrics.DecodeError()
+		h.logger.Info("reply error or group too long, dropping relay", zap.Int32("from", sender))
+		return
+	}
+	limits := h.limits(sub)
+	ids, bodyLen := limitRelay(limits, request.Ids, len(request.Body))
+	if key := request.IdempotencyKey; key != "" {
+		if len(key) > messages.IdempotencyKeyMaxLength {
+			h.metrics.DecodeError()
+			h.logger.Info("idempotency key too long, dropping relay", zap.Int32("from", sender))
+			return
+		}
+		if h.dedup.Seen(sender, key) {
+			h.logger.Info("dropping repeated relay", zap.Int32("from", sender), zap.String("key", key))
+			for range ids {
+				h.metrics.MessageDropped(dropDuplicate)
+			}
+			return
+		}
+	}
+	opts := newRelayOptions(sub, request.Ttl, request.Receipt, request.Priority)
+	opts.call, opts.reply, opts.replyError = request.Call, request.Reply, request.Error
+	opts.sealed = request.Sealed
+	if request.Group != "" {
+		body := request.Body[:bodyLen]
+		if frame.Compressed() {
+			if body, err = sub.codec.Decompress(nil, request.Body, limits.MaxBody); err != nil && err != messages.ErrTooLarge {
+				h.metrics.DecodeError()
+				h.logger.Error("decompressing relay failed", zap.Error(err))
+				return
+			}
+		}
+		h.groupRelay(sender, request.Group, body, opts)
 		return
 	}
-	body := request.Body
-	ids := request.Ids
-	if len(body) > messages.BodyMaxLength {
-		body = body[:messages.BodyMaxLength]
+	if !frame.Compressed() {
+		frame.RewriteAsRelay(&request, bodyLen)
+		opts.stamp(frame)
+		rf := &relayFrame{from: sender, frame: frame, body: request.Body[:bodyLen], relayOptions: opts}
+		h.recordRelay(sender, ids, rf)
+		h.fanout(sender, ids, rf)
+		return
 	}
 
-	if len(ids) > messages.MaxReceivers {
-		ids = ids[:messages.MaxReceivers]
+	// a compressed body can't be cut, it is relayed decompressed if it is over the limit
+	if bodyLen < len(request.Body) {
+		body, err := sub.codec.Decompress(nil, request.Body, limits.MaxBody)
+		if err != nil && err != messages.ErrTooLarge {
+			h.metrics.DecodeError()
+			h.logger.Error("decompressing relay failed", zap.Error(err))
+			return
+		}
+		h.relay(sender, ids, body, opts)
+		return
 	}
+	frame.RewriteAsRelay(&request, bodyLen)
+	opts.stamp(frame)
+	rf := &relayFrame{from: sender, frame: frame, body: request.Body, codec: sub.codec, limit: limits.MaxBody, relayOptions: opts}
+	h.recordRelay(sender, ids, rf)
+	h.fanout(sender, ids, rf)
+	rf.release()
+}
 
-	// prepare relay message
-	relay := &messages.Relay{
-		Body: body,
+// relay sends body to all currently active users from ids on behalf of sender,
+// ids and body must be within limits already
+func (h *Hub) relay(sender int32, ids []int32, body []byte, opts relayOptions) {
+	frame := encodeRelay(body, 0, 0)
+	opts.stamp(frame)
+	rf := &relayFrame{from: sender, frame: frame, body: body, relayOptions: opts}
+	h.recordRelay(sender, ids, rf)
+	h.fanout(sender, ids, rf)
+	frame.Release()
+}
+
+// recordRelay keeps the relay in history if the hub has one and stamps its frame with
+// the message id and timestamp, calls and replies are not kept
+func (h *Hub) recordRelay(sender int32, ids []int32, rf *relayFrame) {
+	if h.history == nil || rf.rpc() {
+		return
 	}
-	bytes, err = messages.Encode(relay, messages.MsgTypeRelay)
+	body, err := rf.decompressed()
 	if err != nil {
-		panic(fmt.Sprintf("Relay marshalling failed, %s", err))
+		// fanout drops the relay as well
+		return
 	}
+	receivers := make([]int32, 0, len(ids))
+	for _, id := range ids {
+		if sender == serverUserID || h.acl.CanRelay(sender, id) {
+			receivers = append(receivers, id)
+		}
+	}
+	var expires int64
+	if !rf.expires.IsZero() {
+		expires = rf.expires.UnixNano() / int64(time.Millisecond)
+	}
+	rf.messageID, rf.timestamp = h.history.Record(sender, receivers, append([]byte(nil), body...), expires, rf.sealed)
+	rf.frame.AppendRelayFields(rf.messageID, rf.timestamp)
+}
 
-	// send relay
+// relayOptions are the settings a sender gave a relay
+type relayOptions struct {
+	// expires drops the relay if it isn't written by then, zero never expires
+	expires time.Time
+	// sender gets receipts carrying receipt if it is set, a sender on another node gets them
+	// through its node at session
+	sender   *subscriber
+	receipt  uint64
+	session  uint64
+	priority messages.RelayRequest_Priority
+	// call, reply and replyError are the request/reply fields written along with the relay
+	call       uint64
+	reply      uint64
+	replyError string
+	// job is the id of a relay to a queue group, 0 for other relays
+	job uint64
+	// sealed marks a body encrypted for the receiver
+	sealed bool
+}
+
+func newRelayOptions(sub *subscriber, ttl uint32, receipt uint64, priority messages.RelayRequest_Priority) relayOptions {
+	opts := relayOptions{priority: priority}
+	if ttl > 0 {
+		opts.expires = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+	if receipt != 0 {
+		opts.sender = sub
+		opts.receipt = receipt
+	}
+	return opts
+}
+
+// rpc reports whether the relay is a call or a reply
+func (o relayOptions) rpc() bool {
+	return o.call != 0 || o.reply != 0 || o.replyError != ""
+}
+
+// stamp adds the request/reply fields and the sealed flag to a relay frame
+func (o relayOptions) stamp(frame *messages.Frame) {
+	if o.rpc() {
+		frame.AppendRelayCall(o.call, o.reply, o.replyError)
+	}
+	if o.sealed {
+		frame.AppendRelaySealed()
+	}
+}
+
+// ttl returns the milliseconds left until the relay expires, 0 if it never does,
+// and whether it has expired
+func (o relayOptions) ttl() (uint32, bool) {
+	if o.expires.IsZero() {
+		return 0, false
+	}
+	left := time.Until(o.expires)
+	if left <= 0 {
+		return 0, true
+	}
+	return uint32((left + time.Millisecond - 1) / time.Millisecond), false
+}
+
+// relayFrame is a relay frame with its body. A compressed relay is passed on as is to receivers
+// which negotiated its codec, others get a plain frame decompressed at most once.
+type relayFrame struct {
+	relayOptions
+	from  int32
+	frame *messages.Frame
+	body  []byte
+	// codec compressed body, nil if it is plain
+	codec messages.Codec
+	// limit caps the decompressed body
+	limit int
+	// messageID and timestamp are set if the relay is kept in history
+	messageID uint64
+	timestamp int64
+
+	plain     *messages.Frame
+	plainBody []byte
+	err       error
+}
+
+// frameFor returns the frame to write to a receiver using codec, nil if the body can't be decompressed
+func (r *relayFrame) frameFor(codec messages.Codec) *messages.Frame {
+	if r.codec == nil || r.codec == codec {
+		return r.frame
+	}
+	if r.plain == nil {
+		body, err := r.decompressed()
+		if err != nil {
+			return nil
+		}
+		r.plain = encodeRelay(body, r.messageID, r.timestamp)
+		r.stamp(r.plain)
+	}
+	return r.plain
+}
+
+// decompressed returns the plain body
+func (r *relayFrame) decompressed() ([]byte, error) {
+	if r.codec == nil {
+		return r.body, nil
+	}
+	if r.plainBody == nil && r.err == nil {
+		r.plainBody, r.err = r.codec.Decompress(nil, r.body, r.limit)
+		if r.err == messages.ErrTooLarge {
+			r.err = nil
+		}
+	}
+	return r.plainBody, r.err
+}
+
+func (r *relayFrame) release() {
+	if r.plain != nil {
+		r.plain.Release()
+	}
+}
+
+// limits returns the limits of the listener of sub
+func (h *Hub) limits(sub *subscriber) Limits {
+	limits := h.currentSettings().limits
+	if sub.listener != nil {
+		limits = limits.Override(sub.listener.config.Limits)
+	}
+	return limits
+}
+
+// limitRelay cuts receivers and body length to limits
+func limitRelay(limits Limits, ids []int32, bodyLen int) ([]int32, int) {
+	if bodyLen > limits.MaxBody {
+		bodyLen = limits.MaxBody
+	}
+	if len(ids) > limits.MaxReceivers {
+		ids = ids[:limits.MaxReceivers]
+	}
+	return ids, bodyLen
+}
+
+// fanout writes the encoded relay frame to local receivers and forwards body to other nodes
+func (h *Hub) fanout(sender int32, ids []int32, rf *relayFrame) {
+	if len(ids) == 0 {
+		return
+	}
+
+	// send relay, receivers owned by other nodes are grouped by node and forwarded
+	var receivers int
+	var remote map[int32][]int32
 	h.lock.RLock()
 	for _, id := range ids {
-		if id == request.Id {
+		if id == sender || !h.canRelay(sender, id) {
+			continue
+		}
+		if h.cluster.isRemote(id) {
+			if remote == nil {
+				remote = make(map[int32][]int32)
+			}
+			remote[nodeOf(id)] = append(remote[nodeOf(id)], id)
 			continue
 		}
+		receivers += h.deliver(id, rf)
+	}
+	h.lock.RUnlock()
 
-		if conn, ok := h.subscribers[id]; ok {
-			if conn != nil {
-				go conn.Write(bytes)
+	// other nodes get the plain body, peers don't negotiate codecs
+	var body []byte
+	if len(remote) > 0 {
+		var err error
+		if body, err = rf.decompressed(); err != nil {
+			h.logger.Error("decompressing relay failed", zap.Error(err))
+			remote = nil
+		}
+		// peers are written to after the frame of the relay is released
+		body = append([]byte(nil), body...)
+	}
+	ttl, expired := rf.ttl()
+	for node, nodeIDs := range remote {
+		if expired {
+			for range nodeIDs {
+				h.metrics.MessageDropped(dropExpired)
+			}
+			continue
+		}
+		relay := &messages.PeerRelay{
+			From:     sender,
+			Ids:      nodeIDs,
+			Body:     body,
+			Ttl:      ttl,
+			Priority: rf.priority,
+			Call:     rf.call,
+			Reply:    rf.reply,
+			Error:    rf.replyError,
+			Sealed:   rf.sealed,
+		}
+		if rf.sender != nil {
+			relay.Receipt, relay.Session = rf.receipt, rf.sender.session
+		}
+		if !h.cluster.forward(node, relay) {
+			for range nodeIDs {
+				h.metrics.MessageDropped(dropOffline)
 			}
+			continue
+		}
+		receivers += len(nodeIDs)
+	}
+	h.metrics.RelayFanout(receivers)
+	if rf.call != 0 && receivers == 0 {
+		h.noResponder(sender, rf.call)
+	}
+}
+
+// receiptFromPeer passes a receipt for a relay forwarded to another node on to the session of
+// its sender
+func (h *Hub) receiptFromPeer(receipt *messages.PeerReceipt) {
+	h.lock.RLock()
+	sender := h.subscribers[receipt.To][receipt.Session]
+	h.lock.RUnlock()
+	if sender == nil {
+		h.logger.Info("sender of receipt is gone, dropping receipt", zap.Int32("id", receipt.To))
+		return
+	}
+	if err := h.send(sender, receipt.Receipt, messages.MsgTypeRelayReceipt); err != nil {
+		h.logger.Info("sending receipt failed", zap.Int32("id", receipt.To), zap.Error(err))
+	}
+}
+
+// relayFromPeer delivers a relay forwarded by another node to local receivers
+func (h *Hub) relayFromPeer(relay *messages.PeerRelay) {
+	opts := newRelayOptions(nil, relay.Ttl, 0, relay.Priority)
+	opts.call, opts.reply, opts.replyError = relay.Call, relay.Reply, relay.Error
+	opts.sealed = relay.Sealed
+	opts.receipt, opts.session = relay.Receipt, relay.Session
+	frame := encodeRelay(relay.Body, 0, 0)
+	defer frame.Release()
+	opts.stamp(frame)
+	rf := &relayFrame{from: relay.From, frame: frame, body: relay.Body, relayOptions: opts}
+	h.recordRelay(relay.From, relay.Ids, rf)
+
+	var receivers int
+	h.lock.RLock()
+	for _, id := range relay.Ids {
+		if h.cluster.isRemote(id) || !h.canRelay(relay.From, id) {
+			continue
 		}
+		receivers += h.deliver(id, rf)
 	}
 	h.lock.RUnlock()
+	h.metrics.RelayFanout(receivers)
+	if rf.call != 0 && receivers == 0 {
+		h.noResponder(relay.From, relay.Call)
+	}
+}
+
+// noResponder replies to a call which reached no session, so the caller needn't wait for it
+// to time out. The reply is relayed to every session of caller, others ignore the token.
+func (h *Hub) noResponder(caller int32, call uint64) {
+	h.logger.Info("call reached no responder", zap.Int32("from", caller))
+	h.relay(serverUserID, []int32{caller}, nil, relayOptions{reply: call, replyError: messages.ReplyNoResponder})
+}
+
+func (h *Hub) canRelay(sender, receiver int32) bool {
+	if sender == serverUserID || h.acl.CanRelay(sender, receiver) {
+		return true
+	}
+	h.logger.Info("relay denied by acl", zap.Int32("from", sender), zap.Int32("to", receiver))
+	h.metrics.MessageDropped(dropACL)
+	return false
+}
+
+// deliver queues the relay frame to every session of id and returns the number of sessions,
+// the caller must hold the lock
+func (h *Hub) deliver(id int32, rf *relayFrame) int {
+	userSessions := h.subscribers[id]
+	// the sequence number is taken while the relay is queued, so numbers follow queue order
+	h.sequences.lock.Lock()
+	defer h.sequences.lock.Unlock()
+	if len(userSessions) == 0 {
+		// a relay missed by a user offline for a while leaves a gap its next session can tell
+		h.sequences.skip(rf.from, id, rf.priority)
+		h.metrics.MessageDropped(dropOffline)
+		return 0
+	}
+	sequence := h.sequences.next(rf.from, id, rf.priority)
+	// fan out to every device of the user
+	var delivered int
+	for _, receiver := range userSessions {
+		frame := rf.frameFor(receiver.codec)
+		if frame == nil {
+			h.metrics.MessageDropped(dropCorrupt)
+			continue
+		}
+		h.metrics.FrameQueued()
+		frame.Retain()
+		if receiver.outbox.push(queuedRelay{frame: frame, opts: rf.relayOptions, from: rf.from, sequence: sequence}) {
+			go h.drain(receiver)
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// localPresence returns users connected to this hub along with the number of their sessions
+func (h *Hub) localPresence() (ids []int32, devices []int32) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	ids = make([]int32, 0, len(h.subscribers))
+	devices = make([]int32, 0, len(h.subscribers))
+	for id, userSessions := range h.subscribers {
+		ids = append(ids, id)
+		devices = append(devices, int32(len(userSessions)))
+	}
+	return ids, devices
+}
+
+func encodeRelay(body []byte, messageID uint64, timestamp int64) *messages.Frame {
+	relay := &messages.Relay{
+		Body:      body,
+		MessageId: messageID,
+		Timestamp: timestamp,
+	}
+	frame, err := messages.EncodeFrame(relay, messages.MsgTypeRelay)
+	if err != nil {
+		panic(fmt.Sprintf("Relay marshalling failed, %s", err))
+	}
+	return frame
+}
+
+// send encodes a response and writes it to subscriber
+func (h *Hub) send(sub *subscriber, msg messages.Message, msgType messages.MsgType) error {
+	sub.writeLock.Lock()
+	defer sub.writeLock.Unlock()
+
+	if err := sub.enc.Encode(msg, msgType); err != nil {
+		return fmt.Errorf("encode failed, %s", err.Error())
+	}
+	return h.flush(sub, msgType, false)
+}
+
+// drain writes relays queued for sub until its outbox is empty
+func (h *Hub) drain(sub *subscriber) {
+	for {
+		relay, ok := sub.outbox.pop()
+		if !ok {
+			return
+		}
+		h.writeRelay(sub, relay)
+	}
+}
+
+// writeRelay writes a queued relay frame unless it expired in the queue, releases the
+// reference taken for the receiver and sends the receipt if the sender asked for one
+func (h *Hub) writeRelay(sub *subscriber, relay queuedRelay) {
+	frame, opts := relay.frame, relay.opts
+	status := messages.RelayReceipt_DELIVERED
+	var done bool
+	sub.writeLock.Lock()
+	if _, expired := opts.ttl(); expired {
+		h.metrics.FrameDiscarded()
+		h.metrics.MessageDropped(dropExpired)
+		status, done = messages.RelayReceipt_EXPIRED, true
+		// an expired job is not sent to another member
+		if opts.job != 0 {
+			h.groups.lock.Lock()
+			h.groups.release(sub, opts.job)
+			h.groups.lock.Unlock()
+		}
+	} else if err := sub.enc.WriteRelayFrame(frame, relay.from, relay.sequence, opts.priority); err == nil {
+		done = h.flush(sub, frame.Type(), true) == nil
+	}
+	if !done {
+		h.metrics.FrameDiscarded()
+	}
+	sub.writeLock.Unlock()
+	frame.Release()
+
+	if opts.receipt == 0 || !done {
+		return
+	}
+	receipt := &messages.RelayReceipt{
+		Receipt: opts.receipt,
+		Id:      sub.id,
+		Status:  status,
+	}
+	if opts.sender == nil {
+		go h.cluster.receipt(relay.from, opts.session, receipt)
+		return
+	}
+	// a slow sender must not hold up relays queued for sub
+	go func() {
+		if err := h.send(opts.sender, receipt, messages.MsgTypeRelayReceipt); err != nil {
+			h.logger.Info("sending receipt failed", zap.Int32("id", opts.sender.id), zap.Error(err))
+		}
+	}()
+}
+
+// writeFrame writes a queued frame and releases the reference taken for the receiver
+func (h *Hub) writeFrame(sub *subscriber, frame *messages.Frame) {
+	sub.writeLock.Lock()
+	if err := sub.enc.WriteFrame(frame); err != nil || h.flush(sub, frame.Type(), true) != nil {
+		h.metrics.FrameDiscarded()
+	}
+	sub.writeLock.Unlock()
+	frame.Release()
+}
+
+// flush writes frames buffered for subscriber and accounts for them in statistics.
+// A connection failing a write is closed, so its reader stops as well.
+// The caller must hold the write lock.
+func (h *Hub) flush(sub *subscriber, msgType messages.MsgType, queued bool) error {
+	if timeout := h.currentSettings().timeouts.Write; timeout > 0 {
+		sub.conn.SetWriteDeadline(time.Now().Add(time.Duration(timeout)))
+	}
+	size := sub.enc.Buffered()
+	if err := sub.enc.Flush(); err != nil {
+		h.logger.Info("write failed, closing connection", zap.Int32("id", sub.id), zap.Error(err))
+		sub.conn.Close()
+		return fmt.Errorf("write failed, %s", err.Error())
+	}
+	sub.sent(size)
+	h.metrics.FrameSent(msgType, size, queued)
+	return nil
+}
+
+// Subscribers returns a snapshot of all subscribed sessions ordered by user id and session
+func (h *Hub) Subscribers() []SubscriberInfo {
+	h.lock.RLock()
+	infos := make([]SubscriberInfo, 0, len(h.subscribers))
+	for _, userSessions := range h.subscribers {
+		for _, sub := range userSessions {
+			infos = append(infos, sub.info())
+		}
+	}
+	h.lock.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ID != infos[j].ID {
+			return infos[i].ID < infos[j].ID
+		}
+		return infos[i].Session < infos[j].Session
+	})
+
+	return infos
+}
+
+// Kick closes all sessions of userID, reports false if the user is not subscribed
+func (h *Hub) Kick(userID int32) bool {
+	h.lock.RLock()
+	userSessions := h.sessionsOf(userID)
+	h.lock.RUnlock()
+	if len(userSessions) == 0 {
+		return false
+	}
+
+	h.logger.Info("kicking user", zap.Int32("id", userID), zap.Int("sessions", len(userSessions)))
+	for _, sub := range userSessions {
+		sub.conn.Close()
+	}
+	return true
+}
+
+// KickSession closes a single session, reports false if there is no such session
+func (h *Hub) KickSession(session uint64) bool {
+	var sub *subscriber
+	h.lock.RLock()
+	for _, userSessions := range h.subscribers {
+		if s, ok := userSessions[session]; ok {
+			sub = s
+			break
+		}
+	}
+	h.lock.RUnlock()
+	if sub == nil {
+		return false
+	}
+
+	h.logger.Info("kicking session", zap.Int32("id", sub.id), zap.Uint64("session", session))
+	sub.conn.Close()
+	return true
+}
+
+// errNoHost is returned banning a user whose sessions have no host, e.g. peers of unix sockets
+var errNoHost = errors.New("user has no host to ban, kick it instead")
+
+// Ban kicks all sessions of userID and rejects further connections from their hosts, it reports
+// false if the user is not connected. Sessions without a host are kicked only, a user with none
+// but those is neither banned nor kicked.
+func (h *Hub) Ban(userID int32) (bool, error) {
+	h.lock.Lock()
+	userSessions := h.sessionsOf(userID)
+	var hosts []string
+	for _, sub := range userSessions {
+		if host := hostOf(sub.conn.RemoteAddr()); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(userSessions) > 0 && len(hosts) == 0 {
+		h.lock.Unlock()
+		return true, errNoHost
+	}
+	for _, host := range hosts {
+		h.banned[host] = struct{}{}
+	}
+	h.lock.Unlock()
+	if len(userSessions) == 0 {
+		return false, nil
+	}
+
+	for _, sub := range userSessions {
+		h.logger.Info("banning user", zap.Int32("id", userID), zap.Stringer("addr", sub.conn.RemoteAddr()))
+		sub.conn.Close()
+	}
+	return true, nil
+}
+
+// sessionsOf copies the sessions of userID, the caller must hold the lock
+func (h *Hub) sessionsOf(userID int32) []*subscriber {
+	userSessions := make([]*subscriber, 0, len(h.subscribers[userID]))
+	for _, sub := range h.subscribers[userID] {
+		userSessions = append(userSessions, sub)
+	}
+	return userSessions
+}
+
+// Unban accepts connections from host again
+func (h *Hub) Unban(host string) {
+	h.lock.Lock()
+	delete(h.banned, host)
+	h.lock.Unlock()
+}
+
+// Banned returns banned hosts
+func (h *Hub) Banned() []string {
+	h.lock.RLock()
+	hosts := make([]string, 0, len(h.banned))
+	for host := range h.banned {
+		hosts = append(hosts, host)
+	}
+	h.lock.RUnlock()
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+func (h *Hub) isBanned(addr net.Addr) bool {
+	host := hostOf(addr)
+	if host == "" {
+		return false
+	}
+	h.lock.RLock()
+	_, ok := h.banned[host]
+	h.lock.RUnlock()
+	return ok
+}
+
+// hostOf strips the port from addr, peers of unix sockets have no host
+func hostOf(addr net.Addr) string {
+	if addr == nil || addr.Network() == "unix" || addr.Network() == "unixpacket" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
 }
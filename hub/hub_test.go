@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"net"
 	"github.com/antonzhukov/go-tcp-messaging/messages"
@@ -16,8 +23,7 @@ func TestHub_identityRequest(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	h := &Hub{
-		subscribers:   make(map[int32]net.Conn),
-		ln:            &mockListener{conn: server},
+		subscribers:   make(map[int32]sessions),
 		logger:        zap.L(),
 		usersProvider: NewUsers(),
 	}
@@ -39,42 +45,117 @@ func TestHub_identityRequest(t *testing.T) {
 		t.Errorf("identityRequest failed. Expected %d, got %d", messages.MsgTypeIdentityResponse, msgType)
 	}
 
-	expectedResp := messages.IdentityResponse{
-		Id: 1,
-	}
 	var result messages.IdentityResponse
 	err = proto.Unmarshal(bytes, &result)
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(expectedResp, result) {
-		t.Errorf("identityRequest failed. Expected %#v, got %#v", expectedResp, result)
+	if result.Id != 1 || !h.usersProvider.AuthenticateToken(1, result.Token) {
+		t.Errorf("identityRequest failed. Expected id 1 with its session token, got %#v", result)
 	}
+
+	// another identity on the connection is rejected
+	frames := readFrames(client)
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_IDENTITY}, messages.MsgTypeRequest))
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_LIST, CountOnly: true}, messages.MsgTypeRequest))
+	expectFrame(t, frames, messages.MsgTypeListResponse, &messages.ListResponse{})
 }
 
-func TestHub_listRequest(t *testing.T) {
+func TestHub_hello(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	h := &Hub{
-		subscribers:   make(map[int32]net.Conn),
-		ln:            &mockListener{conn: server},
+		subscribers:   make(map[int32]sessions),
 		logger:        zap.L(),
 		usersProvider: NewUsers(),
 	}
-	h.subscribers[234] = server
-	h.subscribers[435] = server
 	go h.handleConnection(server)
 
 	// act
-	listReq := &messages.Request{
-		Type: messages.Request_LIST,
+	hello := &messages.Hello{
+		Version:  messages.ProtocolVersion + 1,
+		MaxFrame: 1024,
+		Features: []string{messages.FeatureCompression, messages.FeatureStreams},
 	}
-	bytes, err := messages.Encode(listReq, messages.MsgTypeRequest)
+	bytes, err := messages.Encode(hello, messages.MsgTypeHello)
 	if err != nil {
 		t.Error(err)
 	}
 	go client.Write(bytes)
 
+	// assert
+	bytes, msgType, err := messages.Decode(client)
+	if msgType != messages.MsgTypeWelcome {
+		t.Errorf("hello failed. Expected %d, got %d", messages.MsgTypeWelcome, msgType)
+	}
+
+	// hub without codecs doesn't offer compression
+	expectedWelcome := messages.Welcome{
+		Version:  messages.ProtocolVersion,
+		MaxFrame: messages.DefaultMaxFrame,
+		Features: []string{messages.FeatureStreams},
+	}
+	var result messages.Welcome
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(expectedWelcome, result) {
+		t.Errorf("hello failed. Expected %#v, got %#v", expectedWelcome, result)
+	}
+}
+
+func TestHub_hello_rejected(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	go h.handleConnection(server)
+
+	// act
+	bytes, err := messages.Encode(&messages.Hello{}, messages.MsgTypeHello)
+	if err != nil {
+		t.Error(err)
+	}
+	go client.Write(bytes)
+
+	// assert
+	bytes, msgType, err := messages.Decode(client)
+	if msgType != messages.MsgTypeWelcome {
+		t.Errorf("hello failed. Expected %d, got %d", messages.MsgTypeWelcome, msgType)
+	}
+	var result messages.Welcome
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Reason == "" {
+		t.Error("hello failed. Expected rejection reason")
+	}
+	if _, _, err := messages.Decode(client); err == nil {
+		t.Error("hello failed. Expected connection to be closed")
+	}
+}
+
+func TestHub_listRequest(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: NewUsers(),
+	}
+	h.addSession(newTestSubscriber(234, server))
+	h.addSession(newTestSubscriber(435, server))
+
+	// act
+	listReq := &messages.Request{
+		Type: messages.Request_LIST,
+	}
+	go h.handleRequest(newTestSubscriber(999, server), marshal(t, listReq), nil)
+
 	// assert
 	bytes, msgType, err := messages.Decode(client)
 	if msgType != messages.MsgTypeListResponse {
@@ -94,28 +175,63 @@ func TestHub_listRequest(t *testing.T) {
 	}
 }
 
+func TestHub_listRequest_pages(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	for _, id := range []int32{5, 1, 4, 3, 2} {
+		conn, _ := net.Pipe()
+		h.addSession(newTestSubscriber(id, conn))
+	}
+
+	tests := []struct {
+		name    string
+		request *messages.Request
+		want    *messages.ListResponse
+	}{
+		{"first page", &messages.Request{Limit: 2}, &messages.ListResponse{Ids: []int32{1, 2}, Next: 2}},
+		{"middle page skips requester", &messages.Request{Limit: 1, After: 2}, &messages.ListResponse{Ids: []int32{4}, Next: 4}},
+		{"last page", &messages.Request{Limit: 2, After: 2}, &messages.ListResponse{Ids: []int32{4, 5}}},
+		{"past last page", &messages.Request{After: 5}, &messages.ListResponse{}},
+		{"devices", &messages.Request{Limit: 1, After: 3, Devices: true}, &messages.ListResponse{Ids: []int32{4}, Devices: []int32{1}, Next: 4}},
+		{"count only", &messages.Request{CountOnly: true}, &messages.ListResponse{Total: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			frames := readFrames(client)
+
+			// act
+			go h.listRequest(3, tt.request, newTestSubscriber(3, server))
+
+			// assert
+			expectFrame(t, frames, messages.MsgTypeListResponse, tt.want)
+		})
+	}
+}
+
 func TestHub_relayRequest(t *testing.T) {
 	// arrange
 	server, client := net.Pipe()
 	h := &Hub{
-		subscribers: make(map[int32]net.Conn),
-		ln:          &mockListener{conn: server},
+		subscribers: make(map[int32]sessions),
 		logger:      zap.L(),
 	}
-	h.subscribers[123] = server
-	go h.handleConnection(server)
+	h.addSession(newTestSubscriber(123, server))
 
 	// act
 	relayReq := &messages.RelayRequest{
-		Id:   456,
 		Ids:  []int32{123},
 		Body: []byte("g'day"),
 	}
-	bytes, err := messages.Encode(relayReq, messages.MsgTypeRelayRequest)
+	frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, relayReq, messages.MsgTypeRelayRequest)))
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	go client.Write(bytes)
+	go h.relayRequest(newTestSubscriber(456, server), frame)
 
 	// assert
 	bytes, msgType, err := messages.Decode(client)
@@ -124,7 +240,9 @@ func TestHub_relayRequest(t *testing.T) {
 	}
 
 	expectedRelay := messages.Relay{
-		Body: relayReq.Body,
+		Body:     relayReq.Body,
+		From:     456,
+		Sequence: 1,
 	}
 	var result messages.Relay
 	err = proto.Unmarshal(bytes, &result)
@@ -137,12 +255,626 @@ func TestHub_relayRequest(t *testing.T) {
 
 }
 
+func TestHub_blockRequest(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	acl, err := NewACL("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := NewUsers()
+	blockingID, _ := users.AuthenticateNewUser()
+	blockedID, _ := users.AuthenticateNewUser()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		acl:           acl,
+		usersProvider: users,
+	}
+	blocking := newTestSubscriber(blockingID, server)
+	blocked := newTestSubscriber(blockedID, server)
+	h.addSession(blocking)
+	h.addSession(blocked)
+
+	// act
+	blockReq := &messages.Request{
+		Type: messages.Request_BLOCK,
+		Ids:  []int32{blockedID},
+	}
+	go h.handleRequest(blocking, marshal(t, blockReq), nil)
+
+	// assert
+	bytes, msgType, err := messages.Decode(client)
+	if msgType != messages.MsgTypeBlockListResponse {
+		t.Errorf("blockRequest failed. Expected %d, got %d", messages.MsgTypeBlockListResponse, msgType)
+	}
+
+	expectedResp := messages.BlockListResponse{
+		Ids: []int32{blockedID},
+	}
+	var result messages.BlockListResponse
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(expectedResp, result) {
+		t.Errorf("blockRequest failed. Expected %#v, got %#v", expectedResp, result)
+	}
+	if lists := users.BlockLists(); !reflect.DeepEqual(lists[blockingID], []int32{blockedID}) {
+		t.Errorf("blockRequest failed. Expected the block list kept by users, got %#v", lists)
+	}
+
+	// blocked user doesn't see the blocking one anymore
+	listReq := &messages.Request{
+		Type: messages.Request_LIST,
+	}
+	go h.handleRequest(blocked, marshal(t, listReq), nil)
+
+	bytes, _, err = messages.Decode(client)
+	var listResult messages.ListResponse
+	err = proto.Unmarshal(bytes, &listResult)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(listResult.Ids) != 0 {
+		t.Errorf("blockRequest failed. Expected empty list, got %#v", listResult.Ids)
+	}
+}
+
+func TestHub_identityRequest_existingUser(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	users := NewUsers()
+	id, _ := users.AuthenticateNewUser()
+	token, _ := users.IssueToken(id)
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: users,
+	}
+	h.addSession(newTestSubscriber(id, server))
+	go h.handleConnection(server)
+
+	// act
+	idReq := &messages.Request{
+		Type:  messages.Request_IDENTITY,
+		Id:    id,
+		Token: token,
+	}
+	bytes, err := messages.Encode(idReq, messages.MsgTypeRequest)
+	if err != nil {
+		t.Error(err)
+	}
+	go client.Write(bytes)
+
+	// assert
+	bytes, _, err = messages.Decode(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result messages.IdentityResponse
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if result.Id != id || result.Token != token {
+		t.Errorf("identityRequest failed. Expected %d with its token, got %#v", id, result)
+	}
+
+	// an id without its token is not resumed
+	other, otherClient := net.Pipe()
+	go h.handleConnection(other)
+	frames := readFrames(otherClient)
+	go otherClient.Write(encode(t, &messages.Request{Type: messages.Request_IDENTITY, Id: id, Token: "guess"}, messages.MsgTypeRequest))
+	if got := expectIdentity(t, frames); got == id {
+		t.Errorf("identityRequest failed. Expected a new id, got %d", got)
+	}
+}
+
+func TestHub_handleRequest_beforeIdentity(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: NewUsers(),
+	}
+	h.addSession(newTestSubscriber(435, server))
+	go h.handleConnection(server)
+	frames := readFrames(client)
+
+	// act
+	enc := messages.NewEncoder(client)
+	enc.Encode(&messages.Request{Id: 234, Type: messages.Request_LIST}, messages.MsgTypeRequest)
+	enc.Encode(&messages.Request{Id: 234, Type: messages.Request_LOOKUP, Ids: []int32{435}}, messages.MsgTypeRequest)
+	enc.Encode(&messages.Request{Type: messages.Request_IDENTITY}, messages.MsgTypeRequest)
+	go enc.Flush()
+
+	// assert
+	// requests claiming an id before identity get no response
+	if id := expectIdentity(t, frames); id != 1 {
+		t.Errorf("handleRequest failed. Expected identity 1, got %d", id)
+	}
+}
+
+func TestHub_listRequest_devices(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	h.addSession(newTestSubscriber(234, server))
+	h.addSession(newTestSubscriber(234, server))
+	h.addSession(newTestSubscriber(435, server))
+
+	// act
+	listReq := &messages.Request{
+		Type:    messages.Request_LIST,
+		Devices: true,
+	}
+	go h.handleRequest(newTestSubscriber(999, server), marshal(t, listReq), nil)
+
+	// assert
+	bytes, _, err := messages.Decode(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedResp := messages.ListResponse{
+		Ids:     []int32{234, 435},
+		Devices: []int32{2, 1},
+	}
+	var result messages.ListResponse
+	err = proto.Unmarshal(bytes, &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(expectedResp, result) {
+		t.Errorf("listRequest failed. Expected %#v, got %#v", expectedResp, result)
+	}
+}
+
+func TestHub_relay_sessions(t *testing.T) {
+	// arrange
+	phone, phoneClient := net.Pipe()
+	laptop, laptopClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	h.addSession(newTestSubscriber(123, phone))
+	h.addSession(newTestSubscriber(123, laptop))
+
+	// act
+	h.relay(456, []int32{123}, []byte("g'day"), relayOptions{})
+
+	// assert
+	for _, conn := range []net.Conn{phoneClient, laptopClient} {
+		bytes, msgType, err := messages.Decode(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msgType != messages.MsgTypeRelay {
+			t.Errorf("relay failed. Expected %d, got %d", messages.MsgTypeRelay, msgType)
+		}
+		var result messages.Relay
+		err = proto.Unmarshal(bytes, &result)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(result.Body) != "g'day" {
+			t.Errorf("relay failed. Expected %q, got %q", "g'day", result.Body)
+		}
+	}
+}
+
+func TestHub_relay_sequenceRetention(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	connect := func() (*subscriber, <-chan testFrame) {
+		conn, client := net.Pipe()
+		sub := newTestSubscriber(123, conn)
+		h.addSession(sub)
+		return sub, readFrames(client)
+	}
+	sub, received := connect()
+
+	// act
+	h.relay(456, []int32{123, 789}, []byte("first"), relayOptions{})
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("first"), From: 456, Sequence: 1})
+	h.removeSession(sub)
+	h.relay(456, []int32{123}, []byte("missed"), relayOptions{})
+	sub, received = connect()
+	h.relay(456, []int32{123}, []byte("again"), relayOptions{})
+
+	// assert, the relay missed meanwhile leaves a gap
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("again"), From: 456, Sequence: 3})
+	// numbers of a receiver offline for longer are dropped, receivers never online have none
+	h.removeSession(sub)
+	h.sequences.disconnected(0, time.Now().Add(sequenceRetention))
+	if len(h.sequences.last) != 0 || len(h.sequences.offline) != 0 {
+		t.Errorf("disconnected failed. Expected no numbers kept, got %v", h.sequences.last)
+	}
+	_, received = connect()
+	h.relay(456, []int32{123}, []byte("later"), relayOptions{})
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("later"), From: 456, Sequence: 1})
+}
+
+func TestHub_relayRequest_ttl(t *testing.T) {
+	// arrange
+	receiverConn, receiverClient := net.Pipe()
+	senderConn, senderClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	receiver := newTestSubscriber(123, receiverConn)
+	h.addSession(receiver)
+	sender := newTestSubscriber(234, senderConn)
+	received := readFrames(receiverClient)
+	receipts := readFrames(senderClient)
+	relay := func(body string, ttl uint32, receipt uint64) {
+		request := &messages.RelayRequest{Ids: []int32{123}, Body: []byte(body), Ttl: ttl, Receipt: receipt}
+		frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, request, messages.MsgTypeRelayRequest)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.relayRequest(sender, frame)
+	}
+
+	// act
+	// the receiver is busy until the relay expired in its queue
+	receiver.writeLock.Lock()
+	relay("late", 1, 1)
+	time.Sleep(10 * time.Millisecond)
+	receiver.writeLock.Unlock()
+
+	// assert
+	expectFrame(t, receipts, messages.MsgTypeRelayReceipt, &messages.RelayReceipt{Receipt: 1, Id: 123, Status: messages.RelayReceipt_EXPIRED})
+	relay("g'day", 1000, 2)
+	// the expired relay leaves a gap
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'day"), From: 234, Sequence: 2})
+	expectFrame(t, receipts, messages.MsgTypeRelayReceipt, &messages.RelayReceipt{Receipt: 2, Id: 123, Status: messages.RelayReceipt_DELIVERED})
+}
+
+func TestHub_relayRequest_call(t *testing.T) {
+	// arrange
+	receiverConn, receiverClient := net.Pipe()
+	senderConn, senderClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	receiver := newTestSubscriber(123, receiverConn)
+	sender := newTestSubscriber(234, senderConn)
+	h.addSession(receiver)
+	h.addSession(sender)
+	received := readFrames(receiverClient)
+	replies := readFrames(senderClient)
+	relay := func(sub *subscriber, request *messages.RelayRequest) {
+		frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, request, messages.MsgTypeRelayRequest)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.relayRequest(sub, frame)
+	}
+
+	// act
+	relay(sender, &messages.RelayRequest{Ids: []int32{123}, Body: []byte("ping"), Call: 5})
+	expectFrame(t, received, messages.MsgTypeRelay, &messages.Relay{Body: []byte("ping"), From: 234, Sequence: 1, Call: 5})
+	relay(receiver, &messages.RelayRequest{Ids: []int32{234}, Body: []byte("pong"), Reply: 5})
+	// nobody is online to take the call
+	relay(sender, &messages.RelayRequest{Ids: []int32{345}, Body: []byte("ping"), Call: 6})
+
+	// assert
+	expectFrame(t, replies, messages.MsgTypeRelay, &messages.Relay{Body: []byte("pong"), From: 123, Sequence: 1, Reply: 5})
+	expectFrame(t, replies, messages.MsgTypeRelay, &messages.Relay{Sequence: 1, Reply: 6, Error: messages.ReplyNoResponder})
+}
+
+// TestHub_relayRequest_order relays from several senders at once, with priorities and body
+// sizes mixed, and checks every session of the receiver gets the relays of each sender in order
+func TestHub_relayRequest_order(t *testing.T) {
+	// arrange
+	const senders, relays = 8, 200
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	var received []<-chan testFrame
+	for i := 0; i < 2; i++ {
+		conn, client := net.Pipe()
+		h.addSession(newTestSubscriber(123, conn))
+		received = append(received, readFrames(client))
+	}
+
+	// act
+	var wg sync.WaitGroup
+	for i := int32(1); i <= senders; i++ {
+		wg.Add(1)
+		// every sender relays from a goroutine of its own like a connection
+		go func(sender int32) {
+			defer wg.Done()
+			conn, _ := net.Pipe()
+			sub := newTestSubscriber(sender, conn)
+			// relays of a sender go to all lanes, every lane numbers its own
+			counts := make(map[messages.RelayRequest_Priority]int)
+			for i := 1; i <= relays; i++ {
+				priority := messages.RelayRequest_Priority((int(sender) + i) % 3)
+				counts[priority]++
+				n := counts[priority]
+				body := append([]byte(strconv.Itoa(n)+" "), make([]byte, n%7*1000)...)
+				request := &messages.RelayRequest{Ids: []int32{123}, Body: body, Priority: priority}
+				frame, err := messages.DecodeFrame(bytes.NewReader(encode(t, request, messages.MsgTypeRelayRequest)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				h.relayRequest(sub, frame)
+				frame.Release()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// assert
+	// relays of a sender keep their order per lane
+	type lane struct {
+		from     int32
+		priority messages.RelayRequest_Priority
+	}
+	for session, frames := range received {
+		last := make(map[lane]uint64)
+		for i := 0; i < senders*relays; i++ {
+			frame := <-frames
+			var relay messages.Relay
+			if err := relay.Unmarshal(frame.bytes); err != nil {
+				t.Fatal(err)
+			}
+			n, _ := strconv.Atoi(string(bytes.SplitN(relay.Body, []byte(" "), 2)[0]))
+			key := lane{relay.From, relay.Priority}
+			if relay.Sequence != last[key]+1 || uint64(n) != relay.Sequence {
+				t.Fatalf("relayRequest failed. Session %d expected relay %d from %d with priority %s, got relay %d with sequence %d",
+					session, last[key]+1, relay.From, relay.Priority, n, relay.Sequence)
+			}
+			last[key] = relay.Sequence
+		}
+	}
+}
+
+func TestHub_relayRequest_compressed(t *testing.T) {
+	// arrange
+	codec := messages.LookupCodec("deflate")
+	compressing, compressingClient := net.Pipe()
+	plain, plainClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	sender := newTestSubscriber(456, nil)
+	sender.codec = codec
+	receiver := newTestSubscriber(123, compressing)
+	receiver.codec = codec
+	h.addSession(receiver)
+	h.addSession(newTestSubscriber(123, plain))
+
+	var buf bytes.Buffer
+	enc := messages.NewEncoder(&buf)
+	enc.SetCodec(codec)
+	body := bytes.Repeat([]byte("g'day "), 200)
+	enc.Encode(&messages.RelayRequest{Ids: []int32{123}, Body: body}, messages.MsgTypeRelayRequest)
+	enc.Flush()
+	frame, err := messages.DecodeFrame(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	h.relayRequest(sender, frame)
+	frame.Release()
+
+	// assert
+	for _, tt := range []struct {
+		conn       net.Conn
+		compressed bool
+	}{{compressingClient, true}, {plainClient, false}} {
+		f, err := messages.DecodeFrame(tt.conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Type() != messages.MsgTypeRelay || f.Compressed() != tt.compressed {
+			t.Errorf("relayRequest failed. Expected compressed %v relay, got %v %v", tt.compressed, f.Type(), f.Compressed())
+		}
+		payload := f.Payload()
+		if f.Compressed() {
+			payload, err = messages.Decompress(f, codec, messages.MaxDecompressedLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var result messages.Relay
+		err = proto.Unmarshal(payload, &result)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(result.Body, body) {
+			t.Errorf("relayRequest failed. Expected %d bytes body, got %d", len(body), len(result.Body))
+		}
+		f.Release()
+	}
+}
+
+func TestHub_removeSession(t *testing.T) {
+	// arrange
+	server, _ := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	phone := newTestSubscriber(123, server)
+	laptop := newTestSubscriber(123, server)
+	h.addSession(phone)
+	h.addSession(laptop)
+
+	// act
+	h.removeSession(phone)
+
+	// assert
+	if len(h.subscribers[123]) != 1 || h.subscribers[123][laptop.session] != laptop {
+		t.Errorf("removeSession failed. Unexpected sessions %#v", h.subscribers[123])
+	}
+	h.removeSession(laptop)
+	if _, ok := h.subscribers[123]; ok {
+		t.Error("removeSession failed. User is still subscribed")
+	}
+}
+
+// expectIdentity reads an identity response with a session token and returns its id
+func expectIdentity(t *testing.T, frames <-chan testFrame) int32 {
+	t.Helper()
+	frame := <-frames
+	var resp messages.IdentityResponse
+	if frame.msgType != messages.MsgTypeIdentityResponse || resp.Unmarshal(frame.bytes) != nil || resp.Token == "" {
+		t.Fatalf("Expected identity response with token, got %s %v", frame.msgType, frame.bytes)
+	}
+	return resp.Id
+}
+
+func newTestSubscriber(id int32, conn net.Conn) *subscriber {
+	sub := newSubscriber(conn)
+	sub.id = id
+	return sub
+}
+
+// BenchmarkHub_relayRequest shows allocations of a relay fanned out to 10 receivers:
+//
+//	go test -bench . -benchmem ./hub
+func BenchmarkHub_relayRequest(b *testing.B) {
+	var wg sync.WaitGroup
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	var ids []int32
+	for id := int32(1); id <= 10; id++ {
+		h.addSession(newTestSubscriber(id, discardConn{wg: &wg}))
+		ids = append(ids, id)
+	}
+	request, err := messages.Encode(&messages.RelayRequest{
+		Id:   100,
+		Ids:  ids,
+		Body: bytes.Repeat([]byte{'a'}, 512),
+	}, messages.MsgTypeRelayRequest)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sender := newTestSubscriber(100, discardConn{wg: &wg})
+	r := bytes.NewReader(request)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(request)
+		frame, err := messages.DecodeFrame(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		wg.Add(len(ids))
+		h.relayRequest(sender, frame)
+		frame.Release()
+		wg.Wait()
+	}
+}
+
+func TestHub_Run_listenerSettings(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	receiverConn, receiverClient := net.Pipe()
+	h := NewHub(zap.L(), nil, nil)
+	h.Listen(&mockListener{conn: server}, ListenerConfig{
+		Protocol:    ProtocolProtobuf,
+		RequireAuth: true,
+		Limits:      Limits{MaxBody: 3},
+	})
+	h.addSession(newTestSubscriber(123, receiverConn))
+	receiverFrames := readFrames(receiverClient)
+	go h.Run()
+
+	// act
+	enc := messages.NewEncoder(client)
+	enc.Encode(&messages.RelayRequest{Id: 456, Ids: []int32{123}, Body: []byte("spoofed")}, messages.MsgTypeRelayRequest)
+	enc.Encode(&messages.Request{Type: messages.Request_IDENTITY}, messages.MsgTypeRequest)
+	enc.Encode(&messages.RelayRequest{Ids: []int32{123}, Body: []byte("g'day")}, messages.MsgTypeRelayRequest)
+	go enc.Flush()
+	_, msgType, err := messages.Decode(client)
+
+	// assert
+	if err != nil || msgType != messages.MsgTypeIdentityResponse {
+		t.Fatalf("Run failed. Expected %s, got %s %v", messages.MsgTypeIdentityResponse, msgType, err)
+	}
+	// relays before identity are rejected, max body of the listener cuts the body
+	expectFrame(t, receiverFrames, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'd"), From: 1, Sequence: 1})
+}
+
+func TestHub_accept_maxConnections(t *testing.T) {
+	// arrange
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	h := NewHub(zap.L(), nil, nil)
+	l := &listener{Listener: ln, config: ListenerConfig{Protocol: ProtocolAuto, Limits: Limits{MaxConnections: 1}}}
+	go h.accept(l)
+
+	// act, a socket which never sends a byte still takes its slot
+	idle, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt64(&l.connections) == 1 })
+	rejected, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rejected.Close()
+
+	// assert
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := rejected.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("accept failed. Expected connection over the limit closed, got %v", err)
+	}
+	idle.Close()
+	waitFor(t, func() bool {
+		return atomic.LoadInt64(&l.connections) == 0 && atomic.LoadInt64(&h.connections) == 0
+	})
+}
+
+// discardConn drops written frames and marks them done in wg
+type discardConn struct {
+	net.Conn
+	wg *sync.WaitGroup
+}
+
+func (c discardConn) Write(b []byte) (int, error) {
+	c.wg.Done()
+	return len(b), nil
+}
+
+// mockListener accepts conn once, then fails as a closed listener
 type mockListener struct {
 	conn net.Conn
 }
 
 func (m *mockListener) Accept() (net.Conn, error) {
-	return m.conn, nil
+	if m.conn == nil {
+		return nil, errors.New("use of closed network connection")
+	}
+	conn := m.conn
+	m.conn = nil
+	return conn, nil
 }
 
 func (m *mockListener) Close() error {
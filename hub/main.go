@@ -1,36 +1,275 @@
 package main
 
 import (
+	"crypto/tls"
+	"flag"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"fmt"
 
 	"go.uber.org/zap"
 )
 
-const (
-	port = 8888
-)
-
 func main() {
+	loader := NewConfigLoader(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bad configuration: %s\n", err.Error())
+		os.Exit(2)
+	}
+
 	// init logger
-	l, err := zap.NewProduction()
+	logConfig := zap.NewProductionConfig()
+	logConfig.Encoding = cfg.Log.Format
+	logConfig.Level.UnmarshalText([]byte(cfg.Log.Level))
+	l, err := logConfig.Build()
 	if err != nil {
 		panic(err)
 	}
 
-	// initialize listener
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	// load access-control rules
+	acl, err := NewACL(cfg.ACL.File)
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		ln.Close()
-	}()
 
-	l.Info("Listening for requests", zap.Int("port", port))
+	// serve metrics if requested
+	var metrics *Metrics
+	if cfg.Listen.Metrics != "" {
+		metrics = NewMetrics()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go serveHTTP(l, "metrics", cfg.Listen.Metrics, mux)
+	}
 
 	// initialize hub
-	hub := NewHub(l, ln)
+	hub := NewHub(l, acl, metrics)
+	hub.Configure(cfg.Limits, cfg.Timeouts)
+	codecs, _ := cfg.Listen.Codecs()
+	hub.SetCodecs(codecs)
+
+	// initialize listeners
+	listeners, _ := cfg.Listen.Listeners(cfg.TLS.CertFile != "")
+	for _, lc := range listeners {
+		ln, err := listen(lc, cfg.TLS)
+		if err != nil {
+			panic(err)
+		}
+		defer ln.Close()
+
+		l.Info("Listening for requests", zap.String("network", lc.Network), zap.String("addr", lc.Addr),
+			zap.Bool("tls", lc.TLS), zap.String("protocol", lc.Protocol), zap.Bool("require_auth", lc.RequireAuth))
+		hub.Listen(ln, lc)
+	}
+
+	// make the hub a node of the cluster if configured, it joins once the hub is set up
+	var cluster *Cluster
+	if cfg.Cluster.Node != 0 {
+		cluster = newCluster(l, hub, cfg.Cluster)
+	}
+
+	// keep users on disk if configured, after the cluster which sets the node of ids
+	if cfg.Store.Users == "file" {
+		users, err := OpenUsers(int32(cfg.Cluster.Node), cfg.Store.Path)
+		if err != nil {
+			panic(err)
+		}
+		defer users.Close()
+		hub.SetUsers(users)
+	}
+
+	// keep relays in history if configured
+	if cfg.History.Size > 0 {
+		hub.SetHistory(NewHistory(cfg.History.Size, cfg.History.Conversations))
+	}
+
+	// drop repeated relays if configured
+	if cfg.Dedup.Window > 0 {
+		hub.SetDedup(NewDedup(time.Duration(cfg.Dedup.Window), cfg.Dedup.Size))
+	}
+
+	hub.SetGroupBalance(cfg.Groups.Balance)
+	hub.SetGroupAckTimeout(time.Duration(cfg.Groups.AckTimeout))
+
+	// accept WebSocket clients if requested
+	if cfg.Listen.WebSocket != "" {
+		go serveWebSocket(l, hub, cfg)
+	}
+
+	// serve admin API if requested
+	var admin *Admin
+	if cfg.Listen.Admin != "" {
+		admin = NewAdmin(l, hub, cfg.Admin.Token, logConfig.Level, cfg)
+		go serveHTTP(l, "admin", cfg.Listen.Admin, admin)
+	}
+
+	r := &reloader{
+		logger: l,
+		loader: loader,
+		config: cfg,
+		hub:    hub,
+		acl:    acl,
+		level:  logConfig.Level,
+		admin:  admin,
+	}
+	go r.reloadOnSignal()
+
+	// peers reach the hub once all of it is set up
+	if cluster != nil {
+		if err := startCluster(l, cluster, cfg.Cluster); err != nil {
+			panic(err)
+		}
+	}
+
 	hub.Run()
 }
+
+func listen(lc ListenerConfig, cfg TLSConfig) (net.Listener, error) {
+	if lc.Network == "unix" {
+		removeStaleSocket(lc.Addr)
+	}
+	ln, err := net.Listen(lc.Network, lc.Addr)
+	if err != nil || !lc.TLS {
+		return ln, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}}), nil
+}
+
+// removeStaleSocket removes a socket file left by a hub which didn't shut down cleanly,
+// sockets still accepting connections are kept
+func removeStaleSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return
+	}
+	os.Remove(path)
+}
+
+// newCluster makes hub the node of a cluster configured by cfg
+func newCluster(l *zap.Logger, hub *Hub, cfg ClusterConfig) *Cluster {
+	advertise := cfg.Advertise
+	if advertise == "" {
+		advertise = cfg.Addr
+	}
+
+	cluster := NewCluster(l, hub, int32(cfg.Node), advertise, cfg.Secret, time.Duration(cfg.Gossip))
+	hub.SetCluster(cluster)
+	return cluster
+}
+
+// startCluster serves peers of cluster and joins the peers configured by cfg
+func startCluster(l *zap.Logger, cluster *Cluster, cfg ClusterConfig) error {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	go cluster.Serve(ln)
+	go cluster.Run()
+	for _, addr := range strings.Split(cfg.Peers, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		if err := cluster.Join(addr); err != nil {
+			l.Error("join failed", zap.Error(err))
+		}
+	}
+
+	l.Info("Joined cluster", zap.Int("node", cfg.Node), zap.String("addr", cfg.Addr))
+	return nil
+}
+
+func serveHTTP(l *zap.Logger, name, addr string, handler http.Handler) {
+	l.Info("Serving "+name, zap.String("addr", addr))
+	if err := http.ListenAndServe(addr, handler); err != nil {
+		l.Error(name+" listener failed", zap.Error(err))
+	}
+}
+
+// serveWebSocket serves WebSocket clients, over TLS if tls.cert_file is set
+func serveWebSocket(l *zap.Logger, hub *Hub, cfg Config) {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", NewWebSocketHandler(hub))
+	server := &http.Server{
+		Addr:    cfg.Listen.WebSocket,
+		Handler: mux,
+		// connections can't be hijacked over HTTP/2
+		TLSNextProto: map[string]func(*http.Server, *tls.Conn, http.Handler){},
+	}
+
+	l.Info("Serving websocket", zap.String("addr", cfg.Listen.WebSocket), zap.Bool("tls", cfg.TLS.CertFile != ""))
+	var err error
+	if cfg.TLS.CertFile != "" {
+		err = server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
+		l.Error("websocket listener failed", zap.Error(err))
+	}
+}
+
+// reloader applies configuration changes on SIGHUP
+type reloader struct {
+	logger *zap.Logger
+	loader *ConfigLoader
+	config Config
+	hub    *Hub
+	acl    *ACL
+	level  zap.AtomicLevel
+	admin  *Admin
+}
+
+func (r *reloader) reloadOnSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	for range sigChan {
+		r.reload()
+	}
+}
+
+// reload re-reads configuration and applies the fields which can change at runtime:
+// limits, timeouts, log level and the contents of the ACL file
+func (r *reloader) reload() {
+	cfg, err := r.loader.Load()
+	if err != nil {
+		r.logger.Error("config reload failed", zap.Error(err))
+		return
+	}
+	if changed := r.config.StaticChanges(cfg); len(changed) > 0 {
+		r.logger.Warn("config changes require restart", zap.String("sections", strings.Join(changed, ",")))
+	}
+
+	r.hub.Configure(cfg.Limits, cfg.Timeouts)
+	r.level.UnmarshalText([]byte(cfg.Log.Level))
+	if err := r.acl.Reload(); err != nil {
+		r.logger.Error("acl reload failed", zap.Error(err))
+	}
+
+	// keep settings which were not applied, so they are reported again on next reload
+	r.config.Limits = cfg.Limits
+	r.config.Timeouts = cfg.Timeouts
+	r.config.Log.Level = cfg.Log.Level
+	if r.admin != nil {
+		r.admin.SetConfig(r.config)
+	}
+	r.logger.Info("config reloaded")
+}
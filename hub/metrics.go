@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+const (
+	dropOffline = "offline"
+	dropACL     = "acl"
+	// compressed relays which could not be decompressed for a receiver
+	dropCorrupt = "corrupt"
+	// relays whose ttl ran out in an outbound queue or in history
+	dropExpired = "expired"
+	// repeats of a relay with the same idempotency key
+	dropDuplicate = "duplicate"
+)
+
+var (
+	fanoutBuckets  = []float64{1, 2, 5, 10, 25, 50, 100, 255}
+	latencyBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+)
+
+// Metrics collects hub statistics and serves them in Prometheus text format.
+// All methods are safe to call on a nil *Metrics, they do nothing then.
+type Metrics struct {
+	connectedClients gauge
+	outboundQueue    gauge
+	framesIn         counterVec
+	framesOut        counterVec
+	bytesIn          counter
+	bytesOut         counter
+	dropped          counterVec
+	decodeErrors     counter
+	groupFailovers   counter
+	relayFanout      histogramVec
+	requestLatency   histogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		connectedClients: gauge{name: "hub_connected_clients", help: "Number of open client connections."},
+		outboundQueue:    gauge{name: "hub_outbound_queue_depth", help: "Number of frames waiting to be written to clients."},
+		framesIn:         counterVec{name: "hub_frames_received_total", help: "Frames received from clients by message type.", label: "msg_type"},
+		framesOut:        counterVec{name: "hub_frames_sent_total", help: "Frames sent to clients by message type.", label: "msg_type"},
+		bytesIn:          counter{name: "hub_received_bytes_total", help: "Bytes received from clients."},
+		bytesOut:         counter{name: "hub_sent_bytes_total", help: "Bytes sent to clients."},
+		dropped:          counterVec{name: "hub_dropped_messages_total", help: "Relay messages not delivered to a receiver by reason.", label: "reason"},
+		decodeErrors:     counter{name: "hub_decode_errors_total", help: "Frames which failed to decode or unmarshal."},
+		groupFailovers:   counter{name: "hub_group_failovers_total", help: "Relays to queue groups sent to another member after the session they were given to was lost."},
+		relayFanout:      histogramVec{name: "hub_relay_fanout", help: "Number of receivers a relay message is delivered to.", buckets: fanoutBuckets},
+		requestLatency:   histogramVec{name: "hub_request_duration_seconds", help: "Time spent handling a request by request type.", label: "request", buckets: latencyBuckets},
+	}
+}
+
+func (m *Metrics) ClientConnected() {
+	if m != nil {
+		m.connectedClients.add(1)
+	}
+}
+
+func (m *Metrics) ClientDisconnected() {
+	if m != nil {
+		m.connectedClients.add(-1)
+	}
+}
+
+// FrameReceived counts an incoming frame of size bytes including header
+func (m *Metrics) FrameReceived(msgType messages.MsgType, size int) {
+	if m != nil {
+		m.framesIn.inc(msgType.String())
+		m.bytesIn.add(uint64(size))
+	}
+}
+
+// FrameQueued counts a frame waiting to be written
+func (m *Metrics) FrameQueued() {
+	if m != nil {
+		m.outboundQueue.add(1)
+	}
+}
+
+// FrameDiscarded removes a frame dropped from the outbound queue without writing it
+func (m *Metrics) FrameDiscarded() {
+	if m != nil {
+		m.outboundQueue.add(-1)
+	}
+}
+
+// FrameSent counts an outgoing frame of size bytes including header and
+// removes it from the outbound queue if it was queued
+func (m *Metrics) FrameSent(msgType messages.MsgType, size int, queued bool) {
+	if m != nil {
+		if queued {
+			m.outboundQueue.add(-1)
+		}
+		m.framesOut.inc(msgType.String())
+		m.bytesOut.add(uint64(size))
+	}
+}
+
+func (m *Metrics) MessageDropped(reason string) {
+	if m != nil {
+		m.dropped.inc(reason)
+	}
+}
+
+func (m *Metrics) DecodeError() {
+	if m != nil {
+		m.decodeErrors.add(1)
+	}
+}
+
+// GroupFailover counts a relay to a queue group sent to another member
+func (m *Metrics) GroupFailover() {
+	if m != nil {
+		m.groupFailovers.add(1)
+	}
+}
+
+func (m *Metrics) RelayFanout(receivers int) {
+	if m != nil {
+		m.relayFanout.observe("", float64(receivers))
+	}
+}
+
+func (m *Metrics) RequestHandled(request string, start time.Time) {
+	if m != nil {
+		m.requestLatency.observe(request, time.Since(start).Seconds())
+	}
+}
+
+// ServeHTTP writes all metrics in Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	bw := bufio.NewWriter(w)
+	m.connectedClients.write(bw)
+	m.outboundQueue.write(bw)
+	m.framesIn.write(bw)
+	m.framesOut.write(bw)
+	m.bytesIn.write(bw)
+	m.bytesOut.write(bw)
+	m.dropped.write(bw)
+	m.decodeErrors.write(bw)
+	m.groupFailovers.write(bw)
+	m.relayFanout.write(bw)
+	m.requestLatency.write(bw)
+	bw.Flush()
+}
+
+type counter struct {
+	name, help string
+	value      uint64
+}
+
+func (c *counter) add(v uint64) {
+	atomic.AddUint64(&c.value, v)
+}
+
+func (c *counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, atomic.LoadUint64(&c.value))
+}
+
+type gauge struct {
+	name, help string
+	value      int64
+}
+
+func (g *gauge) add(v int64) {
+	atomic.AddInt64(&g.value, v)
+}
+
+func (g *gauge) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %d\n", g.name, atomic.LoadInt64(&g.value))
+}
+
+type counterVec struct {
+	name, help, label string
+	lock              sync.Mutex
+	values            map[string]uint64
+}
+
+func (c *counterVec) inc(labelValue string) {
+	c.lock.Lock()
+	if c.values == nil {
+		c.values = make(map[string]uint64)
+	}
+	c.values[labelValue]++
+	c.lock.Unlock()
+}
+
+func (c *counterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, lv := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, lv, c.values[lv])
+	}
+}
+
+// histogramVec is a histogram partitioned by a single label.
+// An empty label name gives a plain histogram.
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+	lock              sync.Mutex
+	values            map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogramVec) observe(labelValue string, v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.values == nil {
+		h.values = make(map[string]*histogram)
+	}
+	hist, ok := h.values[labelValue]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[labelValue] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *histogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, lv := range sortedKeys(h.values) {
+		hist := h.values[lv]
+		labels := ""
+		if h.label != "" {
+			labels = fmt.Sprintf("%s=%q,", h.label, lv)
+		}
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", h.name, labels, formatFloat(upper), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, labels, hist.count)
+		if labels != "" {
+			labels = "{" + labels[:len(labels)-1] + "}"
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, hist.count)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]uint64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
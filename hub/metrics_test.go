@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	// arrange
+	m := NewMetrics()
+	m.ClientConnected()
+	m.ClientConnected()
+	m.ClientDisconnected()
+	m.FrameReceived(messages.MsgTypeRelayRequest, 20)
+	m.FrameQueued()
+	m.FrameQueued()
+	m.FrameSent(messages.MsgTypeRelay, 10, true)
+	m.MessageDropped(dropOffline)
+	m.DecodeError()
+	m.RelayFanout(3)
+
+	// act
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert
+	expected := []string{
+		"# TYPE hub_connected_clients gauge",
+		"hub_connected_clients 1",
+		"hub_outbound_queue_depth 1",
+		`hub_frames_received_total{msg_type="RelayRequest"} 1`,
+		`hub_frames_sent_total{msg_type="Relay"} 1`,
+		"hub_received_bytes_total 20",
+		"hub_sent_bytes_total 10",
+		`hub_dropped_messages_total{reason="offline"} 1`,
+		"hub_decode_errors_total 1",
+		"# TYPE hub_relay_fanout histogram",
+		`hub_relay_fanout_bucket{le="2"} 0`,
+		`hub_relay_fanout_bucket{le="5"} 1`,
+		`hub_relay_fanout_bucket{le="+Inf"} 1`,
+		"hub_relay_fanout_sum 3",
+		"hub_relay_fanout_count 1",
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("ServeHTTP failed. Expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetrics_nil(t *testing.T) {
+	var m *Metrics
+	m.ClientConnected()
+	m.FrameReceived(messages.MsgTypeRequest, 5)
+	m.MessageDropped(dropACL)
+	m.RelayFanout(1)
+}
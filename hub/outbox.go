@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// Relays to a connection are queued in lanes by priority and written by a single goroutine
+// while any are queued, responses skip the queue and wait for the relay being written only.
+// Lanes are served by deficit round robin: every round a lane may write its quantum of bytes
+// and carries what it doesn't spend over while it has relays waiting. High priority relays get
+// most of the connection, yet normal and low ones keep their share, and a lane of large bodies
+// spends its quantum on few of them while other lanes write many small ones.
+const (
+	laneHigh = iota
+	laneNormal
+	laneLow
+	laneCount
+)
+
+// laneQuantum is the number of bytes a lane of weight 1 may write per round
+const laneQuantum = 16 << 10
+
+// laneWeights are the shares of the connection lanes get while all of them are busy
+var laneWeights = [laneCount]int{laneHigh: 8, laneNormal: 4, laneLow: 1}
+
+func laneOf(priority messages.RelayRequest_Priority) int {
+	switch priority {
+	case messages.RelayRequest_HIGH:
+		return laneHigh
+	case messages.RelayRequest_LOW:
+		return laneLow
+	default:
+		return laneNormal
+	}
+}
+
+type queuedRelay struct {
+	frame *messages.Frame
+	opts  relayOptions
+	// from and sequence are written along with the frame, see messages.AppendReceiverFields
+	from     int32
+	sequence uint64
+}
+
+// outbox is the outbound queue of a connection, relays of a lane are written in order
+type outbox struct {
+	lock    sync.Mutex
+	lanes   [laneCount][]queuedRelay
+	deficit [laneCount]int
+	queued  int
+	// current is the lane served, credited is set once it got its quantum in this round
+	current  int
+	credited bool
+	// writing is set while a goroutine drains the outbox
+	writing bool
+}
+
+// push queues relay and reports whether the caller has to start draining the outbox
+func (o *outbox) push(relay queuedRelay) bool {
+	lane := laneOf(relay.opts.priority)
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.lanes[lane] = append(o.lanes[lane], relay)
+	o.queued++
+	if o.writing {
+		return false
+	}
+	o.writing = true
+	return true
+}
+
+// pop takes the relay to write next, it returns false and stops draining once the outbox is empty
+func (o *outbox) pop() (queuedRelay, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.queued == 0 {
+		o.writing = false
+		return queuedRelay{}, false
+	}
+	for {
+		lane := o.lanes[o.current]
+		if len(lane) == 0 {
+			// an idle lane saves no quantum for later
+			o.deficit[o.current] = 0
+			o.lanes[o.current] = nil
+			o.next()
+			continue
+		}
+		if !o.credited {
+			o.deficit[o.current] += laneWeights[o.current] * laneQuantum
+			o.credited = true
+		}
+		relay := lane[0]
+		size := len(relay.frame.Bytes())
+		if size > o.deficit[o.current] {
+			o.next()
+			continue
+		}
+		o.deficit[o.current] -= size
+		lane[0] = queuedRelay{}
+		o.lanes[o.current] = lane[1:]
+		o.queued--
+		return relay, true
+	}
+}
+
+// depth returns the number of relays queued
+func (o *outbox) depth() int {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.queued
+}
+
+// next moves on to the following lane, the caller must hold the lock
+func (o *outbox) next() {
+	o.current = (o.current + 1) % laneCount
+	o.credited = false
+}
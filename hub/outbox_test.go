@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestOutbox_pop(t *testing.T) {
+	tests := []struct {
+		name   string
+		relays []queuedRelay
+		want   string
+	}{
+		{
+			"high first, large bodies spread over rounds",
+			[]queuedRelay{
+				testRelay('n', 40<<10, messages.RelayRequest_NORMAL),
+				testRelay('n', 40<<10, messages.RelayRequest_NORMAL),
+				testRelay('n', 40<<10, messages.RelayRequest_NORMAL),
+				testRelay('n', 40<<10, messages.RelayRequest_NORMAL),
+				testRelay('h', 1<<10, messages.RelayRequest_HIGH),
+				testRelay('h', 1<<10, messages.RelayRequest_HIGH),
+				testRelay('h', 1<<10, messages.RelayRequest_HIGH),
+				testRelay('l', 1<<10, messages.RelayRequest_LOW),
+			},
+			"hhhnlnnn",
+		},
+		{
+			"low is not starved by high",
+			append(repeatRelay(testRelay('h', 16<<10, messages.RelayRequest_HIGH), 10),
+				testRelay('l', 1<<10, messages.RelayRequest_LOW)),
+			"hhhhhhhlhhh",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			var o outbox
+			for i, relay := range tt.relays {
+				if start := o.push(relay); start != (i == 0) {
+					t.Fatalf("push failed. Expected draining started by the first relay only, got %v at %d", start, i)
+				}
+			}
+
+			// act
+			var got []byte
+			for {
+				relay, ok := o.pop()
+				if !ok {
+					break
+				}
+				// bodies are made of their label
+				payload := relay.frame.Payload()
+				got = append(got, payload[len(payload)-1])
+			}
+
+			// assert
+			if string(got) != tt.want {
+				t.Errorf("pop failed. Expected %s, got %s", tt.want, got)
+			}
+			if !o.push(tt.relays[0]) {
+				t.Error("push failed. Expected draining to start again after the outbox was emptied")
+			}
+		})
+	}
+}
+
+func TestHub_drain_writeFailed(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+		metrics:     NewMetrics(),
+	}
+	conn, client := net.Pipe()
+	client.Close()
+	sub := newTestSubscriber(123, conn)
+	for i := 0; i < 3; i++ {
+		h.metrics.FrameQueued()
+		sub.outbox.push(testRelay('n', 1, messages.RelayRequest_NORMAL))
+	}
+
+	// act
+	h.drain(sub)
+
+	// assert
+	if depth := atomic.LoadInt64(&h.metrics.outboundQueue.value); depth != 0 {
+		t.Errorf("drain failed. Expected frames failing to write off the queue, got depth %d", depth)
+	}
+}
+
+func testRelay(label byte, size int, priority messages.RelayRequest_Priority) queuedRelay {
+	body := bytes.Repeat([]byte{label}, size)
+	return queuedRelay{frame: encodeRelay(body, 0, 0), opts: relayOptions{priority: priority}}
+}
+
+func repeatRelay(relay queuedRelay, n int) []queuedRelay {
+	relays := make([]queuedRelay, n)
+	for i := range relays {
+		relays[i] = relay
+	}
+	return relays
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// Client protocols of a listener. Both carry the messages of messages.proto, so protobuf
+// and JSON clients relay to each other and JSON frames are translated at the connection.
+const (
+	// ProtocolAuto detects JSON clients by their first byte, frames never start with '{'
+	ProtocolAuto     = "auto"
+	ProtocolProtobuf = "protobuf"
+	ProtocolJSON     = "json"
+)
+
+// jsonExpansion bounds the length of a JSON line relative to the frame it carries,
+// base64 bodies are 4/3 longer and field names are spelled out
+const jsonExpansion = 2
+
+// clientConn wraps conn to translate JSON lines to frames if the client speaks JSON
+func (h *Hub) clientConn(conn net.Conn, protocol string, limits Limits) (net.Conn, error) {
+	maxLine := jsonExpansion * limits.MaxFrame
+	switch protocol {
+	case ProtocolJSON:
+		return messages.NewJSONConn(conn, maxLine), nil
+	case ProtocolAuto:
+	default:
+		return conn, nil
+	}
+
+	if timeout := h.currentSettings().timeouts.Read; timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(timeout)))
+	}
+	first := make([]byte, 1)
+	if _, err := conn.Read(first); err != nil {
+		return nil, err
+	}
+	peeked := &peekedConn{Conn: conn, peeked: first}
+	if first[0] == '{' {
+		return messages.NewJSONConn(peeked, maxLine), nil
+	}
+	return peeked, nil
+}
+
+// peekedConn returns bytes read to detect the protocol before reading on from conn
+type peekedConn struct {
+	net.Conn
+	peeked []byte
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	if len(c.peeked) == 0 {
+		return c.Conn.Read(p)
+	}
+	n := copy(p, c.peeked)
+	c.peeked = c.peeked[n:]
+	return n, nil
+}
+
+// WriteBuffers keeps writes of messages.Encoder a single writev on conn
+func (c *peekedConn) WriteBuffers(bufs net.Buffers) (int64, error) {
+	if bw, ok := c.Conn.(messages.BuffersWriter); ok {
+		return bw.WriteBuffers(bufs)
+	}
+	return bufs.WriteTo(c.Conn)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestHub_serveConn_json(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	tcpConn, tcpClient := net.Pipe()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: NewUsers(),
+	}
+	h.SetCodecs([]messages.Codec{messages.LookupCodec("deflate")})
+	h.addSession(newTestSubscriber(123, tcpConn))
+	tcpFrames := readFrames(tcpClient)
+	go h.serveConn(server, &listener{config: ListenerConfig{Protocol: ProtocolAuto}})
+	lines := bufio.NewReader(client)
+
+	// act
+	go client.Write([]byte(`{"Request":{"type":"IDENTITY","codecs":["deflate"]}}` + "\n"))
+	identity, err := lines.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	go client.Write([]byte(`{"RelayRequest":{"ids":[123],"body":"ZydkYXk="}}` + "\n"))
+
+	// assert
+	// JSON clients don't get compression
+	if !strings.HasPrefix(identity, `{"IdentityResponse":{"id":1,"token":`) || strings.Contains(identity, "codec") {
+		t.Errorf("serveConn failed. Unexpected identity response %q", identity)
+	}
+	expectFrame(t, tcpFrames, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'day"), From: 1, Sequence: 1})
+}
+
+func TestHub_clientConn(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		first    byte
+		json     bool
+	}{
+		{"auto detects json", ProtocolAuto, '{', true},
+		{"auto detects protobuf", ProtocolAuto, byte(messages.MsgTypeHello), false},
+		{"json", ProtocolJSON, 0, true},
+		{"protobuf", ProtocolProtobuf, '{', false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			server, client := net.Pipe()
+			defer client.Close()
+			h := &Hub{}
+			go client.Write([]byte{tt.first})
+
+			// act
+			conn, err := h.clientConn(server, tt.protocol, Limits{})
+
+			// assert
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := conn.(*messages.JSONConn); ok != tt.json {
+				t.Errorf("clientConn failed. Expected json %v, got %T", tt.json, conn)
+			}
+			if tt.json {
+				return
+			}
+			first := make([]byte, 1)
+			if _, err := conn.Read(first); err != nil || first[0] != tt.first {
+				t.Errorf("clientConn failed. Expected first byte %d, got %d %v", tt.first, first[0], err)
+			}
+		})
+	}
+}
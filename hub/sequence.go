@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// sequenceRetention is how long numbers of a receiver are kept after its last session is gone
+const sequenceRetention = 10 * time.Minute
+
+// sequenceTable numbers relays from a sender to a receiver per priority, relays of a priority
+// are queued and written to every session of the receiver in that order. Lanes of higher
+// priority overtake lower ones, so order and numbers hold per sender, receiver and priority.
+// Numbers of a receiver are kept while it has sessions and for sequenceRetention after the last
+// one is gone, so a receiver reconnecting meanwhile can tell the relays it missed.
+// Its zero value is ready to use.
+type sequenceTable struct {
+	lock sync.Mutex
+	// last numbers by receiver
+	last map[int32]map[sequenceKey]uint64
+	// offline holds when receivers of last lost their last session
+	offline map[int32]time.Time
+}
+
+type sequenceKey struct {
+	from     int32
+	priority messages.RelayRequest_Priority
+}
+
+// next returns the number of the next relay from sender to receiver, the caller must hold the lock
+func (t *sequenceTable) next(from, to int32, priority messages.RelayRequest_Priority) uint64 {
+	if t.last == nil {
+		t.last = make(map[int32]map[sequenceKey]uint64)
+	}
+	last, ok := t.last[to]
+	if !ok {
+		last = make(map[sequenceKey]uint64)
+		t.last[to] = last
+	}
+	key := sequenceKey{from: from, priority: priority}
+	last[key]++
+	return last[key]
+}
+
+// skip takes the number of a relay missed by a receiver without sessions, so its next session
+// can tell the gap. Receivers whose numbers aren't kept anymore start over. The caller must
+// hold the lock.
+func (t *sequenceTable) skip(from, to int32, priority messages.RelayRequest_Priority) {
+	if last, ok := t.last[to]; ok {
+		last[sequenceKey{from: from, priority: priority}]++
+	}
+}
+
+// connected keeps numbers of receiver for as long as it has sessions
+func (t *sequenceTable) connected(receiver int32) {
+	t.lock.Lock()
+	delete(t.offline, receiver)
+	t.lock.Unlock()
+}
+
+// disconnected starts the retention of numbers of receiver which lost its last session at now,
+// numbers of receivers offline for longer are dropped
+func (t *sequenceTable) disconnected(receiver int32, now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.last[receiver]; ok {
+		if t.offline == nil {
+			t.offline = make(map[int32]time.Time)
+		}
+		t.offline[receiver] = now
+	}
+	for id, since := range t.offline {
+		if now.Sub(since) >= sequenceRetention {
+			delete(t.last, id)
+			delete(t.offline, id)
+		}
+	}
+}
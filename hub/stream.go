@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+// Streams relay payloads of any size in chunks. A sender opens a stream with an odd id,
+// the hub opens it to every receiver session with an even hub-wide id, then passes
+// chunks on in order from the reader of the sender connection.
+//
+// Flow control is credit based: the sender may have messages.StreamWindow chunks more
+// than the slowest receiver has acked, the hub grants credits to the sender as receivers
+// ack chunks. So a stream never holds more than a window of chunks per receiver.
+// Streams are relayed to receivers on this hub only.
+
+// relayStream is a stream being relayed from a sender session to receiver sessions
+type relayStream struct {
+	id           uint64
+	senderStream uint64
+	sender       *subscriber
+	// receivers map receiver sessions to the number of chunks they acked
+	receivers map[*subscriber]int
+	// sent is the number of chunks accepted from the sender
+	sent int
+	// acked is the number of chunks acked by every receiver, credited to the sender
+	acked int
+}
+
+type streamKey struct {
+	session uint64
+	stream  uint64
+}
+
+// streamTable holds streams being relayed, its zero value is ready to use
+type streamTable struct {
+	lock sync.Mutex
+	last uint64
+	// byID indexes streams by the id seen by receivers
+	byID map[uint64]*relayStream
+	// bySender indexes streams by sender session and the id chosen by the sender
+	bySender map[streamKey]*relayStream
+}
+
+func (t *streamTable) add(s *relayStream) {
+	if t.byID == nil {
+		t.byID = make(map[uint64]*relayStream)
+		t.bySender = make(map[streamKey]*relayStream)
+	}
+	t.last += 2
+	s.id = t.last
+	t.byID[s.id] = s
+	t.bySender[streamKey{s.sender.session, s.senderStream}] = s
+}
+
+func (t *streamTable) remove(s *relayStream) {
+	delete(t.byID, s.id)
+	delete(t.bySender, streamKey{s.sender.session, s.senderStream})
+}
+
+// minAcked returns the number of chunks acked by all receivers
+func (s *relayStream) minAcked() int {
+	acked := s.sent
+	for _, n := range s.receivers {
+		if n < acked {
+			acked = n
+		}
+	}
+	return acked
+}
+
+// credit returns the number of newly acked chunks which can be granted to the sender
+func (s *relayStream) credit() int {
+	acked := s.minAcked()
+	delta := acked - s.acked
+	s.acked = acked
+	return delta
+}
+
+func (s *relayStream) receiverList() []*subscriber {
+	receivers := make([]*subscriber, 0, len(s.receivers))
+	for sub := range s.receivers {
+		receivers = append(receivers, sub)
+	}
+	return receivers
+}
+
+func isClientStream(id uint64) bool {
+	return id%2 == 1
+}
+
+// handleStream dispatches stream frames received from sub
+func (h *Hub) handleStream(sub *subscriber, msgType messages.MsgType, bytes []byte) {
+	var err error
+	switch msgType {
+	case messages.MsgTypeStreamOpen:
+		err = h.openStream(sub, bytes)
+	case messages.MsgTypeStreamChunk:
+		err = h.streamChunk(sub, bytes)
+	case messages.MsgTypeStreamEnd:
+		err = h.endStream(sub, bytes)
+	case messages.MsgTypeStreamAbort:
+		err = h.abortStream(sub, bytes)
+	case messages.MsgTypeStreamAck:
+		err = h.ackStream(sub, bytes)
+	}
+	if err != nil {
+		h.logger.Error("stream failed", zap.Stringer("type", msgType), zap.Error(err))
+	}
+}
+
+func (h *Hub) openStream(sub *subscriber, bytes []byte) error {
+	var open messages.StreamOpen
+	if err := proto.Unmarshal(bytes, &open); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+	if sub.id == 0 {
+		return fmt.Errorf("stream request before identity")
+	}
+	if !sub.supports(messages.FeatureStreams) {
+		return h.send(sub, &messages.StreamAbort{Stream: open.Stream, Reason: "streams were not negotiated"}, messages.MsgTypeStreamAbort)
+	}
+	if !isClientStream(open.Stream) {
+		return h.send(sub, &messages.StreamAbort{Stream: open.Stream, Reason: "stream id must be odd"}, messages.MsgTypeStreamAbort)
+	}
+
+	sender := sub.id
+	ids, _ := limitRelay(h.limits(sub), open.Ids, 0)
+	s := &relayStream{
+		senderStream: open.Stream,
+		sender:       sub,
+		receivers:    make(map[*subscriber]int),
+	}
+	h.lock.RLock()
+	for _, id := range ids {
+		if id == sender || h.cluster.isRemote(id) || !h.canRelay(sender, id) {
+			continue
+		}
+		for _, receiver := range h.subscribers[id] {
+			if receiver.supports(messages.FeatureStreams) {
+				s.receivers[receiver] = 0
+			}
+		}
+	}
+	h.lock.RUnlock()
+	if len(s.receivers) == 0 {
+		return h.send(sub, &messages.StreamAbort{Stream: open.Stream, Reason: "no receivers"}, messages.MsgTypeStreamAbort)
+	}
+
+	h.streams.lock.Lock()
+	if _, ok := h.streams.bySender[streamKey{sub.session, open.Stream}]; ok {
+		h.streams.lock.Unlock()
+		return h.send(sub, &messages.StreamAbort{Stream: open.Stream, Reason: "stream is already open"}, messages.MsgTypeStreamAbort)
+	}
+	h.streams.add(s)
+	receivers := s.receiverList()
+	h.streams.lock.Unlock()
+	h.logger.Info("stream opened", zap.Uint64("stream", s.id), zap.Int32("from", sender), zap.Int("receivers", len(receivers)))
+
+	open.Stream = s.id
+	open.Id = sender
+	open.Ids = nil
+	for _, receiver := range receivers {
+		h.send(receiver, &open, messages.MsgTypeStreamOpen)
+	}
+	return h.send(sub, &messages.StreamAck{Stream: s.senderStream, Credits: messages.StreamWindow}, messages.MsgTypeStreamAck)
+}
+
+func (h *Hub) streamChunk(sub *subscriber, bytes []byte) error {
+	var chunk messages.StreamChunk
+	if err := proto.Unmarshal(bytes, &chunk); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+
+	h.streams.lock.Lock()
+	s, ok := h.streams.bySender[streamKey{sub.session, chunk.Stream}]
+	var reason string
+	switch {
+	case !ok:
+		h.streams.lock.Unlock()
+		return fmt.Errorf("unknown stream %d", chunk.Stream)
+	case len(chunk.Data) > messages.ChunkMaxLength:
+		reason = "chunk too large"
+	case s.sent >= s.acked+messages.StreamWindow:
+		reason = "stream window exceeded"
+	}
+	if reason != "" {
+		h.streams.remove(s)
+		h.streams.lock.Unlock()
+		h.abortReceivers(s, reason)
+		return h.send(sub, &messages.StreamAbort{Stream: chunk.Stream, Reason: reason}, messages.MsgTypeStreamAbort)
+	}
+	s.sent++
+	receivers := s.receiverList()
+	h.streams.lock.Unlock()
+
+	// one frame is shared by all receivers and written in order from this goroutine
+	chunk.Stream = s.id
+	frame, err := messages.EncodeFrame(&chunk, messages.MsgTypeStreamChunk)
+	if err != nil {
+		return fmt.Errorf("encode failed, %s", err.Error())
+	}
+	for _, receiver := range receivers {
+		h.metrics.FrameQueued()
+		frame.Retain()
+		h.writeFrame(receiver, frame)
+	}
+	frame.Release()
+	return nil
+}
+
+func (h *Hub) endStream(sub *subscriber, bytes []byte) error {
+	var end messages.StreamEnd
+	if err := proto.Unmarshal(bytes, &end); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+
+	h.streams.lock.Lock()
+	s, ok := h.streams.bySender[streamKey{sub.session, end.Stream}]
+	if ok {
+		h.streams.remove(s)
+	}
+	h.streams.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown stream %d", end.Stream)
+	}
+
+	h.logger.Info("stream ended", zap.Uint64("stream", s.id), zap.Int("chunks", s.sent))
+	for _, receiver := range s.receiverList() {
+		h.send(receiver, &messages.StreamEnd{Stream: s.id}, messages.MsgTypeStreamEnd)
+	}
+	return nil
+}
+
+// abortStream handles aborts of senders (odd ids) and receivers leaving a stream (even ids)
+func (h *Hub) abortStream(sub *subscriber, bytes []byte) error {
+	var abort messages.StreamAbort
+	if err := proto.Unmarshal(bytes, &abort); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+
+	if isClientStream(abort.Stream) {
+		h.streams.lock.Lock()
+		s, ok := h.streams.bySender[streamKey{sub.session, abort.Stream}]
+		if ok {
+			h.streams.remove(s)
+		}
+		h.streams.lock.Unlock()
+		if ok {
+			h.abortReceivers(s, abort.Reason)
+		}
+		return nil
+	}
+
+	h.streams.lock.Lock()
+	s, ok := h.streams.byID[abort.Stream]
+	h.streams.lock.Unlock()
+	if ok {
+		h.leaveStream(s, sub)
+	}
+	return nil
+}
+
+// ackStream credits chunks acked by a receiver to the sender
+func (h *Hub) ackStream(sub *subscriber, bytes []byte) error {
+	var ack messages.StreamAck
+	if err := proto.Unmarshal(bytes, &ack); err != nil {
+		h.metrics.DecodeError()
+		return fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+
+	h.streams.lock.Lock()
+	s, ok := h.streams.byID[ack.Stream]
+	if !ok {
+		h.streams.lock.Unlock()
+		return nil
+	}
+	acked, ok := s.receivers[sub]
+	if ok && ack.Credits > 0 {
+		// receivers can't ack chunks which were not sent
+		acked += int(ack.Credits)
+		if acked > s.sent {
+			acked = s.sent
+		}
+		s.receivers[sub] = acked
+	}
+	credit := s.credit()
+	h.streams.lock.Unlock()
+
+	if credit > 0 {
+		return h.send(s.sender, &messages.StreamAck{Stream: s.senderStream, Credits: int32(credit)}, messages.MsgTypeStreamAck)
+	}
+	return nil
+}
+
+// leaveStream removes a receiver, the last one leaving aborts the stream for the sender
+func (h *Hub) leaveStream(s *relayStream, receiver *subscriber) {
+	h.streams.lock.Lock()
+	if _, ok := h.streams.byID[s.id]; !ok {
+		h.streams.lock.Unlock()
+		return
+	}
+	delete(s.receivers, receiver)
+	empty := len(s.receivers) == 0
+	if empty {
+		h.streams.remove(s)
+	}
+	credit := s.credit()
+	h.streams.lock.Unlock()
+
+	switch {
+	case empty:
+		h.send(s.sender, &messages.StreamAbort{Stream: s.senderStream, Reason: "no receivers"}, messages.MsgTypeStreamAbort)
+	case credit > 0:
+		h.send(s.sender, &messages.StreamAck{Stream: s.senderStream, Credits: int32(credit)}, messages.MsgTypeStreamAck)
+	}
+}
+
+func (h *Hub) abortReceivers(s *relayStream, reason string) {
+	h.logger.Info("stream aborted", zap.Uint64("stream", s.id), zap.String("reason", reason))
+	for _, receiver := range s.receiverList() {
+		h.send(receiver, &messages.StreamAbort{Stream: s.id, Reason: reason}, messages.MsgTypeStreamAbort)
+	}
+}
+
+// dropStreams aborts streams sent by a closed session and removes it from streams it receives
+func (h *Hub) dropStreams(sub *subscriber) {
+	var sent, received []*relayStream
+	h.streams.lock.Lock()
+	for _, s := range h.streams.byID {
+		if s.sender == sub {
+			sent = append(sent, s)
+			h.streams.remove(s)
+			continue
+		}
+		if _, ok := s.receivers[sub]; ok {
+			received = append(received, s)
+		}
+	}
+	h.streams.lock.Unlock()
+
+	for _, s := range sent {
+		h.abortReceivers(s, "sender disconnected")
+	}
+	for _, s := range received {
+		h.leaveStream(s, sub)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestHub_stream(t *testing.T) {
+	// arrange
+	senderConn, senderClient := net.Pipe()
+	receiverConn, receiverClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	sender := newTestSubscriber(456, senderConn)
+	receiver := newTestSubscriber(123, receiverConn)
+	h.addSession(sender)
+	h.addSession(receiver)
+	senderFrames := readFrames(senderClient)
+	receiverFrames := readFrames(receiverClient)
+
+	// act
+	h.handleStream(sender, messages.MsgTypeStreamOpen, marshal(t, &messages.StreamOpen{Stream: 1, Ids: []int32{123}, Name: "cat.gif", Length: 3}))
+	h.handleStream(sender, messages.MsgTypeStreamChunk, marshal(t, &messages.StreamChunk{Stream: 1, Data: []byte("gif")}))
+	h.handleStream(receiver, messages.MsgTypeStreamAck, marshal(t, &messages.StreamAck{Stream: 2, Credits: 1}))
+	h.handleStream(sender, messages.MsgTypeStreamEnd, marshal(t, &messages.StreamEnd{Stream: 1}))
+
+	// assert
+	expectFrame(t, receiverFrames, messages.MsgTypeStreamOpen, &messages.StreamOpen{Stream: 2, Id: 456, Name: "cat.gif", Length: 3})
+	expectFrame(t, senderFrames, messages.MsgTypeStreamAck, &messages.StreamAck{Stream: 1, Credits: messages.StreamWindow})
+	expectFrame(t, receiverFrames, messages.MsgTypeStreamChunk, &messages.StreamChunk{Stream: 2, Data: []byte("gif")})
+	expectFrame(t, senderFrames, messages.MsgTypeStreamAck, &messages.StreamAck{Stream: 1, Credits: 1})
+	expectFrame(t, receiverFrames, messages.MsgTypeStreamEnd, &messages.StreamEnd{Stream: 2})
+	if len(h.streams.byID) != 0 {
+		t.Errorf("stream failed. Expected no open streams, got %d", len(h.streams.byID))
+	}
+}
+
+func TestHub_stream_windowExceeded(t *testing.T) {
+	// arrange
+	senderConn, senderClient := net.Pipe()
+	receiverConn, receiverClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	sender := newTestSubscriber(456, senderConn)
+	h.addSession(sender)
+	h.addSession(newTestSubscriber(123, receiverConn))
+	senderFrames := readFrames(senderClient)
+	receiverFrames := readFrames(receiverClient)
+	h.handleStream(sender, messages.MsgTypeStreamOpen, marshal(t, &messages.StreamOpen{Stream: 1, Ids: []int32{123}}))
+	<-receiverFrames
+	<-senderFrames
+
+	// act
+	go func() {
+		for i := 0; i <= messages.StreamWindow; i++ {
+			h.handleStream(sender, messages.MsgTypeStreamChunk, marshal(t, &messages.StreamChunk{Stream: 1, Data: []byte{byte(i)}}))
+		}
+	}()
+
+	// assert
+	for i := 0; i < messages.StreamWindow; i++ {
+		expectFrame(t, receiverFrames, messages.MsgTypeStreamChunk, &messages.StreamChunk{Stream: 2, Data: []byte{byte(i)}})
+	}
+	expectFrame(t, receiverFrames, messages.MsgTypeStreamAbort, &messages.StreamAbort{Stream: 2, Reason: "stream window exceeded"})
+	expectFrame(t, senderFrames, messages.MsgTypeStreamAbort, &messages.StreamAbort{Stream: 1, Reason: "stream window exceeded"})
+}
+
+func TestHub_dropStreams(t *testing.T) {
+	// arrange
+	senderConn, senderClient := net.Pipe()
+	receiverConn, receiverClient := net.Pipe()
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	sender := newTestSubscriber(456, senderConn)
+	receiver := newTestSubscriber(123, receiverConn)
+	h.addSession(sender)
+	h.addSession(receiver)
+	senderFrames := readFrames(senderClient)
+	receiverFrames := readFrames(receiverClient)
+	h.handleStream(sender, messages.MsgTypeStreamOpen, marshal(t, &messages.StreamOpen{Stream: 1, Ids: []int32{123}}))
+	<-receiverFrames
+	<-senderFrames
+
+	// act
+	h.dropStreams(receiver)
+
+	// assert
+	expectFrame(t, senderFrames, messages.MsgTypeStreamAbort, &messages.StreamAbort{Stream: 1, Reason: "no receivers"})
+	if len(h.streams.byID) != 0 {
+		t.Errorf("dropStreams failed. Expected no open streams, got %d", len(h.streams.byID))
+	}
+}
+
+type testFrame struct {
+	bytes   []byte
+	msgType messages.MsgType
+}
+
+// readFrames decodes frames written to conn until it is closed
+func readFrames(conn net.Conn) <-chan testFrame {
+	frames := make(chan testFrame, 64)
+	go func() {
+		for {
+			bytes, msgType, err := messages.Decode(conn)
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- testFrame{bytes: bytes, msgType: msgType}
+		}
+	}()
+	return frames
+}
+
+func marshal(t *testing.T, msg proto.Message) []byte {
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes
+}
+
+func expectFrame(t *testing.T, frames <-chan testFrame, msgType messages.MsgType, expected proto.Message) {
+	t.Helper()
+	frame := <-frames
+	if frame.msgType != msgType {
+		t.Fatalf("stream failed. Expected %s, got %s", msgType, frame.msgType)
+	}
+	result := reflect.New(reflect.TypeOf(expected).Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(frame.bytes, result); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("stream failed. Expected %#v, got %#v", expected, result)
+	}
+}
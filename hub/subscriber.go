@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// lastSession is the last issued session id
+var lastSession uint64
+
+// subscriber is a client connection (session) along with its statistics
+type subscriber struct {
+	// id of the user authenticated on this connection, 0 until identity request
+	id int32
+	// session identifies the connection among all sessions of the hub
+	session     uint64
+	conn        net.Conn
+	connectedAt time.Time
+	// listener accepted the connection, nil for WebSocket clients
+	listener *listener
+
+	// writeLock serializes writes of responses and relays through enc
+	writeLock sync.Mutex
+	enc       *messages.Encoder
+	// outbox queues relays by priority, responses are written as they come
+	outbox outbox
+	// codec compresses frames in both directions, nil until negotiated by identity request
+	codec messages.Codec
+	// version and features negotiated by hello, version is 0 for clients without hello
+	version  uint32
+	features []string
+	// json is set for clients speaking JSON lines, they can't read compressed frames
+	json bool
+
+	framesIn  uint64
+	framesOut uint64
+	bytesIn   uint64
+	bytesOut  uint64
+}
+
+// sessions are the connections of one user keyed by session id
+type sessions map[uint64]*subscriber
+
+// SubscriberInfo is a snapshot of a user session for operators
+type SubscriberInfo struct {
+	ID          int32     `json:"id"`
+	Session     uint64    `json:"session"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	FramesIn    uint64    `json:"frames_in"`
+	FramesOut   uint64    `json:"frames_out"`
+	BytesIn     uint64    `json:"bytes_in"`
+	BytesOut    uint64    `json:"bytes_out"`
+}
+
+func newSubscriber(conn net.Conn) *subscriber {
+	_, json := conn.(*messages.JSONConn)
+	return &subscriber{
+		session:     atomic.AddUint64(&lastSession, 1),
+		conn:        conn,
+		connectedAt: time.Now(),
+		enc:         messages.NewEncoder(conn),
+		json:        json,
+	}
+}
+
+// supports reports whether the feature was negotiated, clients without hello get all of them
+func (s *subscriber) supports(feature string) bool {
+	if s.json && feature == messages.FeatureCompression {
+		return false
+	}
+	return s.version == 0 || messages.HasFeature(s.features, feature)
+}
+
+func (s *subscriber) received(size int) {
+	atomic.AddUint64(&s.framesIn, 1)
+	atomic.AddUint64(&s.bytesIn, uint64(size))
+}
+
+func (s *subscriber) sent(size int) {
+	atomic.AddUint64(&s.framesOut, 1)
+	atomic.AddUint64(&s.bytesOut, uint64(size))
+}
+
+func (s *subscriber) info() SubscriberInfo {
+	var remoteAddr string
+	if addr := s.conn.RemoteAddr(); addr != nil {
+		remoteAddr = addr.String()
+	}
+
+	return SubscriberInfo{
+		ID:          s.id,
+		Session:     s.session,
+		RemoteAddr:  remoteAddr,
+		ConnectedAt: s.connectedAt,
+		FramesIn:    atomic.LoadUint64(&s.framesIn),
+		FramesOut:   atomic.LoadUint64(&s.framesOut),
+		BytesIn:     atomic.LoadUint64(&s.bytesIn),
+		BytesOut:    atomic.LoadUint64(&s.bytesOut),
+	}
+}
@@ -1,26 +1,458 @@
 package main
 
-import "sync"
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+)
+
+// User ids are prefixed with the id of the node which issued them, so they are unique
+// across a cluster and the owner of a user is known from the id alone:
+//
+//	bit 31 (sign) | bits 24-30 node | bits 0-23 per-node counter
+//
+// A standalone hub is node 0 and issues plain 1, 2, 3...
+const (
+	nodeShift   = 24
+	MaxNodeID   = 127
+	userCounter = 1<<nodeShift - 1
+)
+
+// compactMin is the number of records the users file may hold before it is compacted while open,
+// beyond that it is compacted once less than half of its records are live
+const compactMin = 1024
 
 type UserProvider interface {
-	AuthenticateNewUser() int32
+	AuthenticateNewUser() (int32, error)
+	// AuthenticateKey returns the id bound to a device key, an unknown key is bound to a new id
+	AuthenticateKey(key string) (int32, error)
+	// Exists reports whether id was issued by the provider
+	Exists(id int32) bool
+	// Bound reports whether id is bound to a device key, its sessions are only resumed with the key
+	Bound(id int32) bool
+	// IssueToken makes up the session token resuming id, an id without device key
+	IssueToken(id int32) (string, error)
+	// AuthenticateToken reports whether token was issued for id
+	AuthenticateToken(id int32, token string) bool
+	// Profile returns the profile of an issued id, nil for unknown ids. It must not be modified.
+	Profile(id int32) *messages.Profile
+	// SetProfile replaces the profile of profile.Id
+	SetProfile(profile *messages.Profile) error
+	// Profiles returns all profiles set, they must not be modified
+	Profiles() []*messages.Profile
+	// SetBlocked replaces the block list of id, see ACL.Block
+	SetBlocked(id int32, ids []int32) error
+	// BlockLists returns the block lists set by id, they must not be modified
+	BlockLists() map[int32][]int32
 }
 
+// Users issues ids in memory, users opened on a file keep ids, device keys, session tokens, profiles
+// and block lists across restarts, see OpenUsers
 type Users struct {
+	node            int32
 	availableUserID int32
-	lock            sync.RWMutex
+	// keys maps hashes of device keys to the ids bound to them
+	keys  map[string]int32
+	bound map[int32]bool
+	// tokens maps ids to the SHA-256 hashes of their session tokens
+	tokens   map[int32]string
+	profiles map[int32]*messages.Profile
+	blocked  map[int32][]int32
+	file     *os.File
+	path     string
+	// records is the number of records in file, anonymous users only move the counter of ids
+	// so most of them are dead
+	records int
+	lock    sync.RWMutex
+}
+
+// userRecord is a line of the users file, every line records one change
+type userRecord struct {
+	// Next is the counter of the next id issued
+	Next int32 `json:"next,omitempty"`
+	// ID is bound to the device key with the SHA-256 hash Key or resumed with the
+	// session token with the hash Token
+	ID      int32             `json:"id,omitempty"`
+	Key     string            `json:"key,omitempty"`
+	Token   string            `json:"token,omitempty"`
+	Profile *messages.Profile `json:"profile,omitempty"`
+	Blocks  *blockList        `json:"blocks,omitempty"`
+}
+
+// blockList replaces the block list of ID, an empty one clears it
+type blockList struct {
+	ID  int32   `json:"id"`
+	IDs []int32 `json:"ids"`
 }
 
 func NewUsers() *Users {
+	return NewNodeUsers(0)
+}
+
+// NewNodeUsers issues ids prefixed with node
+func NewNodeUsers(node int32) *Users {
 	return &Users{
+		node:            node,
 		availableUserID: 1,
+		keys:            make(map[string]int32),
+		bound:           make(map[int32]bool),
+		tokens:          make(map[int32]string),
+		profiles:        make(map[int32]*messages.Profile),
+		blocked:         make(map[int32][]int32),
 	}
 }
 
-func (u *Users) AuthenticateNewUser() int32 {
+// OpenUsers issues ids prefixed with node and appends every change to the file at path.
+// The file is replayed and compacted on open and whenever most of its records are dead,
+// a line torn by a crash is dropped.
+func OpenUsers(node int32, path string) (*Users, error) {
+	u := NewNodeUsers(node)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	lines := bytes.Split(data, []byte{'\n'})
+	// the last line is empty unless a write was torn
+	for i, line := range lines[:len(lines)-1] {
+		var rec userRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, i+1, err.Error())
+		}
+		if err := u.apply(rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, i+1, err.Error())
+		}
+	}
+
+	if err := u.compact(path); err != nil {
+		return nil, err
+	}
+	u.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	u.path = path
+	return u, nil
+}
+
+// apply replays a record of the users file
+func (u *Users) apply(rec userRecord) error {
+	var blocking int32
+	if rec.Blocks != nil {
+		blocking = rec.Blocks.ID
+	}
+	for _, id := range []int32{rec.ID, rec.Profile.GetId(), blocking} {
+		if id != 0 && nodeOf(id) != u.node {
+			return fmt.Errorf("user %d was issued by node %d, not %d", id, nodeOf(id), u.node)
+		}
+	}
+	if rec.Next > u.availableUserID {
+		u.availableUserID = rec.Next
+	}
+	if rec.Key != "" {
+		u.keys[rec.Key] = rec.ID
+		u.bound[rec.ID] = true
+		if next := rec.ID&userCounter + 1; next > u.availableUserID {
+			u.availableUserID = next
+		}
+	}
+	if rec.Token != "" {
+		u.tokens[rec.ID] = rec.Token
+	}
+	if rec.Profile != nil {
+		u.profiles[rec.Profile.Id] = rec.Profile
+	}
+	if rec.Blocks != nil {
+		u.setBlocked(rec.Blocks.ID, rec.Blocks.IDs)
+	}
+	return nil
+}
+
+// compact rewrites the users file with one record per key, token, profile and block list
+func (u *Users) compact(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(userRecord{Next: u.availableUserID})
+	for key, id := range u.keys {
+		enc.Encode(userRecord{ID: id, Key: key})
+	}
+	for id, token := range u.tokens {
+		enc.Encode(userRecord{ID: id, Token: token})
+	}
+	for _, profile := range u.profiles {
+		enc.Encode(userRecord{Profile: profile})
+	}
+	for id, ids := range u.blocked {
+		enc.Encode(userRecord{Blocks: &blockList{ID: id, IDs: ids}})
+	}
+	records := u.live()
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	u.records = records
+	return nil
+}
+
+// live returns the number of records compact writes
+func (u *Users) live() int {
+	return 1 + len(u.keys) + len(u.tokens) + len(u.profiles) + len(u.blocked)
+}
+
+// reopen compacts the open users file and appends to the compacted one
+func (u *Users) reopen() error {
+	if err := u.compact(u.path); err != nil {
+		return fmt.Errorf("users file: %s", err.Error())
+	}
+	f, err := os.OpenFile(u.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("users file: %s", err.Error())
+	}
+	u.file.Close()
+	u.file = f
+	return nil
+}
+
+// Close closes the users file, changes are synced as they are made
+func (u *Users) Close() error {
 	u.lock.Lock()
-	id := u.availableUserID
+	defer u.lock.Unlock()
+	if u.file == nil {
+		return nil
+	}
+	err := u.file.Close()
+	u.file = nil
+	return err
+}
+
+// write appends rec to the users file if there is one, the change is applied after it is synced
+func (u *Users) write(rec userRecord) error {
+	if u.file == nil {
+		return nil
+	}
+	// changes written before are applied, so compacting drops none of them
+	if u.records > compactMin && u.records > 2*u.live() {
+		if err := u.reopen(); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := u.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("users file: %s", err.Error())
+	}
+	u.records++
+	return u.file.Sync()
+}
+
+// nextID returns the id issued next
+func (u *Users) nextID() (int32, error) {
+	if u.availableUserID > userCounter {
+		return 0, fmt.Errorf("user ids of node %d exhausted", u.node)
+	}
+	return u.node<<nodeShift | u.availableUserID, nil
+}
+
+func (u *Users) AuthenticateNewUser() (int32, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	id, err := u.nextID()
+	if err != nil {
+		return 0, err
+	}
+	if err := u.write(userRecord{Next: u.availableUserID + 1}); err != nil {
+		return 0, err
+	}
+	u.availableUserID++
+	return id, nil
+}
+
+func (u *Users) AuthenticateKey(key string) (int32, error) {
+	hash := hashKey(key)
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if id, ok := u.keys[hash]; ok {
+		return id, nil
+	}
+	id, err := u.nextID()
+	if err != nil {
+		return 0, err
+	}
+	if err := u.write(userRecord{ID: id, Key: hash}); err != nil {
+		return 0, err
+	}
 	u.availableUserID++
-	u.lock.Unlock()
-	return id
+	u.keys[hash] = id
+	u.bound[id] = true
+	return id, nil
+}
+
+func (u *Users) Exists(id int32) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return u.exists(id)
+}
+
+func (u *Users) exists(id int32) bool {
+	counter := id & userCounter
+	return nodeOf(id) == u.node && counter > 0 && counter < u.availableUserID
+}
+
+func (u *Users) Bound(id int32) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return u.bound[id]
+}
+
+func (u *Users) IssueToken(id int32) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if !u.exists(id) || u.bound[id] {
+		return "", fmt.Errorf("user %d can't get a session token", id)
+	}
+	if err := u.write(userRecord{ID: id, Token: hashKey(token)}); err != nil {
+		return "", err
+	}
+	u.tokens[id] = hashKey(token)
+	return token, nil
+}
+
+func (u *Users) AuthenticateToken(id int32, token string) bool {
+	u.lock.RLock()
+	hash, ok := u.tokens[id]
+	u.lock.RUnlock()
+	return ok && subtle.ConstantTimeCompare([]byte(hash), []byte(hashKey(token))) == 1
+}
+
+func (u *Users) Profile(id int32) *messages.Profile {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	if profile, ok := u.profiles[id]; ok {
+		return profile
+	}
+	if u.exists(id) {
+		return &messages.Profile{Id: id}
+	}
+	return nil
+}
+
+func (u *Users) SetProfile(profile *messages.Profile) error {
+	if err := validateProfile(profile); err != nil {
+		return err
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if !u.exists(profile.Id) {
+		return fmt.Errorf("unknown user %d", profile.Id)
+	}
+	if err := u.write(userRecord{Profile: profile}); err != nil {
+		return err
+	}
+	u.profiles[profile.Id] = profile
+	return nil
+}
+
+func (u *Users) Profiles() []*messages.Profile {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	profiles := make([]*messages.Profile, 0, len(u.profiles))
+	for _, profile := range u.profiles {
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+func (u *Users) SetBlocked(id int32, ids []int32) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if !u.exists(id) {
+		return fmt.Errorf("unknown user %d", id)
+	}
+	if err := u.write(userRecord{Blocks: &blockList{ID: id, IDs: ids}}); err != nil {
+		return err
+	}
+	u.setBlocked(id, ids)
+	return nil
+}
+
+func (u *Users) setBlocked(id int32, ids []int32) {
+	if len(ids) == 0 {
+		delete(u.blocked, id)
+		return
+	}
+	u.blocked[id] = append([]int32(nil), ids...)
+}
+
+func (u *Users) BlockLists() map[int32][]int32 {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	lists := make(map[int32][]int32, len(u.blocked))
+	for id, ids := range u.blocked {
+		lists[id] = ids
+	}
+	return lists
+}
+
+// validateProfile checks profile against the limits of messages
+func validateProfile(profile *messages.Profile) error {
+	if len(profile.Name) > messages.ProfileNameMaxLength {
+		return fmt.Errorf("profile name longer than %d", messages.ProfileNameMaxLength)
+	}
+	if len(profile.PublicKey) != 0 && len(profile.PublicKey) != messages.PublicKeyLength {
+		return fmt.Errorf("public key must be %d bytes", messages.PublicKeyLength)
+	}
+	if len(profile.Metadata) > messages.ProfileMaxAttributes {
+		return fmt.Errorf("profile has more than %d attributes", messages.ProfileMaxAttributes)
+	}
+	for _, attr := range profile.Metadata {
+		if attr == nil || attr.Key == "" {
+			return fmt.Errorf("profile attribute without key")
+		}
+		if len(attr.Key) > messages.AttributeMaxLength || len(attr.Value) > messages.AttributeMaxLength {
+			return fmt.Errorf("profile attribute %q longer than %d", attr.Key, messages.AttributeMaxLength)
+		}
+	}
+	return nil
+}
+
+// hashKey keeps device keys out of the users file
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// nodeOf returns the node which issued id
+func nodeOf(id int32) int32 {
+	return id >> nodeShift
 }
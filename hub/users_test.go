@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestOpenUsers(t *testing.T) {
+	// arrange
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.jsonl")
+	users, err := OpenUsers(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, _ := users.AuthenticateNewUser()
+	token, err := users.IssueToken(anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, _ := users.AuthenticateKey("phone-key")
+	if _, err := users.IssueToken(phone); err == nil {
+		t.Error("IssueToken failed. Expected error for an id bound to a device key")
+	}
+	profile := &messages.Profile{Id: phone, Name: "Anton", Metadata: []*messages.Attribute{{Key: "city", Value: "Berlin"}}}
+	if err := users.SetProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetBlocked(phone, []int32{anonymous}); err != nil {
+		t.Fatal(err)
+	}
+	users.Close()
+
+	// a crash may tear the last line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"next":`))
+	f.Close()
+
+	// act
+	users, err = OpenUsers(2, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer users.Close()
+	again, _ := users.AuthenticateKey("phone-key")
+	next, _ := users.AuthenticateNewUser()
+
+	// assert
+	if again != phone {
+		t.Errorf("AuthenticateKey failed. Expected %d, got %d", phone, again)
+	}
+	if next != 2<<nodeShift|3 {
+		t.Errorf("AuthenticateNewUser failed. Expected %d, got %d", 2<<nodeShift|3, next)
+	}
+	if !users.Exists(anonymous) || !users.Bound(phone) || users.Bound(anonymous) {
+		t.Error("Exists failed. Unexpected result")
+	}
+	if !users.AuthenticateToken(anonymous, token) || users.AuthenticateToken(anonymous, "guess") || users.AuthenticateToken(phone, token) {
+		t.Error("AuthenticateToken failed. Expected the session token kept for its id only")
+	}
+	if got := users.Profile(phone); !reflect.DeepEqual(got, profile) {
+		t.Errorf("Profile failed. Expected %#v, got %#v", profile, got)
+	}
+	if lists := users.BlockLists(); len(lists) != 1 || !reflect.DeepEqual(lists[phone], []int32{anonymous}) {
+		t.Errorf("BlockLists failed. Expected %d blocked by %d, got %#v", anonymous, phone, lists)
+	}
+	if _, err := OpenUsers(3, path); err == nil {
+		t.Error("OpenUsers failed. Expected error for ids of another node")
+	}
+}
+
+func TestUsers_AuthenticateNewUser_compact(t *testing.T) {
+	// arrange
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.jsonl")
+	users, err := OpenUsers(0, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, _ := users.AuthenticateKey("phone-key")
+
+	// act, every anonymous user moves the counter of ids
+	var last int32
+	for i := 0; i < 3*compactMin; i++ {
+		if last, err = users.AuthenticateNewUser(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users.Close()
+
+	// assert
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte{'\n'}); lines > compactMin+1 {
+		t.Errorf("AuthenticateNewUser failed. Expected at most %d records, got %d", compactMin+1, lines)
+	}
+	users, err = OpenUsers(0, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer users.Close()
+	if again, _ := users.AuthenticateKey("phone-key"); again != phone {
+		t.Errorf("AuthenticateKey failed. Expected %d, got %d", phone, again)
+	}
+	if next, _ := users.AuthenticateNewUser(); next != last+1 {
+		t.Errorf("AuthenticateNewUser failed. Expected %d, got %d", last+1, next)
+	}
+}
+
+func TestUsers_SetProfile_invalid(t *testing.T) {
+	users := NewUsers()
+	id, _ := users.AuthenticateNewUser()
+	long := string(make([]byte, messages.AttributeMaxLength+1))
+	tests := []struct {
+		name    string
+		profile *messages.Profile
+	}{
+		{"unknown user", &messages.Profile{Id: id + 1}},
+		{"long name", &messages.Profile{Id: id, Name: long}},
+		{"attribute without key", &messages.Profile{Id: id, Metadata: []*messages.Attribute{{Value: "v"}}}},
+		{"long attribute", &messages.Profile{Id: id, Metadata: []*messages.Attribute{{Key: "k", Value: long}}}},
+		{"short public key", &messages.Profile{Id: id, PublicKey: make([]byte, messages.PublicKeyLength-1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := users.SetProfile(tt.profile); err == nil {
+				t.Errorf("SetProfile() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestHub_profileRequest(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	users := NewUsers()
+	// a bound id is not resumed without its key
+	bound, _ := users.AuthenticateKey("laptop-key")
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: users,
+	}
+	go h.handleConnection(server)
+	frames := readFrames(client)
+
+	// act
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_IDENTITY, Id: bound}, messages.MsgTypeRequest))
+	if id := expectIdentity(t, frames); id != bound+1 {
+		t.Fatalf("identityRequest failed. Expected %d, got %d", bound+1, id)
+	}
+	go client.Write(encode(t, &messages.Request{
+		Type:    messages.Request_PROFILE,
+		Profile: &messages.Profile{Id: bound, Name: "Anton"},
+	}, messages.MsgTypeRequest))
+
+	// assert
+	profile := &messages.Profile{Id: bound + 1, Name: "Anton"}
+	expectFrame(t, frames, messages.MsgTypeProfileResponse, &messages.ProfileResponse{Profiles: []*messages.Profile{profile}})
+
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_LOOKUP, Ids: []int32{bound, bound + 1, bound + 2}}, messages.MsgTypeRequest))
+	expectFrame(t, frames, messages.MsgTypeProfileResponse, &messages.ProfileResponse{
+		Profiles: []*messages.Profile{{Id: bound}, profile},
+	})
+}
+
+func TestHub_publishKeyRequest(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	users := NewUsers()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: users,
+	}
+	go h.handleConnection(server)
+	frames := readFrames(client)
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_IDENTITY}, messages.MsgTypeRequest))
+	var identity messages.IdentityResponse
+	if err := identity.Unmarshal((<-frames).bytes); err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, messages.PublicKeyLength)
+	key[0] = 1
+
+	// act
+	go client.Write(encode(t, &messages.Request{Type: messages.Request_PUBLISH_KEY, PublicKey: key}, messages.MsgTypeRequest))
+
+	// assert
+	expectFrame(t, frames, messages.MsgTypeProfileResponse, &messages.ProfileResponse{
+		Profiles: []*messages.Profile{{Id: identity.Id, PublicKey: key}},
+	})
+	// changing the profile keeps the key
+	go client.Write(encode(t, &messages.Request{
+		Type:    messages.Request_PROFILE,
+		Profile: &messages.Profile{Name: "Anton"},
+	}, messages.MsgTypeRequest))
+	expectFrame(t, frames, messages.MsgTypeProfileResponse, &messages.ProfileResponse{
+		Profiles: []*messages.Profile{{Id: identity.Id, Name: "Anton", PublicKey: key}},
+	})
+}
+
+func encode(t *testing.T, msg proto.Marshaler, msgType messages.MsgType) []byte {
+	t.Helper()
+	b, err := messages.Encode(msg, msgType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHub_directoryRequest(t *testing.T) {
+	// arrange
+	users := NewUsers()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: users,
+	}
+	profiles := []*messages.Profile{
+		{Name: "Anna", Metadata: []*messages.Attribute{{Key: "city", Value: "Berlin"}}},
+		{Name: "anton", Metadata: []*messages.Attribute{{Key: "city", Value: "Paris"}}},
+		{Name: "Bob"},
+	}
+	for _, profile := range profiles {
+		profile.Id, _ = users.AuthenticateNewUser()
+		users.SetProfile(profile)
+	}
+	anna, anton, bob := profiles[0], profiles[1], profiles[2]
+	for _, id := range []int32{anna.Id, anna.Id, bob.Id} {
+		conn, _ := net.Pipe()
+		h.addSession(newTestSubscriber(id, conn))
+	}
+	me, _ := users.AuthenticateNewUser()
+
+	tests := []struct {
+		name  string
+		query *messages.DirectoryQuery
+		want  *messages.DirectoryResponse
+	}{
+		{
+			"all",
+			nil,
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{
+				{Profile: anna, Online: true, Devices: 2}, {Profile: anton}, {Profile: bob, Online: true, Devices: 1},
+			}},
+		},
+		{
+			"name prefix first page",
+			&messages.DirectoryQuery{NamePrefix: "AN", Limit: 1},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{{Profile: anna, Online: true, Devices: 2}}, Next: anna.Id},
+		},
+		{
+			"name prefix last page",
+			&messages.DirectoryQuery{NamePrefix: "AN", Limit: 1, After: anna.Id},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{{Profile: anton}}},
+		},
+		{
+			"attribute",
+			&messages.DirectoryQuery{Attributes: []*messages.Attribute{{Key: "city", Value: "Paris"}}},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{{Profile: anton}}},
+		},
+		{
+			"any value of attribute",
+			&messages.DirectoryQuery{Attributes: []*messages.Attribute{{Key: "city"}}},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{{Profile: anna, Online: true, Devices: 2}, {Profile: anton}}},
+		},
+		{
+			"online",
+			&messages.DirectoryQuery{Presence: messages.DirectoryQuery_ONLINE},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{
+				{Profile: anna, Online: true, Devices: 2}, {Profile: bob, Online: true, Devices: 1},
+			}},
+		},
+		{
+			"offline",
+			&messages.DirectoryQuery{Presence: messages.DirectoryQuery_OFFLINE},
+			&messages.DirectoryResponse{Users: []*messages.UserRecord{{Profile: anton}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			frames := readFrames(client)
+
+			// act
+			go h.directoryRequest(me, tt.query, newTestSubscriber(me, server))
+
+			// assert
+			expectFrame(t, frames, messages.MsgTypeDirectoryResponse, tt.want)
+		})
+	}
+}
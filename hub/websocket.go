@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebSocket clients carry the same frames as TCP clients in binary messages. The frames
+// read from a connection form one byte stream however they are split into messages,
+// every message written by the hub holds one or more whole frames. See RFC 6455.
+
+const (
+	wsGUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsVersion = "13"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+
+	wsFin          = 0x80
+	wsMasked       = 0x80
+	wsMaxHeaderLen = 10
+	// wsMaxControlLen is the longest payload of ping, pong and close frames
+	wsMaxControlLen = 125
+
+	wsStatusNormal          = 1000
+	wsStatusProtocolError   = 1002
+	wsStatusUnsupportedData = 1003
+
+	// wsCloseTimeout bounds writing the close frame to a peer which doesn't read
+	wsCloseTimeout = time.Second
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// WebSocketHandler upgrades HTTP requests to WebSocket connections served by the hub
+type WebSocketHandler struct {
+	hub *Hub
+}
+
+func NewWebSocketHandler(hub *Hub) *WebSocketHandler {
+	return &WebSocketHandler{
+		hub: hub,
+	}
+}
+
+func (ws *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket handshake expected", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != wsVersion {
+		w.Header().Set("Sec-WebSocket-Version", wsVersion)
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		http.Error(w, "bad websocket key", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		ws.hub.logger.Error("websocket hijack failed", zap.Error(err))
+		return
+	}
+	// the server may have set deadlines for reading the request
+	conn.SetDeadline(time.Time{})
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	c := newWebSocketConn(conn, rw.Reader)
+	if ws.hub.admit(c, nil) {
+		defer ws.hub.release(nil)
+		ws.hub.handleConnection(c)
+	}
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a Sec-WebSocket-Key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma separated header has token, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketConn is a net.Conn reading and writing the payload of binary messages, so the
+// hub serves it like any other connection
+type webSocketConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	// reader state, Read is called from the connection goroutine only
+	remaining  int64
+	mask       [4]byte
+	maskPos    int
+	fragmented bool
+
+	writeLock sync.Mutex
+	header    [wsMaxHeaderLen]byte
+	bufs      net.Buffers
+	out       net.Buffers
+	closeSent bool
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(conn net.Conn, r *bufio.Reader) *webSocketConn {
+	return &webSocketConn{
+		Conn: conn,
+		r:    r,
+	}
+}
+
+// Read reads the payload of binary messages, control frames are handled on the way
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.unmask(p[:n])
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame reads a frame header, data frames are left for Read to consume
+func (c *webSocketConn) nextFrame() error {
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:2]); err != nil {
+		return err
+	}
+	fin := header[0]&wsFin != 0
+	opcode := header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return c.fail(wsStatusProtocolError, "no extensions were negotiated")
+	}
+	if header[1]&wsMasked == 0 {
+		return c.fail(wsStatusProtocolError, "client frames must be masked")
+	}
+
+	length := int64(header[1] &^ wsMasked)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(c.r, header[:2]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.r, header[:8]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(header[:8]))
+		if length < 0 {
+			return c.fail(wsStatusProtocolError, "bad frame length")
+		}
+	}
+	if _, err := io.ReadFull(c.r, c.mask[:]); err != nil {
+		return err
+	}
+	c.maskPos = 0
+
+	switch opcode {
+	case wsOpBinary, wsOpContinuation:
+		if (opcode == wsOpContinuation) != c.fragmented {
+			return c.fail(wsStatusProtocolError, "unexpected continuation")
+		}
+		c.fragmented = !fin
+		c.remaining = length
+		return nil
+	case wsOpText:
+		return c.fail(wsStatusUnsupportedData, "text messages are not supported")
+	case wsOpPing, wsOpPong, wsOpClose:
+	default:
+		return c.fail(wsStatusProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+	}
+
+	if !fin || length > wsMaxControlLen {
+		return c.fail(wsStatusProtocolError, "bad control frame")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+	c.unmask(payload)
+
+	switch opcode {
+	case wsOpPing:
+		return c.writeFrame(wsOpPong, payload)
+	case wsOpClose:
+		// echo the status code of the peer
+		if len(payload) > 2 {
+			payload = payload[:2]
+		}
+		c.writeFrame(wsOpClose, payload)
+		return io.EOF
+	}
+	return nil
+}
+
+func (c *webSocketConn) unmask(p []byte) {
+	for i := range p {
+		p[i] ^= c.mask[c.maskPos&3]
+		c.maskPos++
+	}
+}
+
+// fail sends a close frame with status and returns reason as an error
+func (c *webSocketConn) fail(status uint16, reason string) error {
+	c.writeClose(status, reason)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// Write sends p as one binary message
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	n, err := c.WriteBuffers(net.Buffers{p})
+	return int(n), err
+}
+
+// WriteBuffers sends bufs as one binary message, see messages.BuffersWriter
+func (c *webSocketConn) WriteBuffers(bufs net.Buffers) (int64, error) {
+	var length int64
+	for _, b := range bufs {
+		length += int64(len(b))
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return 0, errWebSocketClosed
+	}
+	header := c.putHeader(wsOpBinary, length)
+	n, err := c.write(header, bufs...)
+	n -= int64(len(header))
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+// writeFrame sends a single unfragmented frame, a close frame is sent once
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+	if opcode == wsOpClose {
+		c.closeSent = true
+	}
+	_, err := c.write(c.putHeader(opcode, int64(len(payload))), payload)
+	return err
+}
+
+// write sends a frame header and payload with a single writev, c.writeLock must be held
+func (c *webSocketConn) write(header []byte, payload ...[]byte) (int64, error) {
+	c.bufs = append(c.bufs[:0], header)
+	c.bufs = append(c.bufs, payload...)
+	// WriteTo consumes the buffers it is called on, see messages.Encoder.Flush
+	c.out = c.bufs
+	n, err := c.out.WriteTo(c.Conn)
+	c.out = nil
+	for i := range c.bufs {
+		c.bufs[i] = nil
+	}
+	return n, err
+}
+
+func (c *webSocketConn) writeClose(status uint16, reason string) error {
+	if len(reason) > wsMaxControlLen-2 {
+		reason = reason[:wsMaxControlLen-2]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, status)
+	return c.writeFrame(wsOpClose, append(payload, reason...))
+}
+
+// putHeader encodes an unmasked frame header, c.writeLock must be held
+func (c *webSocketConn) putHeader(opcode byte, length int64) []byte {
+	h := c.header[:]
+	h[0] = wsFin | opcode
+	switch {
+	case length <= wsMaxControlLen:
+		h[1] = byte(length)
+		return h[:2]
+	case length <= 0xffff:
+		h[1] = 126
+		binary.BigEndian.PutUint16(h[2:], uint16(length))
+		return h[:4]
+	default:
+		h[1] = 127
+		binary.BigEndian.PutUint64(h[2:], uint64(length))
+		return h[:10]
+	}
+}
+
+// Close sends a normal close frame unless one was sent, then closes the connection
+func (c *webSocketConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.Conn.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+		c.writeClose(wsStatusNormal, "")
+		err = c.Conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/antonzhukov/go-tcp-messaging/messages"
+
+	"github.com/gogo/protobuf/proto"
+	"go.uber.org/zap"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	// arrange
+	tcpConn, tcpClient := net.Pipe()
+	h := &Hub{
+		subscribers:   make(map[int32]sessions),
+		logger:        zap.L(),
+		usersProvider: NewUsers(),
+	}
+	h.addSession(newTestSubscriber(123, tcpConn))
+	tcpFrames := readFrames(tcpClient)
+	server := httptest.NewServer(NewWebSocketHandler(h))
+	defer server.Close()
+	conn, r := dialWebSocket(t, server.URL)
+	defer conn.Close()
+
+	// act
+	identity, err := messages.Encode(&messages.Request{Type: messages.Request_IDENTITY}, messages.MsgTypeRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// frames may be split across messages and fragments
+	writeWebSocketFrame(t, conn, wsOpBinary, true, identity[:3])
+	writeWebSocketFrame(t, conn, wsOpBinary, false, identity[3:4])
+	writeWebSocketFrame(t, conn, wsOpContinuation, true, identity[4:])
+
+	// assert
+	opcode, payload := readWebSocketFrame(t, r)
+	if opcode != wsOpBinary {
+		t.Fatalf("websocket failed. Expected binary message, got opcode %d", opcode)
+	}
+	bytes, msgType, err := messages.Decode(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var identityResp messages.IdentityResponse
+	if err := proto.Unmarshal(bytes, &identityResp); err != nil {
+		t.Fatal(err)
+	}
+	if msgType != messages.MsgTypeIdentityResponse || identityResp.Id != 1 {
+		t.Errorf("websocket failed. Expected identity 1, got %s %#v", msgType, identityResp)
+	}
+
+	// WebSocket and TCP users share the hub
+	relay, err := messages.Encode(&messages.RelayRequest{Ids: []int32{123}, Body: []byte("g'day")}, messages.MsgTypeRelayRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeWebSocketFrame(t, conn, wsOpBinary, true, relay)
+	expectFrame(t, tcpFrames, messages.MsgTypeRelay, &messages.Relay{Body: []byte("g'day"), From: 1, Sequence: 1})
+
+	writeWebSocketFrame(t, conn, wsOpPing, true, []byte("ping"))
+	opcode, payload = readWebSocketFrame(t, r)
+	if opcode != wsOpPong || string(payload) != "ping" {
+		t.Errorf("websocket failed. Expected pong, got opcode %d %q", opcode, payload)
+	}
+
+	status := make([]byte, 2)
+	binary.BigEndian.PutUint16(status, wsStatusNormal)
+	writeWebSocketFrame(t, conn, wsOpClose, true, status)
+	opcode, payload = readWebSocketFrame(t, r)
+	if opcode != wsOpClose || !reflect.DeepEqual(payload, status) {
+		t.Errorf("websocket failed. Expected close, got opcode %d %v", opcode, payload)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("websocket failed. Expected connection closed, got %v", err)
+	}
+}
+
+func TestWebSocketHandler_textMessage(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	server := httptest.NewServer(NewWebSocketHandler(h))
+	defer server.Close()
+	conn, r := dialWebSocket(t, server.URL)
+	defer conn.Close()
+
+	// act
+	writeWebSocketFrame(t, conn, wsOpText, true, []byte("g'day"))
+
+	// assert
+	opcode, payload := readWebSocketFrame(t, r)
+	if opcode != wsOpClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != wsStatusUnsupportedData {
+		t.Errorf("websocket failed. Expected close %d, got opcode %d %v", wsStatusUnsupportedData, opcode, payload)
+	}
+}
+
+func TestWebSocketHandler_notUpgrade(t *testing.T) {
+	// arrange
+	h := &Hub{
+		subscribers: make(map[int32]sessions),
+		logger:      zap.L(),
+	}
+	server := httptest.NewServer(NewWebSocketHandler(h))
+	defer server.Close()
+
+	// act
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// assert
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("websocket failed. Expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// example from RFC 6455
+	result := acceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if result != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey failed. Expected %q, got %q", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", result)
+	}
+}
+
+// dialWebSocket performs the client side of the handshake
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, url+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", wsVersion)
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake failed. Expected %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed. Unexpected accept key %q", accept)
+	}
+	return conn, r
+}
+
+// writeWebSocketFrame writes a masked client frame
+func writeWebSocketFrame(t *testing.T, conn net.Conn, opcode byte, fin bool, payload []byte) {
+	t.Helper()
+	header := []byte{opcode, wsMasked}
+	if fin {
+		header[0] |= wsFin
+	}
+	switch {
+	case len(payload) <= wsMaxControlLen:
+		header[1] |= byte(len(payload))
+	case len(payload) <= 0xffff:
+		header[1] |= 126
+		header = append(header, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		t.Fatal("payload too long")
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	if _, err := conn.Write(append(append(header, mask...), masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readWebSocketFrame reads an unmasked server frame
+func readWebSocketFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0]&wsFin == 0 || header[1]&wsMasked != 0 {
+		t.Fatalf("Unexpected frame header %v", header)
+	}
+	opcode, length := header[0]&0x0f, int(header[1])
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(r, header); err != nil {
+			t.Fatal(err)
+		}
+		length = int(binary.BigEndian.Uint16(header))
+	case 127:
+		t.Fatal("Unexpected frame length")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return opcode, payload
+}
@@ -0,0 +1,240 @@
+package messages
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FlagCompressed is set in the type byte of frames with a compressed payload. Relay and
+// RelayRequest frames compress the body only, so hubs route them without decompressing
+// and can pass the body on to receivers which negotiated the same codec.
+const FlagCompressed MsgType = 0x80
+
+// ErrTooLarge is returned by Codec.Decompress along with the first limit bytes
+// when the decompressed data is longer
+var ErrTooLarge = errors.New("decompressed data too large")
+
+// ErrDecompress wraps failures to decompress a frame, the stream is still in sync after them
+var ErrDecompress = errors.New("decompression failed")
+
+// Codec compresses frame payloads. Codecs are negotiated by name during the identity
+// request, so a codec must produce the same format wherever it is registered.
+type Codec interface {
+	Name() string
+	// Compress appends compressed src to dst
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress appends decompressed src to dst. At most limit bytes are appended,
+	// ErrTooLarge is returned if there is more.
+	Decompress(dst, src []byte, limit int) ([]byte, error)
+}
+
+var (
+	codecsLock sync.RWMutex
+	codecs     []Codec
+)
+
+func init() {
+	RegisterCodec(newFlateCodec("deflate", false))
+	RegisterCodec(newFlateCodec("gzip", true))
+}
+
+// RegisterCodec adds a codec to the negotiable ones, later codecs are preferred less.
+// Snappy or zstd can be offered this way by wrapping their block encoders.
+func RegisterCodec(c Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	for i, registered := range codecs {
+		if registered.Name() == c.Name() {
+			codecs[i] = c
+			return
+		}
+	}
+	codecs = append(codecs, c)
+}
+
+// LookupCodec returns a registered codec by name or nil
+func LookupCodec(name string) Codec {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// CodecNames returns names of registered codecs in order of preference
+func CodecNames() []string {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	names := make([]string, len(codecs))
+	for i, c := range codecs {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Compressed reports whether the frame payload (or relay body) is compressed
+func (f *Frame) Compressed() bool {
+	return MsgType(f.data[0])&FlagCompressed != 0
+}
+
+// Decompress returns the plain payload of a compressed frame. The payload of relay
+// frames is rebuilt around the decompressed body. The result doesn't alias the frame.
+func Decompress(f *Frame, codec Codec, limit int) ([]byte, error) {
+	if codec == nil {
+		return nil, fmt.Errorf("%w: no codec negotiated", ErrDecompress)
+	}
+
+	switch f.Type() {
+	case MsgTypeRelay:
+		var relay Relay
+		if err := relay.Unmarshal(f.Payload()); err != nil {
+			return nil, err
+		}
+		return decompressBody(codec, &relay, relay.Body, limit)
+	case MsgTypeRelayRequest:
+		req, err := ParseRelayRequest(f.Payload())
+		if err != nil {
+			return nil, err
+		}
+		return decompressBody(codec, &RelayRequest{Id: req.Id, Ids: req.Ids, Ttl: req.Ttl, Receipt: req.Receipt, Priority: req.Priority,
+			IdempotencyKey: req.IdempotencyKey, Call: req.Call, Reply: req.Reply, Error: req.Error,
+			Group: req.Group, Sealed: req.Sealed}, req.Body, limit)
+	}
+
+	payload, err := codec.Decompress(nil, f.Payload(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDecompress, err.Error())
+	}
+	return payload, nil
+}
+
+// relayMessage is a Relay or RelayRequest, they share the body field
+type relayMessage interface {
+	Message
+	GetBody() []byte
+	setBody(body []byte)
+}
+
+func (m *Relay) setBody(body []byte)        { m.Body = body }
+func (m *RelayRequest) setBody(body []byte) { m.Body = body }
+
+func decompressBody(codec Codec, msg relayMessage, body []byte, limit int) ([]byte, error) {
+	plain, err := codec.Decompress(nil, body, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDecompress, err.Error())
+	}
+	msg.setBody(plain)
+	payload := make([]byte, msg.Size())
+	if _, err := msg.MarshalTo(payload); err != nil {
+		return nil, fmt.Errorf("marshalling failed: %s", err.Error())
+	}
+	return payload, nil
+}
+
+// compressFrame compresses the payload of f with codec, f is kept if compression doesn't pay off
+func compressFrame(codec Codec, f *Frame) (*Frame, error) {
+	payload := f.Payload()
+	z := newFrame(HeaderLen)
+	data, err := codec.Compress(z.data, payload)
+	if err != nil {
+		z.Release()
+		return nil, fmt.Errorf("compression failed: %s", err.Error())
+	}
+	if len(data) >= len(f.data) {
+		z.Release()
+		return f, nil
+	}
+	z.buf = data
+	z.data = data
+	putHeader(z.data, f.Type()|FlagCompressed, len(data)-HeaderLen)
+	f.Release()
+	return z, nil
+}
+
+// flateCodec implements deflate and gzip from the standard library, writers are
+// pooled as every one of them holds several hundred kilobytes of state
+type flateCodec struct {
+	name    string
+	gzip    bool
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func newFlateCodec(name string, gzip bool) *flateCodec {
+	return &flateCodec{
+		name: name,
+		gzip: gzip,
+	}
+}
+
+func (c *flateCodec) Name() string {
+	return c.name
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func (c *flateCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, ok := c.writers.Get().(resetWriter)
+	switch {
+	case ok:
+		w.Reset(buf)
+	case c.gzip:
+		w = gzip.NewWriter(buf)
+	default:
+		w, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *flateCodec) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	r, err := c.reader(bytes.NewReader(src))
+	if err != nil {
+		return dst, err
+	}
+	defer c.readers.Put(r)
+
+	buf := bytes.NewBuffer(dst)
+	n, err := buf.ReadFrom(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return dst, err
+	}
+	out := buf.Bytes()
+	if n > int64(limit) {
+		return out[:len(dst)+limit], ErrTooLarge
+	}
+	return out, nil
+}
+
+func (c *flateCodec) reader(src io.Reader) (io.ReadCloser, error) {
+	r := c.readers.Get()
+	if c.gzip {
+		if gr, ok := r.(*gzip.Reader); ok {
+			return gr, gr.Reset(src)
+		}
+		return gzip.NewReader(src)
+	}
+	if fr, ok := r.(io.ReadCloser); ok {
+		return fr, fr.(flate.Resetter).Reset(src, nil)
+	}
+	return flate.NewReader(src), nil
+}
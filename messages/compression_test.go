@@ -0,0 +1,128 @@
+package messages
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCodec_roundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"text":"g'day"}`), 100)
+	for _, name := range CodecNames() {
+		t.Run(name, func(t *testing.T) {
+			codec := LookupCodec(name)
+			compressed, err := codec.Compress(nil, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(compressed) >= len(data) {
+				t.Errorf("Compress() = %d bytes, want less than %d", len(compressed), len(data))
+			}
+
+			plain, err := codec.Decompress([]byte("prefix"), compressed, len(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plain, append([]byte("prefix"), data...)) {
+				t.Errorf("Decompress() = %q, want %q", plain, data)
+			}
+
+			plain, err = codec.Decompress(nil, compressed, 10)
+			if err != ErrTooLarge {
+				t.Errorf("Decompress() error = %v, want %v", err, ErrTooLarge)
+			}
+			if !bytes.Equal(plain, data[:10]) {
+				t.Errorf("Decompress() = %q, want %q", plain, data[:10])
+			}
+		})
+	}
+}
+
+func TestEncoder_SetCodec(t *testing.T) {
+	// arrange
+	codec := LookupCodec("deflate")
+	body := bytes.Repeat([]byte("g'day "), 200)
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetCodec(codec)
+
+	// act
+	relay := &Relay{Body: body}
+	enc.Encode(relay, MsgTypeRelay)
+	enc.Encode(&IdentityResponse{Id: 123}, MsgTypeIdentityResponse)
+	enc.Encode(&ListResponse{Ids: make([]int32, 1000)}, MsgTypeListResponse)
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert
+	if !bytes.Equal(relay.Body, body) {
+		t.Error("Encode() modified the relay body")
+	}
+	r := bytes.NewReader(w.Bytes())
+	var compressed []bool
+	for r.Len() > 0 {
+		f, err := DecodeFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed = append(compressed, f.Compressed())
+		f.Release()
+	}
+	want := []bool{true, false, true}
+	if !reflect.DeepEqual(compressed, want) {
+		t.Errorf("Encode() compressed frames = %v, want %v", compressed, want)
+	}
+
+	dec := NewDecoder(bytes.NewReader(w.Bytes()))
+	dec.SetCodec(codec)
+	payload, msgType, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Relay
+	if err := result.Unmarshal(payload); err != nil {
+		t.Fatal(err)
+	}
+	if msgType != MsgTypeRelay || !bytes.Equal(result.Body, body) {
+		t.Errorf("Decode() = %v %q, want %v %q", msgType, result.Body, MsgTypeRelay, body)
+	}
+}
+
+func TestFrame_RewriteAsRelay_compressed(t *testing.T) {
+	// arrange
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.SetCodec(LookupCodec("gzip"))
+	body := bytes.Repeat([]byte("g'day "), 200)
+	enc.Encode(&RelayRequest{Id: 456, Ids: []int32{123}, Body: body}, MsgTypeRelayRequest)
+	enc.Flush()
+	f, err := DecodeFrame(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Release()
+	req, err := ParseRelayRequest(f.Payload())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	f.RewriteAsRelay(&req, len(req.Body))
+
+	// assert
+	if f.Type() != MsgTypeRelay || !f.Compressed() {
+		t.Fatalf("RewriteAsRelay() type = %v, compressed = %v", f.Type(), f.Compressed())
+	}
+	payload, err := Decompress(f, LookupCodec("gzip"), MaxDecompressedLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Relay
+	if err := result.Unmarshal(payload); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result.Body, body) {
+		t.Errorf("Decompress() body = %q, want %q", result.Body, body)
+	}
+}
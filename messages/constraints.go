@@ -1,6 +1,50 @@
 package messages
 
 const (
+	// ProtocolVersion is the version announced in Hello, clients without Hello speak version 1
+	ProtocolVersion = 2
+	// MinProtocolVersion is the oldest version still served
+	MinProtocolVersion = 1
+	// DefaultMaxFrame is the longest frame payload accepted unless configured otherwise
+	DefaultMaxFrame = 4 * 1024 * 1024
+
 	BodyMaxLength = 1024 * 1024
 	MaxReceivers  = 255
+
+	// KeyMaxLength caps device keys binding clients to stable ids
+	KeyMaxLength = 256
+	// ProfileNameMaxLength caps display names, attributes of profiles are capped alike
+	ProfileNameMaxLength = 64
+	ProfileMaxAttributes = 32
+	AttributeMaxLength   = 256
+	// PublicKeyLength is the length of X25519 public keys in profiles
+	PublicKeyLength = 32
+	// DirectoryPageSize is the default number of users in a directory page, pages hold up to DirectoryMaxPageSize
+	DirectoryPageSize    = 100
+	DirectoryMaxPageSize = 1000
+	// ListMaxPageSize caps the ids of a list page, a page holds less than 64 KiB
+	ListMaxPageSize = 10000
+	// HistoryPageSize caps relays of a history page, it is also the page size of requests without one
+	HistoryPageSize = 100
+	// IdempotencyKeyMaxLength caps idempotency keys of relays
+	IdempotencyKeyMaxLength = 128
+	// ReplyErrorMaxLength caps errors of replies
+	ReplyErrorMaxLength = 256
+	// ReplyNoResponder is the error of the reply the hub sends when a call reaches nobody
+	ReplyNoResponder = "no responder"
+	// BlockListMaxLength caps the ids a user blocks
+	BlockListMaxLength = 1000
+	// GroupNameMaxLength caps names of queue groups, a session joins up to MaxGroups of them
+	GroupNameMaxLength = 64
+	MaxGroups          = 32
+
+	// ChunkMaxLength caps the data of a single stream chunk
+	ChunkMaxLength = 64 * 1024
+	// StreamWindow is the number of chunks a sender may have in flight before it gets acks
+	StreamWindow = 16
+
+	// CompressionThreshold is the payload (or relay body) length below which frames are sent uncompressed
+	CompressionThreshold = 512
+	// MaxDecompressedLength caps the decompressed payload of a frame
+	MaxDecompressedLength = 16 * 1024 * 1024
 )
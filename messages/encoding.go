@@ -14,6 +14,9 @@ type MsgType uint8
 const (
 	typeLen = 1
 	sizeLen = 4
+
+	// HeaderLen is the size of the frame header preceding every encoded message
+	HeaderLen = typeLen + sizeLen
 )
 
 const (
@@ -23,8 +26,69 @@ const (
 	MsgTypeListResponse
 	MsgTypeRelayRequest
 	MsgTypeRelay
+	MsgTypeBlockListResponse
+
+	// hub-to-hub frames
+	MsgTypePeerHello
+	MsgTypeGossip
+	MsgTypePeerRelay
+
+	// stream frames
+	MsgTypeStreamOpen
+	MsgTypeStreamChunk
+	MsgTypeStreamEnd
+	MsgTypeStreamAbort
+	MsgTypeStreamAck
+
+	// handshake frames
+	MsgTypeHello
+	MsgTypeWelcome
+
+	MsgTypeProfileResponse
+	MsgTypeDirectoryResponse
+	MsgTypeHistoryResponse
+	MsgTypeRelayReceipt
+	MsgTypeGroupResponse
+	MsgTypeJobAck
+	MsgTypePeerAuth
+	MsgTypePeerReceipt
 )
 
+var msgTypeNames = map[MsgType]string{
+	MsgTypeUnknown:           "Unknown",
+	MsgTypeRequest:           "Request",
+	MsgTypeIdentityResponse:  "IdentityResponse",
+	MsgTypeListResponse:      "ListResponse",
+	MsgTypeRelayRequest:      "RelayRequest",
+	MsgTypeRelay:             "Relay",
+	MsgTypeBlockListResponse: "BlockListResponse",
+	MsgTypePeerHello:         "PeerHello",
+	MsgTypeGossip:            "Gossip",
+	MsgTypePeerRelay:         "PeerRelay",
+	MsgTypeStreamOpen:        "StreamOpen",
+	MsgTypeStreamChunk:       "StreamChunk",
+	MsgTypeStreamEnd:         "StreamEnd",
+	MsgTypeStreamAbort:       "StreamAbort",
+	MsgTypeStreamAck:         "StreamAck",
+	MsgTypeHello:             "Hello",
+	MsgTypeWelcome:           "Welcome",
+	MsgTypeProfileResponse:   "ProfileResponse",
+	MsgTypeDirectoryResponse: "DirectoryResponse",
+	MsgTypeHistoryResponse:   "HistoryResponse",
+	MsgTypeRelayReceipt:      "RelayReceipt",
+	MsgTypeGroupResponse:     "GroupResponse",
+	MsgTypeJobAck:            "JobAck",
+	MsgTypePeerAuth:          "PeerAuth",
+	MsgTypePeerReceipt:       "PeerReceipt",
+}
+
+func (t MsgType) String() string {
+	if name, ok := msgTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MsgType(%d)", uint8(t))
+}
+
 func Encode(msg proto.Marshaler, msgType MsgType) ([]byte, error) {
 	b, err := msg.Marshal()
 	if err != nil {
@@ -57,6 +57,18 @@ func Test_Encode(t *testing.T) {
 			[]byte{5, 0, 0, 0, 3, 26, 1, 99},
 			false,
 		},
+		{
+			"gossip",
+			args{&Gossip{Nodes: []*NodePresence{{Node: 1, Ids: []int32{5}}}}, MsgTypeGossip},
+			[]byte{8, 0, 0, 0, 7, 10, 5, 8, 1, 34, 1, 5},
+			false,
+		},
+		{
+			"hello",
+			args{&Hello{Version: 2, Features: []string{"streams"}}, MsgTypeHello},
+			[]byte{15, 0, 0, 0, 11, 8, 2, 26, 7, 's', 't', 'r', 'e', 'a', 'm', 's'},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
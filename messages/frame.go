@@ -0,0 +1,422 @@
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// frames are allocated with at least this capacity so small frames can share buffers
+	minFrameCap = 4 * 1024
+	// larger buffers are left to the garbage collector instead of being pinned in the pool
+	maxPooledFrameCap = 64 * 1024
+
+	// relayBodyTag is the key of field 3 (body) with bytes wire type, shared by RelayRequest and Relay
+	relayBodyTag = 3<<3 | 2
+	// keys of Relay fields 4 (message_id) and 5 (timestamp) with varint wire type
+	relayMessageIDTag = 4 << 3
+	relayTimestampTag = 5 << 3
+	// keys of Relay fields 6 (from), 7 (sequence) and 8 (priority) with varint wire type
+	relayFromTag     = 6 << 3
+	relaySequenceTag = 7 << 3
+	relayPriorityTag = 8 << 3
+	// keys of Relay fields 9 (call) and 10 (reply) with varint wire type, 11 (error) with bytes wire type
+	relayCallTag  = 9 << 3
+	relayReplyTag = 10 << 3
+	relayErrorTag = 11<<3 | 2
+	// relaySealedTag is the key of Relay field 14 (sealed) with varint wire type
+	relaySealedTag = 14 << 3
+	// maxReceiverFields is the longest encoding of the fields of AppendReceiverFields
+	maxReceiverFields = 3 * (1 + binary.MaxVarintLen64)
+)
+
+var framePool = sync.Pool{
+	New: func() interface{} {
+		return &Frame{buf: make([]byte, 0, minFrameCap)}
+	},
+}
+
+var errTruncated = errors.New("truncated message")
+
+// Frame is an encoded message with its header held in a pooled buffer. A frame is
+// reference counted so one buffer can be written to many connections: every holder
+// calls Retain before handing the frame on and Release when done with it.
+type Frame struct {
+	buf  []byte
+	data []byte
+	refs int32
+}
+
+// DecodeFrame reads a frame from r into a pooled buffer, the caller must Release it. Frames
+// with payloads longer than DefaultMaxFrame fail with ErrFrameTooLarge before they are read,
+// a Decoder reads frames up to another maximum.
+func DecodeFrame(r io.Reader) (*Frame, error) {
+	return decodeFrame(r, DefaultMaxFrame)
+}
+
+// decodeFrame reads a frame with a payload of up to max bytes, 0 is unlimited
+func decodeFrame(r io.Reader, max int) (*Frame, error) {
+	f := newFrame(HeaderLen)
+	if _, err := io.ReadFull(r, f.data); err != nil {
+		f.Release()
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(f.data[typeLen:]))
+	if max > 0 && length > int64(max) {
+		f.Release()
+		return nil, ErrFrameTooLarge
+	}
+
+	f.grow(HeaderLen + int(length))
+	if _, err := io.ReadFull(r, f.data[HeaderLen:]); err != nil {
+		f.Release()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// EncodeFrame encodes msg into a pooled frame, the caller must Release it
+func EncodeFrame(msg Message, msgType MsgType) (*Frame, error) {
+	size := msg.Size()
+	f := newFrame(HeaderLen + size)
+	putHeader(f.data, msgType, size)
+	if _, err := msg.MarshalTo(f.data[HeaderLen:]); err != nil {
+		f.Release()
+		return nil, fmt.Errorf("marshalling failed: %s", err.Error())
+	}
+
+	return f, nil
+}
+
+// Message is implemented by the generated messages
+type Message interface {
+	Size() int
+	MarshalTo(dAtA []byte) (int, error)
+}
+
+func newFrame(size int) *Frame {
+	f := framePool.Get().(*Frame)
+	if cap(f.buf) < size {
+		f.buf = make([]byte, size)
+	}
+	f.data = f.buf[:size]
+	f.refs = 1
+	return f
+}
+
+// grow resizes the frame keeping its contents
+func (f *Frame) grow(size int) {
+	if cap(f.buf) < size {
+		buf := make([]byte, size)
+		copy(buf, f.data)
+		f.buf = buf
+	}
+	f.data = f.buf[:size]
+}
+
+func putHeader(b []byte, msgType MsgType, length int) {
+	b[0] = byte(msgType)
+	binary.BigEndian.PutUint32(b[typeLen:], uint32(length))
+}
+
+// Type returns the message type from the frame header without flags
+func (f *Frame) Type() MsgType {
+	return MsgType(f.data[0]) &^ FlagCompressed
+}
+
+// Payload returns the encoded message without the header
+func (f *Frame) Payload() []byte {
+	return f.data[HeaderLen:]
+}
+
+// Bytes returns the whole frame ready to be written to a connection
+func (f *Frame) Bytes() []byte {
+	return f.data
+}
+
+// Retain adds a reference to the frame
+func (f *Frame) Retain() {
+	atomic.AddInt32(&f.refs, 1)
+}
+
+// Release drops a reference, the last one returns the buffer to the pool
+func (f *Frame) Release() {
+	refs := atomic.AddInt32(&f.refs, -1)
+	if refs > 0 {
+		return
+	}
+	if refs < 0 {
+		panic("messages: frame released too many times")
+	}
+	if cap(f.buf) <= maxPooledFrameCap {
+		f.data = nil
+		framePool.Put(f)
+	}
+}
+
+// RelayRequestView is a RelayRequest read in place, Body aliases the frame it was parsed from
+type RelayRequestView struct {
+	Id             int32
+	Ids            []int32
+	Body           []byte
+	Ttl            uint32
+	Receipt        uint64
+	Priority       RelayRequest_Priority
+	IdempotencyKey string
+	Call           uint64
+	Reply          uint64
+	Error          string
+	Group          string
+	Sealed         bool
+
+	// bodyOffset is the position of Body in the payload
+	bodyOffset int
+}
+
+// ParseRelayRequest reads a RelayRequest payload without copying the body
+func ParseRelayRequest(payload []byte) (RelayRequestView, error) {
+	var req RelayRequestView
+	for i := 0; i < len(payload); {
+		key, n := binary.Uvarint(payload[i:])
+		if n <= 0 {
+			return req, errTruncated
+		}
+		i += n
+		fieldNum, wireType := key>>3, key&7
+
+		switch {
+		case fieldNum == 1 && wireType == 0:
+			v, n := binary.Uvarint(payload[i:])
+			if n <= 0 {
+				return req, errTruncated
+			}
+			req.Id = int32(v)
+			i += n
+		case fieldNum == 2 && wireType == 0:
+			v, n := binary.Uvarint(payload[i:])
+			if n <= 0 {
+				return req, errTruncated
+			}
+			req.Ids = append(req.Ids, int32(v))
+			i += n
+		case fieldNum == 2 && wireType == 2:
+			packed, n, err := lengthDelimited(payload, i)
+			if err != nil {
+				return req, err
+			}
+			i += n
+			if req.Ids == nil {
+				// every id takes at least a byte
+				req.Ids = make([]int32, 0, len(packed))
+			}
+			for j := 0; j < len(packed); {
+				v, n := binary.Uvarint(packed[j:])
+				if n <= 0 {
+					return req, errTruncated
+				}
+				req.Ids = append(req.Ids, int32(v))
+				j += n
+			}
+		case fieldNum == 3 && wireType == 2:
+			body, n, err := lengthDelimited(payload, i)
+			if err != nil {
+				return req, err
+			}
+			req.Body = body
+			req.bodyOffset = i + n - len(body)
+			i += n
+		case (fieldNum >= 4 && fieldNum <= 6 || fieldNum == 8 || fieldNum == 9 || fieldNum == 12) && wireType == 0:
+			v, n := binary.Uvarint(payload[i:])
+			if n <= 0 {
+				return req, errTruncated
+			}
+			switch fieldNum {
+			case 4:
+				req.Ttl = uint32(v)
+			case 5:
+				req.Receipt = v
+			case 6:
+				req.Priority = RelayRequest_Priority(v)
+			case 8:
+				req.Call = v
+			case 9:
+				req.Reply = v
+			case 12:
+				req.Sealed = v != 0
+			}
+			i += n
+		case (fieldNum == 7 || fieldNum == 10 || fieldNum == 11) && wireType == 2:
+			value, n, err := lengthDelimited(payload, i)
+			if err != nil {
+				return req, err
+			}
+			switch fieldNum {
+			case 7:
+				req.IdempotencyKey = string(value)
+			case 10:
+				req.Error = string(value)
+			case 11:
+				req.Group = string(value)
+			}
+			i += n
+		default:
+			n, err := skipField(payload[i:], wireType)
+			if err != nil {
+				return req, err
+			}
+			i += n
+		}
+	}
+
+	return req, nil
+}
+
+// lengthDelimited returns the field value starting with its length at payload[i:]
+// and the number of bytes taken by length and value
+func lengthDelimited(payload []byte, i int) ([]byte, int, error) {
+	length, n := binary.Uvarint(payload[i:])
+	if n <= 0 || length > uint64(len(payload)-i-n) {
+		return nil, 0, errTruncated
+	}
+	start := i + n
+	return payload[start : start+int(length)], n + int(length), nil
+}
+
+func skipField(b []byte, wireType uint64) (int, error) {
+	switch wireType {
+	case 0:
+		if _, n := binary.Uvarint(b); n > 0 {
+			return n, nil
+		}
+	case 1:
+		if len(b) >= 8 {
+			return 8, nil
+		}
+	case 2:
+		if _, n, err := lengthDelimited(b, 0); err == nil {
+			return n, nil
+		}
+	case 5:
+		if len(b) >= 4 {
+			return 4, nil
+		}
+	default:
+		return 0, fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	return 0, errTruncated
+}
+
+// RewriteAsRelay turns a frame holding req into a Relay frame carrying the first bodyLen
+// bytes of req.Body. Relay header and body key are written over the request fields which
+// precede the body, so the body is never copied. The frame can't be parsed as RelayRequest
+// afterwards.
+func (f *Frame) RewriteAsRelay(req *RelayRequestView, bodyLen int) {
+	if bodyLen == 0 {
+		f.data = f.data[:HeaderLen]
+		putHeader(f.data, MsgTypeRelay, 0)
+		return
+	}
+	// a compressed body stays compressed
+	msgType := MsgTypeRelay | MsgType(f.data[0])&FlagCompressed
+
+	// the original key and length take at least as many bytes as the new ones,
+	// and the request header is in front of them, so everything fits before the body
+	bodyStart := HeaderLen + req.bodyOffset
+	keyLen := 1 + uvarintLen(uint64(bodyLen))
+	start := bodyStart - keyLen - HeaderLen
+	data := f.data[start : bodyStart+bodyLen]
+
+	putHeader(data, msgType, keyLen+bodyLen)
+	data[HeaderLen] = relayBodyTag
+	binary.PutUvarint(data[HeaderLen+1:], uint64(bodyLen))
+	f.data = data
+}
+
+// AppendRelayFields adds message id and timestamp to a frame rewritten by RewriteAsRelay.
+// The fields follow the body, which stays in place unless the buffer is too short.
+func (f *Frame) AppendRelayFields(messageID uint64, timestamp int64) {
+	var fields [2 * (1 + binary.MaxVarintLen64)]byte
+	n := 0
+	fields[n] = relayMessageIDTag
+	n += 1 + binary.PutUvarint(fields[n+1:], messageID)
+	fields[n] = relayTimestampTag
+	n += 1 + binary.PutUvarint(fields[n+1:], uint64(timestamp))
+	f.appendPayload(fields[:n])
+}
+
+// AppendRelayCall adds the request/reply fields to a frame rewritten by RewriteAsRelay,
+// see AppendRelayFields
+func (f *Frame) AppendRelayCall(call, reply uint64, err string) {
+	fields := make([]byte, 0, 2*(1+binary.MaxVarintLen64)+1+binary.MaxVarintLen64+len(err))
+	if call != 0 {
+		fields = append(fields, relayCallTag)
+		fields = appendUvarint(fields, call)
+	}
+	if reply != 0 {
+		fields = append(fields, relayReplyTag)
+		fields = appendUvarint(fields, reply)
+	}
+	if err != "" {
+		fields = append(fields, relayErrorTag)
+		fields = appendUvarint(fields, uint64(len(err)))
+		fields = append(fields, err...)
+	}
+	f.appendPayload(fields)
+}
+
+// AppendRelaySealed marks a frame rewritten by RewriteAsRelay as sealed, see AppendRelayFields
+func (f *Frame) AppendRelaySealed() {
+	f.appendPayload([]byte{relaySealedTag, 1})
+}
+
+// appendPayload adds encoded fields to the payload and fixes the header
+func (f *Frame) appendPayload(fields []byte) {
+	size := len(f.data) + len(fields)
+	if cap(f.data) < size {
+		buf := make([]byte, size)
+		copy(buf, f.data)
+		f.buf = buf
+		f.data = buf
+	}
+	f.data = f.data[:size]
+	copy(f.data[size-len(fields):], fields)
+	binary.BigEndian.PutUint32(f.data[typeLen:], uint32(size-HeaderLen))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// AppendReceiverFields appends the Relay fields which differ between receivers of a relay
+// frame to b, they are written after the shared frame by Encoder.WriteRelayFrame
+func AppendReceiverFields(b []byte, from int32, sequence uint64, priority RelayRequest_Priority) []byte {
+	var fields [maxReceiverFields]byte
+	n := 0
+	fields[n] = relayFromTag
+	n += 1 + binary.PutUvarint(fields[n+1:], uint64(from))
+	fields[n] = relaySequenceTag
+	n += 1 + binary.PutUvarint(fields[n+1:], sequence)
+	if priority != RelayRequest_NORMAL {
+		fields[n] = relayPriorityTag
+		n += 1 + binary.PutUvarint(fields[n+1:], uint64(priority))
+	}
+	return append(b, fields[:n]...)
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,356 @@
+package messages
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantType    MsgType
+		wantPayload []byte
+		wantErr     bool
+	}{
+		{"empty", []byte{0, 0, 0, 0, 0}, MsgTypeUnknown, []byte{}, false},
+		{"relay", []byte{5, 0, 0, 0, 3, 26, 1, 99}, MsgTypeRelay, []byte{26, 1, 99}, false},
+		{"truncated", []byte{5, 0, 0, 0, 3, 26}, MsgTypeUnknown, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := DecodeFrame(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer f.Release()
+			if f.Type() != tt.wantType {
+				t.Errorf("DecodeFrame() type = %v, want %v", f.Type(), tt.wantType)
+			}
+			if !bytes.Equal(f.Payload(), tt.wantPayload) {
+				t.Errorf("DecodeFrame() payload = %v, want %v", f.Payload(), tt.wantPayload)
+			}
+			if !bytes.Equal(f.Bytes(), tt.data) {
+				t.Errorf("DecodeFrame() bytes = %v, want %v", f.Bytes(), tt.data)
+			}
+		})
+	}
+}
+
+func TestDecodeFrame_tooLarge(t *testing.T) {
+	// arrange, the header declares a payload longer than any frame read
+	data := []byte{5, 0xff, 0xff, 0xff, 0xff, 26}
+
+	// act
+	f, err := DecodeFrame(bytes.NewReader(data))
+
+	// assert
+	if err != ErrFrameTooLarge || f != nil {
+		t.Errorf("DecodeFrame() error = %v, want %v", err, ErrFrameTooLarge)
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	msg := &RelayRequest{Id: 456, Ids: []int32{123}, Body: []byte{99}}
+	want, err := Encode(msg, MsgTypeRelayRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := EncodeFrame(msg, MsgTypeRelayRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Release()
+	if !bytes.Equal(f.Bytes(), want) {
+		t.Errorf("EncodeFrame() = %v, want %v", f.Bytes(), want)
+	}
+}
+
+func TestParseRelayRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    RelayRequestView
+		wantErr bool
+	}{
+		{
+			"packed ids",
+			[]byte{8, 200, 3, 18, 2, 123, 124, 26, 1, 99},
+			RelayRequestView{Id: 456, Ids: []int32{123, 124}, Body: []byte{99}, bodyOffset: 9},
+			false,
+		},
+		{
+			"unpacked ids and unknown field",
+			[]byte{16, 123, 16, 124, 104, 7, 26, 2, 99, 100},
+			RelayRequestView{Ids: []int32{123, 124}, Body: []byte{99, 100}, bodyOffset: 8},
+			false,
+		},
+		{
+			"options after body",
+			[]byte{26, 1, 99, 32, 232, 7, 40, 9, 48, 1, 58, 1, 'k'},
+			RelayRequestView{Body: []byte{99}, Ttl: 1000, Receipt: 9, Priority: RelayRequest_HIGH, IdempotencyKey: "k", bodyOffset: 2},
+			false,
+		},
+		{
+			"call, group and sealed",
+			[]byte{26, 1, 99, 64, 5, 72, 6, 82, 1, 'e', 90, 1, 'g', 96, 1},
+			RelayRequestView{Body: []byte{99}, Call: 5, Reply: 6, Error: "e", Group: "g", Sealed: true, bodyOffset: 2},
+			false,
+		},
+		{
+			"no body",
+			[]byte{8, 1},
+			RelayRequestView{Id: 1},
+			false,
+		},
+		{
+			"truncated body",
+			[]byte{26, 5, 99},
+			RelayRequestView{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRelayRequest(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRelayRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRelayRequest() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrame_RewriteAsRelay(t *testing.T) {
+	body := bytes.Repeat([]byte{'a'}, 200)
+	tests := []struct {
+		name    string
+		bodyLen int
+	}{
+		{"whole body", 200},
+		{"truncated body with shorter length", 100},
+		{"empty body", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			req, err := Encode(&RelayRequest{Id: 456, Ids: []int32{1, 2, 3}, Body: body}, MsgTypeRelayRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := DecodeFrame(bytes.NewReader(req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+			view, err := ParseRelayRequest(f.Payload())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// act
+			f.RewriteAsRelay(&view, tt.bodyLen)
+
+			// assert
+			want, err := Encode(&Relay{Body: body[:tt.bodyLen]}, MsgTypeRelay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.bodyLen == 0 {
+				want = []byte{byte(MsgTypeRelay), 0, 0, 0, 0}
+			}
+			if !bytes.Equal(f.Bytes(), want) {
+				t.Errorf("RewriteAsRelay() = %v, want %v", f.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestFrame_AppendRelayFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		bodyLen int
+	}{
+		{"in place", 200},
+		{"buffer too short", 2 * minFrameCap},
+		{"empty body", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			body := bytes.Repeat([]byte{'a'}, tt.bodyLen)
+			req, err := Encode(&RelayRequest{Ids: []int32{1}, Body: body}, MsgTypeRelayRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := DecodeFrame(bytes.NewReader(req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+			view, err := ParseRelayRequest(f.Payload())
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.RewriteAsRelay(&view, tt.bodyLen)
+
+			// act
+			f.AppendRelayFields(1<<60, 1600000000000)
+
+			// assert
+			want, err := Encode(&Relay{Body: body, MessageId: 1 << 60, Timestamp: 1600000000000}, MsgTypeRelay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Bytes(), want) {
+				t.Errorf("AppendRelayFields() = %v, want %v", f.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestFrame_AppendRelayCall(t *testing.T) {
+	tests := []struct {
+		name  string
+		relay *Relay
+	}{
+		{"call", &Relay{Body: []byte("ping"), Call: 1 << 63}},
+		{"reply", &Relay{Body: []byte("pong"), Reply: 7}},
+		{"error", &Relay{Reply: 7, Error: ReplyNoResponder}},
+		{"sealed reply", &Relay{Body: []byte("box"), Reply: 7, Sealed: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// arrange
+			req, err := Encode(&RelayRequest{Ids: []int32{1}, Body: tt.relay.Body}, MsgTypeRelayRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := DecodeFrame(bytes.NewReader(req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Release()
+			view, err := ParseRelayRequest(f.Payload())
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.RewriteAsRelay(&view, len(view.Body))
+
+			// act
+			f.AppendRelayCall(tt.relay.Call, tt.relay.Reply, tt.relay.Error)
+			if tt.relay.Sealed {
+				f.AppendRelaySealed()
+			}
+
+			// assert
+			want, err := Encode(tt.relay, MsgTypeRelay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Bytes(), want) {
+				t.Errorf("AppendRelayCall() = %v, want %v", f.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestFrame_Release(t *testing.T) {
+	f, err := DecodeFrame(bytes.NewReader([]byte{5, 0, 0, 0, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Retain()
+	f.Release()
+	f.Release()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Release() did not panic on extra release")
+		}
+	}()
+	f.Release()
+}
+
+// The benchmarks compare the allocating path with the pooled one:
+//
+//	go test -bench . -benchmem ./messages
+
+var benchRelayRequest = func() []byte {
+	b, err := Encode(&RelayRequest{Id: 1, Ids: []int32{2, 3, 4, 5}, Body: bytes.Repeat([]byte{'a'}, 512)}, MsgTypeRelayRequest)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+func BenchmarkDecode(b *testing.B) {
+	r := bytes.NewReader(benchRelayRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchRelayRequest)
+		if _, _, err := Decode(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeFrame(b *testing.B) {
+	r := bytes.NewReader(benchRelayRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchRelayRequest)
+		f, err := DecodeFrame(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Release()
+	}
+}
+
+// BenchmarkRelay_unmarshalEncode is the relay path before frames: decode, unmarshal, copy, encode
+func BenchmarkRelay_unmarshalEncode(b *testing.B) {
+	r := bytes.NewReader(benchRelayRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchRelayRequest)
+		payload, _, err := Decode(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var req RelayRequest
+		if err := req.Unmarshal(payload); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Encode(&Relay{Body: req.Body}, MsgTypeRelay); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRelay_rewrite(b *testing.B) {
+	r := bytes.NewReader(benchRelayRequest)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(benchRelayRequest)
+		f, err := DecodeFrame(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		view, err := ParseRelayRequest(f.Payload())
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.RewriteAsRelay(&view, len(view.Body))
+		io.Discard.Write(f.Bytes())
+		f.Release()
+	}
+}
@@ -0,0 +1,66 @@
+package messages
+
+import "errors"
+
+// Features are the optional parts of the protocol negotiated with Hello
+const (
+	// FeatureCompression allows negotiating a codec with the identity request
+	FeatureCompression = "compression"
+	// FeatureStreams enables chunked streams multiplexed by id with per-stream acks
+	FeatureStreams = "streams"
+)
+
+// ErrFrameTooLarge is returned for frames longer than the negotiated maximum
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// Features returns all features this version of the protocol supports
+func Features() []string {
+	return []string{FeatureCompression, FeatureStreams}
+}
+
+// NewHello announces the local protocol version, the longest payload
+// accepted and all supported features
+func NewHello(maxFrame int) *Hello {
+	return &Hello{
+		Version:  ProtocolVersion,
+		MaxFrame: uint32(maxFrame),
+		Features: Features(),
+	}
+}
+
+// Negotiate answers a remote Hello with the common version and features, the connection
+// is rejected if the remote version is too old. Every side keeps announcing its own max
+// frame, frames sent to a peer must fit into the max frame of the peer.
+func Negotiate(local, remote *Hello) *Welcome {
+	if remote.Version < MinProtocolVersion {
+		return &Welcome{
+			Version: local.Version,
+			Reason:  "protocol version is not supported",
+		}
+	}
+
+	welcome := &Welcome{
+		Version:  local.Version,
+		MaxFrame: local.MaxFrame,
+		Features: []string{},
+	}
+	if remote.Version < welcome.Version {
+		welcome.Version = remote.Version
+	}
+	for _, feature := range local.Features {
+		if HasFeature(remote.Features, feature) {
+			welcome.Features = append(welcome.Features, feature)
+		}
+	}
+	return welcome
+}
+
+// HasFeature reports whether feature is among features
+func HasFeature(features []string, feature string) bool {
+	for _, f := range features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
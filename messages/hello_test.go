@@ -0,0 +1,43 @@
+package messages
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	local := &Hello{Version: 2, MaxFrame: 1024, Features: []string{FeatureCompression, FeatureStreams}}
+	tests := []struct {
+		name   string
+		remote *Hello
+		want   *Welcome
+	}{
+		{
+			"same version",
+			&Hello{Version: 2, MaxFrame: 64, Features: []string{FeatureStreams, "unknown"}},
+			&Welcome{Version: 2, MaxFrame: 1024, Features: []string{FeatureStreams}},
+		},
+		{
+			"newer client",
+			&Hello{Version: 3, Features: []string{FeatureCompression, FeatureStreams}},
+			&Welcome{Version: 2, MaxFrame: 1024, Features: []string{FeatureCompression, FeatureStreams}},
+		},
+		{
+			"older client",
+			&Hello{Version: 1},
+			&Welcome{Version: 1, MaxFrame: 1024, Features: []string{}},
+		},
+		{
+			"unsupported client",
+			&Hello{Version: 0},
+			&Welcome{Version: 2, Reason: "protocol version is not supported"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(local, tt.remote); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Negotiate() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
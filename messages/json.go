@@ -0,0 +1,219 @@
+package messages
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// JSON lines carry the same messages as frames for debugging and scripting: every line
+// is an object holding one message under its type name, e.g.
+//
+//	{"RelayRequest":{"ids":[123],"body":"ZydkYXk="}}
+//
+// Messages follow the proto3 JSON mapping with field names as in messages.proto: bytes
+// are base64, enums are names and 64 bit integers are strings.
+
+// ErrLineTooLong is returned for JSON lines longer than the limit of a JSONConn
+var ErrLineTooLong = errors.New("json line too long")
+
+var (
+	jsonMarshaler   = jsonpb.Marshaler{OrigName: true}
+	jsonUnmarshaler = jsonpb.Unmarshaler{}
+)
+
+// ParseMsgType returns the message type with name, MsgTypeUnknown if there is none
+func ParseMsgType(name string) MsgType {
+	for t, n := range msgTypeNames {
+		if n == name {
+			return t
+		}
+	}
+	return MsgTypeUnknown
+}
+
+// NewMessage returns an empty message of type t, message types are named as in messages.proto
+func NewMessage(t MsgType) (proto.Message, error) {
+	typ := proto.MessageType(t.String())
+	if typ == nil {
+		return nil, fmt.Errorf("no message for type %s", t)
+	}
+	return reflect.New(typ.Elem()).Interface().(proto.Message), nil
+}
+
+// MarshalJSON appends msg as a JSON line to dst
+func MarshalJSON(dst []byte, msg proto.Message, msgType MsgType) ([]byte, error) {
+	s, err := jsonMarshaler.MarshalToString(msg)
+	if err != nil {
+		return dst, fmt.Errorf("marshalling failed: %s", err.Error())
+	}
+	dst = append(dst, '{')
+	dst = strconv.AppendQuote(dst, msgType.String())
+	dst = append(dst, ':')
+	dst = append(dst, s...)
+	return append(dst, '}', '\n'), nil
+}
+
+// UnmarshalJSON parses a JSON line
+func UnmarshalJSON(line []byte) (proto.Message, MsgType, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return nil, MsgTypeUnknown, err
+	}
+	if len(envelope) == 1 {
+		for name, raw := range envelope {
+			return unmarshalJSONMessage(name, raw)
+		}
+	}
+	return nil, MsgTypeUnknown, fmt.Errorf("expected one message, got %d", len(envelope))
+}
+
+func unmarshalJSONMessage(name string, raw json.RawMessage) (proto.Message, MsgType, error) {
+	msgType := ParseMsgType(name)
+	msg, err := NewMessage(msgType)
+	if err != nil {
+		return nil, MsgTypeUnknown, fmt.Errorf("unknown message %q", name)
+	}
+	if err := jsonUnmarshaler.Unmarshal(bytes.NewReader(raw), msg); err != nil {
+		return nil, MsgTypeUnknown, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	return msg, msgType, nil
+}
+
+// FrameToJSON appends the message in an encoded frame as a JSON line to dst
+func FrameToJSON(dst []byte, msgType MsgType, payload []byte) ([]byte, error) {
+	if msgType&FlagCompressed != 0 {
+		return dst, fmt.Errorf("%s: compressed frames have no JSON form", msgType&^FlagCompressed)
+	}
+	msg, err := NewMessage(msgType)
+	if err != nil {
+		return dst, err
+	}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return dst, fmt.Errorf("unmarshal failed, %s", err.Error())
+	}
+	return MarshalJSON(dst, msg, msgType)
+}
+
+// JSONConn translates between JSON lines on the wire and frames, so code reading and
+// writing frames serves JSON peers too. Invalid lines are answered with
+// {"error":"..."} and skipped, the connection stays usable.
+type JSONConn struct {
+	net.Conn
+	r       *bufio.Reader
+	maxLine int
+	// frames holds frames translated from a line but not read yet
+	frames []byte
+
+	writeLock sync.Mutex
+	// partial holds the start of a frame written incompletely
+	partial []byte
+	lines   []byte
+}
+
+// NewJSONConn reads lines of at most maxLine bytes from conn, 0 is unlimited
+func NewJSONConn(conn net.Conn, maxLine int) *JSONConn {
+	return &JSONConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		maxLine: maxLine,
+	}
+}
+
+// Read returns frames translated from JSON lines
+func (c *JSONConn) Read(p []byte) (int, error) {
+	for len(c.frames) == 0 {
+		line, err := c.readLine()
+		if err != nil {
+			return 0, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		msg, msgType, err := UnmarshalJSON(line)
+		if err != nil {
+			if err := c.writeError(err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		frame, err := Encode(msg.(proto.Marshaler), msgType)
+		if err != nil {
+			return 0, err
+		}
+		c.frames = frame
+	}
+	n := copy(p, c.frames)
+	c.frames = c.frames[n:]
+	return n, nil
+}
+
+func (c *JSONConn) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := c.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if c.maxLine > 0 && len(line) > c.maxLine {
+			return nil, ErrLineTooLong
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (c *JSONConn) writeError(err error) error {
+	line, _ := json.Marshal(map[string]string{"error": err.Error()})
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err = c.Conn.Write(append(line, '\n'))
+	return err
+}
+
+// Write translates frames to JSON lines, frames may be split across writes
+func (c *JSONConn) Write(p []byte) (int, error) {
+	n, err := c.WriteBuffers(net.Buffers{p})
+	return int(n), err
+}
+
+// WriteBuffers translates all frames in bufs and writes their lines at once,
+// see BuffersWriter
+func (c *JSONConn) WriteBuffers(bufs net.Buffers) (int64, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	var n int64
+	for _, b := range bufs {
+		c.partial = append(c.partial, b...)
+		n += int64(len(b))
+	}
+	var err error
+	var i int
+	c.lines = c.lines[:0]
+	for err == nil && len(c.partial)-i >= HeaderLen {
+		frame := c.partial[i:]
+		length := int(binary.BigEndian.Uint32(frame[typeLen:]))
+		if len(frame) < HeaderLen+length {
+			break
+		}
+		c.lines, err = FrameToJSON(c.lines, MsgType(frame[0]), frame[HeaderLen:HeaderLen+length])
+		i += HeaderLen + length
+	}
+	c.partial = append(c.partial[:0], c.partial[i:]...)
+	if len(c.lines) > 0 {
+		if _, werr := c.Conn.Write(c.lines); werr != nil {
+			return 0, werr
+		}
+	}
+	return n, err
+}
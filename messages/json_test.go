@@ -0,0 +1,161 @@
+package messages
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     proto.Message
+		msgType MsgType
+		want    string
+	}{
+		{
+			"identity request",
+			&Request{Type: Request_IDENTITY, Codecs: []string{"gzip"}},
+			MsgTypeRequest,
+			`{"Request":{"type":"IDENTITY","codecs":["gzip"]}}`,
+		},
+		{
+			"relay request",
+			&RelayRequest{Ids: []int32{123}, Body: []byte("g'day")},
+			MsgTypeRelayRequest,
+			`{"RelayRequest":{"ids":[123],"body":"ZydkYXk="}}`,
+		},
+		{
+			"empty relay",
+			&Relay{},
+			MsgTypeRelay,
+			`{"Relay":{}}`,
+		},
+		{
+			"stream open",
+			&StreamOpen{Stream: 1, Name: "cat.gif", Length: 3},
+			MsgTypeStreamOpen,
+			`{"StreamOpen":{"stream":"1","name":"cat.gif","length":"3"}}`,
+		},
+		{
+			"hello",
+			&Hello{Version: 2, MaxFrame: 1024},
+			MsgTypeHello,
+			`{"Hello":{"version":2,"max_frame":1024}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalJSON(nil, tt.msg, tt.msgType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want+"\n" {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			msg, msgType, err := UnmarshalJSON(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msgType != tt.msgType || !reflect.DeepEqual(msg, tt.msg) {
+				t.Errorf("UnmarshalJSON() = %v %#v, want %v %#v", msgType, msg, tt.msgType, tt.msg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"not json", `hello`},
+		{"no message", `{}`},
+		{"two messages", `{"Relay":{},"Request":{}}`},
+		{"unknown message", `{"Greeting":{}}`},
+		{"unknown field", `{"Relay":{"sender":1}}`},
+		{"unknown enum", `{"Request":{"type":"HUG"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := UnmarshalJSON([]byte(tt.line)); err == nil {
+				t.Errorf("UnmarshalJSON(%s) error = nil, want error", tt.line)
+			}
+		})
+	}
+}
+
+func TestJSONConn(t *testing.T) {
+	// arrange
+	server, client := net.Pipe()
+	conn := NewJSONConn(server, 1024)
+	lines := readLines(client)
+	go client.Write([]byte("{\"Request\":{\"type\":\"LIST\"}}\n\nbad\n{\"Relay\":{\"body\":\"Yw==\"}}\n"))
+
+	// act
+	dec := NewDecoder(conn)
+	payload, msgType, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request Request
+	if err := request.Unmarshal(payload); err != nil {
+		t.Fatal(err)
+	}
+	relayPayload, relayType, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert
+	if msgType != MsgTypeRequest || request.Type != Request_LIST {
+		t.Errorf("Read() = %v %#v, want %v LIST", msgType, request, MsgTypeRequest)
+	}
+	if errLine := <-lines; errLine != "{\"error\":\"invalid character 'b' looking for beginning of value\"}\n" {
+		t.Errorf("Read() error line = %q", errLine)
+	}
+	if relayType != MsgTypeRelay || !reflect.DeepEqual(relayPayload, []byte{26, 1, 99}) {
+		t.Errorf("Read() = %v %v, want %v %v", relayType, relayPayload, MsgTypeRelay, []byte{26, 1, 99})
+	}
+
+	// frames are translated once complete, a flush is written at once
+	go func() {
+		enc := NewEncoder(conn)
+		enc.Encode(&IdentityResponse{Id: 123}, MsgTypeIdentityResponse)
+		enc.Encode(&Relay{Body: []byte{99}}, MsgTypeRelay)
+		enc.Flush()
+		frame, _ := Encode(&ListResponse{Ids: []int32{123}}, MsgTypeListResponse)
+		conn.Write(frame[:3])
+		conn.Write(frame[3:])
+	}()
+	got := []string{<-lines, <-lines, <-lines}
+	want := []string{
+		"{\"IdentityResponse\":{\"id\":123}}\n",
+		"{\"Relay\":{\"body\":\"Yw==\"}}\n",
+		"{\"ListResponse\":{\"ids\":[123]}}\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Write() = %q, want %q", got, want)
+	}
+}
+
+// readLines reads lines from conn until it is closed
+func readLines(conn net.Conn) <-chan string {
+	lines := make(chan string, 16)
+	go func() {
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- line
+		}
+	}()
+	return lines
+}
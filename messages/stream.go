@@ -0,0 +1,319 @@
+package messages
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// encoders flush on their own once this many bytes are pending
+	maxBuffered = 256 * 1024
+	// maxFrameLen is the largest payload length the header can carry
+	maxFrameLen = 1<<(8*sizeLen) - 1
+)
+
+// Encoder writes frames to an io.Writer. Frames are buffered until Flush, so several
+// frames can be sent at once: headers and bodies are handed to the writer as net.Buffers,
+// which is a single writev syscall for a net.Conn. Encoder is not safe for concurrent use.
+type Encoder struct {
+	w        io.Writer
+	codec    Codec
+	maxFrame int
+	bufs    net.Buffers
+	out     net.Buffers
+	headers []byte
+	frames  []*Frame
+	pending int
+}
+
+// BuffersWriter is implemented by writers which keep the buffers of a Flush together,
+// e.g. as one WebSocket message. WriteBuffers must not retain or modify bufs.
+type BuffersWriter interface {
+	WriteBuffers(bufs net.Buffers) (int64, error)
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: w,
+	}
+}
+
+// SetCodec compresses frames encoded from now on with codec, nil turns compression off
+func (e *Encoder) SetCodec(codec Codec) {
+	e.codec = codec
+}
+
+// SetMaxFrame makes the encoder fail frames with longer payloads than max, 0 is unlimited
+func (e *Encoder) SetMaxFrame(max int) {
+	e.maxFrame = max
+}
+
+func (e *Encoder) fits(payloadLen int) error {
+	if e.maxFrame > 0 && payloadLen > e.maxFrame {
+		return ErrFrameTooLarge
+	}
+	return nil
+}
+
+// Encode marshals msg and queues the frame, payloads (or relay bodies) of at least
+// CompressionThreshold bytes are compressed if a codec is set
+func (e *Encoder) Encode(msg Message, msgType MsgType) error {
+	if e.codec != nil {
+		if m, ok := msg.(relayMessage); ok {
+			return e.encodeRelay(m, msgType)
+		}
+		if msg.Size() >= CompressionThreshold {
+			return e.encodeCompressed(msg, msgType)
+		}
+	}
+	return e.encode(msg, msgType)
+}
+
+func (e *Encoder) encode(msg Message, msgType MsgType) error {
+	size := msg.Size()
+	if err := e.fits(size); err != nil {
+		return err
+	}
+	body := newFrame(size)
+	if _, err := msg.MarshalTo(body.data); err != nil {
+		body.Release()
+		return fmt.Errorf("marshalling failed: %s", err.Error())
+	}
+	e.frames = append(e.frames, body)
+
+	return e.WriteRaw(msgType, body.data)
+}
+
+func (e *Encoder) encodeCompressed(msg Message, msgType MsgType) error {
+	f, err := EncodeFrame(msg, msgType)
+	if err != nil {
+		return err
+	}
+	if f, err = compressFrame(e.codec, f); err != nil {
+		return err
+	}
+	if err := e.fits(len(f.Payload())); err != nil {
+		f.Release()
+		return err
+	}
+	e.frames = append(e.frames, f)
+	e.bufs = append(e.bufs, f.Bytes())
+	return e.queued(len(f.Bytes()))
+}
+
+// encodeRelay compresses the body of a relay only, so hubs can route it as is
+func (e *Encoder) encodeRelay(msg relayMessage, msgType MsgType) error {
+	body := msg.GetBody()
+	if len(body) < CompressionThreshold {
+		return e.encode(msg, msgType)
+	}
+
+	z := newFrame(0)
+	compressed, err := e.codec.Compress(z.data, body)
+	if err != nil {
+		z.Release()
+		return fmt.Errorf("compression failed: %s", err.Error())
+	}
+	if len(compressed) >= len(body) {
+		z.Release()
+		return e.encode(msg, msgType)
+	}
+	z.buf = compressed
+	z.data = compressed
+	e.frames = append(e.frames, z)
+
+	msg.setBody(compressed)
+	err = e.encode(msg, msgType|FlagCompressed)
+	msg.setBody(body)
+	return err
+}
+
+// WriteRaw queues a frame with an already encoded payload,
+// payload must not be modified until Flush
+func (e *Encoder) WriteRaw(msgType MsgType, payload []byte) error {
+	if uint64(len(payload)) > maxFrameLen {
+		return fmt.Errorf("message too large (%d bytes)", len(payload))
+	}
+	if err := e.fits(len(payload)); err != nil {
+		return err
+	}
+
+	// headers share one buffer, slices taken before it grows keep pointing to the old one
+	start := len(e.headers)
+	e.headers = append(e.headers, make([]byte, HeaderLen)...)
+	header := e.headers[start : start+HeaderLen]
+	putHeader(header, msgType, len(payload))
+
+	e.bufs = append(e.bufs, header)
+	if len(payload) > 0 {
+		e.bufs = append(e.bufs, payload)
+	}
+	return e.queued(HeaderLen + len(payload))
+}
+
+// WriteFrame queues an encoded frame, the encoder holds a reference to it until Flush
+func (e *Encoder) WriteFrame(f *Frame) error {
+	if err := e.fits(len(f.Payload())); err != nil {
+		return err
+	}
+	f.Retain()
+	e.frames = append(e.frames, f)
+	e.bufs = append(e.bufs, f.Bytes())
+	return e.queued(len(f.Bytes()))
+}
+
+// WriteRelayFrame queues a relay frame followed by the fields of its receiver, see
+// AppendReceiverFields. The frame is shared by all receivers, the encoder holds a
+// reference to it until Flush.
+func (e *Encoder) WriteRelayFrame(f *Frame, from int32, sequence uint64, priority RelayRequest_Priority) error {
+	// header and fields share the headers buffer, see WriteRaw
+	payload := f.Payload()
+	start := len(e.headers)
+	e.headers = append(e.headers, make([]byte, HeaderLen)...)
+	e.headers = AppendReceiverFields(e.headers, from, sequence, priority)
+	header, fields := e.headers[start:start+HeaderLen], e.headers[start+HeaderLen:]
+	length := len(payload) + len(fields)
+	if err := e.fits(length); err != nil {
+		e.headers = e.headers[:start]
+		return err
+	}
+	putHeader(header, MsgType(f.Bytes()[0]), length)
+
+	f.Retain()
+	e.frames = append(e.frames, f)
+	e.bufs = append(e.bufs, header, payload, fields)
+	return e.queued(len(header) + len(payload) + len(fields))
+}
+
+func (e *Encoder) queued(size int) error {
+	e.pending += size
+	if e.pending >= maxBuffered {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Buffered returns the number of bytes waiting for Flush
+func (e *Encoder) Buffered() int {
+	return e.pending
+}
+
+// Flush writes all queued frames. Pending frames are dropped on error,
+// as the stream can't be resumed after a partial frame anyway.
+func (e *Encoder) Flush() error {
+	if e.pending == 0 {
+		return nil
+	}
+
+	// WriteTo repeats writes until everything is written or an error occurs. It consumes
+	// the buffers it is called on, so it gets a copy which keeps e.bufs reusable.
+	var err error
+	if bw, ok := e.w.(BuffersWriter); ok {
+		_, err = bw.WriteBuffers(e.bufs)
+	} else {
+		e.out = e.bufs
+		_, err = e.out.WriteTo(e.w)
+		e.out = nil
+	}
+
+	for i := range e.bufs {
+		e.bufs[i] = nil
+	}
+	for i, f := range e.frames {
+		f.Release()
+		e.frames[i] = nil
+	}
+	e.bufs = e.bufs[:0]
+	e.frames = e.frames[:0]
+	e.headers = e.headers[:0]
+	e.pending = 0
+
+	return err
+}
+
+// Decoder reads frames from a buffered io.Reader
+type Decoder struct {
+	r        *bufio.Reader
+	codec    Codec
+	maxFrame int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Decoder{
+		r: br,
+	}
+}
+
+// SetCodec decompresses frames decoded from now on with codec
+func (d *Decoder) SetCodec(codec Codec) {
+	d.codec = codec
+}
+
+// SetMaxFrame makes the decoder fail on frames with longer payloads than max before
+// reading them, 0 is unlimited. The connection should be closed after ErrFrameTooLarge.
+func (d *Decoder) SetMaxFrame(max int) {
+	d.maxFrame = max
+}
+
+// checkFrame peeks at the header of the next frame and checks its length
+func (d *Decoder) checkFrame() error {
+	if d.maxFrame <= 0 {
+		return nil
+	}
+	header, err := d.r.Peek(HeaderLen)
+	if err != nil {
+		if err == io.EOF && len(header) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if int64(binary.BigEndian.Uint32(header[typeLen:])) > int64(d.maxFrame) {
+		return ErrFrameTooLarge
+	}
+	return nil
+}
+
+// Decode reads the next frame and returns its payload and type. Compressed frames
+// are decompressed, an error wrapping ErrDecompress leaves the decoder usable.
+func (d *Decoder) Decode() ([]byte, MsgType, error) {
+	if err := d.checkFrame(); err != nil {
+		return nil, MsgTypeUnknown, err
+	}
+	if d.codec == nil {
+		return Decode(d.r)
+	}
+
+	// the length was checked against the maximum of the decoder
+	f, err := decodeFrame(d.r, 0)
+	if err != nil {
+		return nil, MsgTypeUnknown, err
+	}
+	defer f.Release()
+	if f.Compressed() {
+		payload, err := Decompress(f, d.codec, MaxDecompressedLength)
+		return payload, f.Type(), err
+	}
+	payload := make([]byte, len(f.Payload()))
+	copy(payload, f.Payload())
+	return payload, f.Type(), nil
+}
+
+// DecodeFrame reads the next frame into a pooled buffer, the caller must Release it
+func (d *Decoder) DecodeFrame() (*Frame, error) {
+	if err := d.checkFrame(); err != nil {
+		return nil, err
+	}
+	return decodeFrame(d.r, 0)
+}
+
+// Buffered returns the number of bytes read from the underlying reader but not decoded yet
+func (d *Decoder) Buffered() int {
+	return d.r.Buffered()
+}
@@ -0,0 +1,166 @@
+package messages
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEncoder_Flush(t *testing.T) {
+	// arrange
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	relay, err := EncodeFrame(&Relay{Body: []byte{99}}, MsgTypeRelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err = enc.Encode(&Request{Id: 123}, MsgTypeRequest)
+	if err == nil {
+		err = enc.WriteRaw(MsgTypeIdentityResponse, []byte{8, 123})
+	}
+	if err == nil {
+		err = enc.WriteFrame(relay)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.Release()
+
+	// assert
+	if w.Len() != 0 {
+		t.Errorf("Encoder wrote %d bytes before Flush", w.Len())
+	}
+	if enc.Buffered() != 22 {
+		t.Errorf("Buffered() = %d, want %d", enc.Buffered(), 22)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 0, 0, 0, 2, 16, 123,
+		2, 0, 0, 0, 2, 8, 123,
+		5, 0, 0, 0, 3, 26, 1, 99,
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Flush() wrote %v, want %v", w.Bytes(), want)
+	}
+	if enc.Buffered() != 0 {
+		t.Errorf("Buffered() = %d after Flush, want 0", enc.Buffered())
+	}
+}
+
+func TestEncoder_Flush_error(t *testing.T) {
+	enc := NewEncoder(failingWriter{})
+	if err := enc.Encode(&Request{Id: 123}, MsgTypeRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Flush(); err == nil {
+		t.Error("Flush() error = nil, want error")
+	}
+	if enc.Buffered() != 0 {
+		t.Errorf("Buffered() = %d after failed Flush, want 0", enc.Buffered())
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	// arrange
+	r, w := io.Pipe()
+	go func() {
+		enc := NewEncoder(w)
+		enc.Encode(&IdentityResponse{Id: 123}, MsgTypeIdentityResponse)
+		enc.Encode(&ListResponse{Ids: []int32{123}}, MsgTypeListResponse)
+		enc.Flush()
+		w.Close()
+	}()
+	dec := NewDecoder(r)
+
+	// act
+	var got []MsgType
+	for {
+		_, msgType, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msgType)
+	}
+
+	// assert
+	want := []MsgType{MsgTypeIdentityResponse, MsgTypeListResponse}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() types = %v, want %v", got, want)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestDecoder_SetMaxFrame(t *testing.T) {
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	enc.Encode(&Relay{Body: make([]byte, 10)}, MsgTypeRelay)
+	enc.Flush()
+
+	dec := NewDecoder(&w)
+	dec.SetMaxFrame(8)
+	if _, err := dec.DecodeFrame(); err != ErrFrameTooLarge {
+		t.Errorf("DecodeFrame() error = %v, want %v", err, ErrFrameTooLarge)
+	}
+
+	enc.SetMaxFrame(8)
+	if err := enc.Encode(&Relay{Body: make([]byte, 10)}, MsgTypeRelay); err != ErrFrameTooLarge {
+		t.Errorf("Encode() error = %v, want %v", err, ErrFrameTooLarge)
+	}
+}
+
+func TestEncoder_WriteRelayFrame(t *testing.T) {
+	// arrange
+	var w bytes.Buffer
+	enc := NewEncoder(&w)
+	relay, err := EncodeFrame(&Relay{Body: []byte{99}, MessageId: 7}, MsgTypeRelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// act
+	err = enc.WriteRelayFrame(relay, 123, 1, RelayRequest_NORMAL)
+	if err == nil {
+		err = enc.WriteRelayFrame(relay, 124, 300, RelayRequest_HIGH)
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.Release()
+
+	// assert
+	r := bytes.NewReader(w.Bytes())
+	for _, want := range []*Relay{
+		{Body: []byte{99}, MessageId: 7, From: 123, Sequence: 1},
+		{Body: []byte{99}, MessageId: 7, From: 124, Sequence: 300, Priority: RelayRequest_HIGH},
+	} {
+		payload, msgType, err := Decode(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Relay
+		if err := got.Unmarshal(payload); err != nil {
+			t.Fatal(err)
+		}
+		if msgType != MsgTypeRelay || !reflect.DeepEqual(&got, want) {
+			t.Errorf("WriteRelayFrame() wrote %s %#v, want %#v", msgType, got, want)
+		}
+	}
+}